@@ -4,12 +4,19 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
+	"time"
 
+	"gemini-zeromq-labs/lab01/internal/alert"
 	"gemini-zeromq-labs/lab01/internal/config"
+	"gemini-zeromq-labs/lab01/internal/health"
 	"gemini-zeromq-labs/lab01/internal/protocol"
+	"gemini-zeromq-labs/lab01/internal/tsdb"
 
 	"github.com/go-zeromq/zmq4"
 )
@@ -48,15 +55,49 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger.Info("Waiting for metrics...")
+	// Initialize ZeroMQ PUB socket for liveness events
+	events := zmq4.NewPub(ctx)
+	defer events.Close()
 
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Info("Shutting down dashboard...")
-			return
-		default:
-			// Read message
+	logger.Info("Publishing liveness events", "endpoint", cfg.EventsEndpoint)
+	if err := events.Listen(cfg.EventsEndpoint); err != nil {
+		logger.Error("Failed to bind events endpoint", "error", err)
+		os.Exit(1)
+	}
+
+	// Time series store, served over HTTP
+	store := tsdb.NewStore(cfg.SamplesPerHost)
+	server := &http.Server{Addr: cfg.HTTPAddr, Handler: tsdb.Handler(store)}
+	go func() {
+		logger.Info("HTTP server listening", "addr", cfg.HTTPAddr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("HTTP server failed", "error", err)
+			cancel()
+		}
+	}()
+	defer server.Close()
+
+	// Threshold alerting (optional)
+	var engine *alert.Engine
+	alertChan := make(chan protocol.Alert, 256)
+	if cfg.AlertRules != "" {
+		rules, err := alert.LoadRules(cfg.AlertRules)
+		if err != nil {
+			logger.Error("Failed to load alert rules", "error", err)
+			os.Exit(1)
+		}
+		engine = alert.NewEngine(rules)
+		logger.Info("Alert rules loaded", "rules", len(rules), "endpoint", cfg.AlertsEndpoint)
+		go runAlertPusher(ctx, cfg.AlertsEndpoint, alertChan, logger)
+	}
+
+	interval := time.Duration(cfg.Interval) * time.Second
+	registry := health.NewRegistry(interval, cfg.SuspectAfter, cfg.DownAfter)
+
+	// Receive in a goroutine so the main loop can also sweep the registry
+	msgChan := make(chan zmq4.Msg)
+	go func() {
+		for {
 			msg, err := sub.Recv()
 			if err != nil {
 				// check if context cancelled
@@ -66,31 +107,186 @@ func main() {
 				logger.Error("Error receiving message", "error", err)
 				continue
 			}
+			select {
+			case msgChan <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	sweep := time.NewTicker(interval)
+	defer sweep.Stop()
+
+	logger.Info("Waiting for metrics...",
+		"suspect_after", cfg.SuspectAfter,
+		"down_after", cfg.DownAfter)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Shutting down dashboard...")
+			return
+
+		case now := <-sweep.C:
+			for _, t := range registry.Sweep(now) {
+				publishTransition(events, t, logger)
+			}
 
-			// Expected Frames: [Topic, Payload]
+		case msg := <-msgChan:
+			// Expected Frames: [Topic, Payload] (legacy JSON) or [Topic, Codec, Payload]
 			if len(msg.Frames) < 2 {
 				logger.Warn("Received malformed message", "frames", len(msg.Frames))
 				continue
 			}
 
-			// topic := string(msg.Frames[0])
-			payload := msg.Frames[1]
-
-			metric, err := protocol.FromJSON(payload)
+			metric, err := protocol.DecodeFrames(msg.Frames)
 			if err != nil {
 				logger.Error("Error parsing metric", "error", err)
 				continue
 			}
 
-			// Print formatted (keep fmt for Dashboard display as it is a UI)
-			fmt.Printf("[%s] %s@%s | CPU: %.2f%% | Mem: %.0f MB | Status: %s\n",
-				metric.Timestamp.Format("15:04:05"),
-				metric.Service,
-				metric.Host,
-				metric.CPU,
-				metric.Memory,
-				metric.Status,
-			)
+			// Liveness is based on receive time, not the agent clock
+			if t := registry.Observe(metric.Service, metric.Host, time.Now()); t != nil {
+				publishTransition(events, *t, logger)
+			}
+
+			store.Append(*metric)
+			if engine != nil {
+				for _, a := range engine.Evaluate(metric) {
+					fmt.Printf("[%s] !!! ALERT %s %s on %s@%s: %s=%.2f (threshold %.2f) !!!\n",
+						a.Timestamp.Format("15:04:05"), a.Rule, a.State, a.Service, a.Host, a.Field, a.Value, a.Threshold)
+					select {
+					case alertChan <- a:
+					default:
+						logger.Warn("Alert queue full, dropping alert", "rule", a.Rule, "state", a.State)
+					}
+				}
+			}
+			printMetric(metric)
+		}
+	}
+}
+
+// printMetric renders a metric line (keep fmt for Dashboard display as it is a UI).
+func printMetric(metric *protocol.Metric) {
+	if metric.Kind == "" {
+		// Legacy agents only report CPU and Memory
+		fmt.Printf("[%s] %s@%s | CPU: %.2f%% | Mem: %.0f MB | Status: %s\n",
+			metric.Timestamp.Format("15:04:05"),
+			metric.Service,
+			metric.Host,
+			metric.CPU,
+			metric.Memory,
+			metric.Status,
+		)
+		return
+	}
+
+	keys := make([]string, 0, len(metric.Values))
+	for k := range metric.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%.2f", k, metric.Values[k])
+	}
+	fmt.Printf("[%s] %s@%s | %s:%s | Status: %s\n",
+		metric.Timestamp.Format("15:04:05"),
+		metric.Service,
+		metric.Host,
+		metric.Kind,
+		b.String(),
+		metric.Status,
+	)
+}
+
+// runAlertPusher connects a PUSH socket to the alert logger and forwards alerts as JSON.
+// It runs separately so an absent alert logger never stalls metric processing.
+func runAlertPusher(ctx context.Context, endpoint string, alerts <-chan protocol.Alert, logger *slog.Logger) {
+	push := zmq4.NewPush(ctx, zmq4.WithDialerMaxRetries(-1))
+	defer push.Close()
+
+	if err := push.Dial(endpoint); err != nil {
+		if ctx.Err() == nil {
+			logger.Error("Failed to connect to alert logger", "endpoint", endpoint, "error", err)
+		}
+		return
+	}
+	logger.Info("Connected to alert logger", "endpoint", endpoint)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case a := <-alerts:
+			payload, err := a.ToJSON()
+			if err != nil {
+				logger.Error("Error serializing alert", "error", err)
+				continue
+			}
+			if err := push.Send(zmq4.NewMsg(payload)); err != nil {
+				logger.Error("Error pushing alert", "error", err)
+			}
 		}
 	}
 }
+
+// publishTransition converts a registry transition into a HostEvent and publishes it.
+func publishTransition(pub zmq4.Socket, t health.Transition, logger *slog.Logger) {
+	event := protocol.HostEvent{
+		Timestamp:     time.Now(),
+		Type:          eventType(t),
+		Service:       t.Agent.Service,
+		Host:          t.Agent.Host,
+		PreviousState: string(t.From),
+		State:         string(t.To),
+		LastSeen:      t.Agent.LastSeen,
+		Missed:        t.Missed,
+	}
+
+	fmt.Printf("[%s] *** %s@%s is %s (was %s, missed %d intervals) ***\n",
+		event.Timestamp.Format("15:04:05"),
+		event.Service,
+		event.Host,
+		event.Type,
+		orNone(event.PreviousState),
+		event.Missed,
+	)
+
+	payload, err := event.ToJSON()
+	if err != nil {
+		logger.Error("Error serializing event", "error", err)
+		return
+	}
+
+	// Create ZMQ message: [Topic] [Payload]
+	msg := zmq4.NewMsgFrom([]byte(event.Topic()), payload)
+	if err := pub.Send(msg); err != nil {
+		logger.Error("Error publishing event", "error", err)
+		return
+	}
+	logger.Info("Published liveness event", "type", event.Type, "agent", t.Agent.Key())
+}
+
+func eventType(t health.Transition) protocol.EventType {
+	switch {
+	case t.From == "":
+		return protocol.EventJoined
+	case t.To == health.StateSuspect:
+		return protocol.EventSuspect
+	case t.To == health.StateDown:
+		return protocol.EventDown
+	default:
+		return protocol.EventRecovered
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "NONE"
+	}
+	return s
+}
@@ -3,12 +3,12 @@ package main
 import (
 	"context"
 	"log/slog"
-	"math/rand"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"gemini-zeromq-labs/lab01/internal/collector"
 	"gemini-zeromq-labs/lab01/internal/config"
 	"gemini-zeromq-labs/lab01/internal/protocol"
 
@@ -31,6 +31,36 @@ func main() {
 		cancel()
 	}()
 
+	// Build the enabled collectors before touching the network
+	type scheduled struct {
+		collector collector.Collector
+		interval  time.Duration
+	}
+	var enabled []scheduled
+	for _, spec := range cfg.Collectors {
+		c, err := collector.New(spec.Name)
+		if err != nil {
+			logger.Error("Invalid collector", "error", err)
+			os.Exit(1)
+		}
+		enabled = append(enabled, scheduled{collector: c, interval: time.Duration(spec.Interval) * time.Second})
+	}
+
+	codec, err := protocol.LookupCodec(cfg.Codec)
+	if err != nil {
+		logger.Error("Invalid codec", "error", err)
+		os.Exit(1)
+	}
+
+	// JSON goes out in the legacy 2-frame layout unless asked otherwise, so
+	// agents and dashboards can be upgraded in either order.
+	legacy := codec.Name() == protocol.JSONCodec{}.Name() && !cfg.CodecFrame
+
+	if len(enabled) == 0 {
+		logger.Error("No collectors enabled")
+		os.Exit(1)
+	}
+
 	// Initialize ZeroMQ PUB socket
 	// Agent Connects, Dashboard Binds.
 	pub := zmq4.NewPub(ctx)
@@ -43,45 +73,71 @@ func main() {
 	}
 
 	hostname, _ := os.Hostname()
-	serviceName := "monitor-agent-01"
 
-	ticker := time.NewTicker(time.Duration(cfg.Interval) * time.Second)
-	defer ticker.Stop()
+	// Each collector runs on its own ticker; the socket is only used from the main loop.
+	outChan := make(chan zmq4.Msg)
+	for _, s := range enabled {
+		topic := collector.Topic(cfg.Topic, s.collector.Name(), hostname)
+		logger.Info("Starting collector", "collector", s.collector.Name(), "topic", topic, "interval", s.interval.String())
+		go runCollector(ctx, s.collector, s.interval, topic, cfg.Service, hostname, codec, legacy, outChan, logger)
+	}
 
-	logger.Info("Starting metrics publishing", "topic", cfg.Topic, "interval_sec", cfg.Interval)
+	logger.Info("Starting metrics publishing", "service", cfg.Service, "collectors", len(enabled), "codec", string(protocol.CodecFrame(codec)), "legacy_frames", legacy)
 
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Info("Shutting down agent...")
 			return
+		case msg := <-outChan:
+			if err := pub.Send(msg); err != nil {
+				logger.Error("Error sending message", "error", err)
+			}
+		}
+	}
+}
+
+// runCollector samples c every interval and hands the encoded message to out.
+func runCollector(ctx context.Context, c collector.Collector, interval time.Duration, topic, service, hostname string, codec protocol.Codec, legacy bool, out chan<- zmq4.Msg, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			// Generate mock metrics
 			metric := protocol.Metric{
 				Timestamp: time.Now(),
-				Service:   serviceName,
+				Service:   service,
 				Host:      hostname,
-				CPU:       rand.Float64() * 100,
-				Memory:    rand.Float64() * 16384, // MB
+				Kind:      c.Name(),
 				Status:    "OK",
 			}
+			if err := c.Collect(ctx, &metric); err != nil {
+				logger.Error("Error collecting metric", "collector", c.Name(), "error", err)
+				metric.Status = "ERROR"
+			}
 
-			payload, err := metric.ToJSON()
+			// Create ZMQ message: [Topic] [Codec] [Payload], or [Topic] [JSON Payload]
+			var frames [][]byte
+			var err error
+			if legacy {
+				frames, err = protocol.EncodeLegacyFrames(topic, &metric)
+			} else {
+				frames, err = protocol.EncodeFrames(topic, &metric, codec)
+			}
 			if err != nil {
 				logger.Error("Error serializing metric", "error", err)
 				continue
 			}
+			msg := zmq4.NewMsgFrom(frames...)
 
-			// Create ZMQ message: [Topic] [Payload]
-			msg := zmq4.NewMsgFrom(
-				[]byte(cfg.Topic),
-				payload,
-			)
-
-			if err := pub.Send(msg); err != nil {
-				logger.Error("Error sending message", "error", err)
-			} else {
-				logger.Info("Sent metric", "cpu", metric.CPU, "memory_mb", metric.Memory)
+			select {
+			case out <- msg:
+				logger.Info("Sent metric", "collector", c.Name(), "values", metric.Values)
+			case <-ctx.Done():
+				return
 			}
 		}
 	}
@@ -0,0 +1,92 @@
+package alert
+
+import (
+	"time"
+
+	"gemini-zeromq-labs/lab01/internal/protocol"
+)
+
+type seriesKey struct {
+	rule    int
+	service string
+	host    string
+}
+
+// ruleState tracks one rule against one Service@Host.
+type ruleState struct {
+	active bool
+	streak int // consecutive samples moving towards the opposite state
+}
+
+// Engine evaluates metrics against rules. It is not safe for concurrent use.
+type Engine struct {
+	rules  []Rule
+	states map[seriesKey]*ruleState
+}
+
+// NewEngine creates an engine for already validated rules (see LoadRules).
+func NewEngine(rules []Rule) *Engine {
+	return &Engine{rules: rules, states: make(map[seriesKey]*ruleState)}
+}
+
+// Evaluate feeds one metric through every rule and returns raised/cleared alerts.
+func (e *Engine) Evaluate(m *protocol.Metric) []protocol.Alert {
+	fields := m.Fields()
+
+	var out []protocol.Alert
+	for i := range e.rules {
+		r := &e.rules[i]
+		v, ok := fields[r.Field]
+		if !ok {
+			continue
+		}
+
+		key := seriesKey{rule: i, service: m.Service, host: m.Host}
+		st, ok := e.states[key]
+		if !ok {
+			st = &ruleState{}
+			e.states[key] = st
+		}
+
+		var state protocol.AlertState
+		var threshold float64
+		if !st.active {
+			if !r.breached(v) {
+				st.streak = 0
+				continue
+			}
+			st.streak++
+			if st.streak < r.For {
+				continue
+			}
+			state, threshold = protocol.AlertRaised, r.Raise
+		} else {
+			if !r.recovered(v) {
+				// Values inside the hysteresis band keep the alert raised
+				st.streak = 0
+				continue
+			}
+			st.streak++
+			if st.streak < r.ClearFor {
+				continue
+			}
+			state, threshold = protocol.AlertCleared, *r.Clear
+		}
+
+		out = append(out, protocol.Alert{
+			Timestamp: time.Now(),
+			Rule:      r.Name,
+			State:     state,
+			Severity:  r.Severity,
+			Service:   m.Service,
+			Host:      m.Host,
+			Field:     r.Field,
+			Value:     v,
+			Threshold: threshold,
+			Samples:   st.streak,
+		})
+		st.active = !st.active
+		st.streak = 0
+	}
+	return out
+}
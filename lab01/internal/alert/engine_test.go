@@ -0,0 +1,132 @@
+package alert
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gemini-zeromq-labs/lab01/internal/protocol"
+)
+
+func rule(t *testing.T, r Rule) Rule {
+	t.Helper()
+	if err := r.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func ptr(v float64) *float64 { return &v }
+
+// run feeds cpu values for host h1 and returns the alert state changes,
+// one letter per sample: R raised, C cleared, . nothing.
+func run(e *Engine, host string, values ...float64) string {
+	var b strings.Builder
+	for _, v := range values {
+		alerts := e.Evaluate(&protocol.Metric{Service: "agent", Host: host, CPU: v})
+		switch {
+		case len(alerts) == 0:
+			b.WriteByte('.')
+		case alerts[0].State == protocol.AlertRaised:
+			b.WriteByte('R')
+		default:
+			b.WriteByte('C')
+		}
+	}
+	return b.String()
+}
+
+func TestEngineHysteresis(t *testing.T) {
+	cases := []struct {
+		name   string
+		rule   Rule
+		values []float64
+		want   string
+	}{
+		{"raise and clear", Rule{Field: "cpu", Op: ">", Raise: 90}, []float64{50, 95, 95, 80}, ".R.C"},
+		{"band keeps it raised", Rule{Field: "cpu", Op: ">", Raise: 90, Clear: ptr(70)}, []float64{95, 85, 75, 91, 70}, "R...C"},
+		{"for consecutive samples", Rule{Field: "cpu", Op: ">", Raise: 90, For: 3}, []float64{95, 95, 50, 95, 95, 95, 95}, ".....R."},
+		{"clear for consecutive samples", Rule{Field: "cpu", Op: ">", Raise: 90, Clear: ptr(70), ClearFor: 2}, []float64{95, 60, 80, 60, 60}, "R...C"},
+		{"below", Rule{Field: "cpu", Op: "<", Raise: 10, Clear: ptr(20)}, []float64{15, 5, 15, 20}, ".R.C"},
+		{"equal is not a breach", Rule{Field: "cpu", Op: ">", Raise: 90}, []float64{90, 90}, ".."},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEngine([]Rule{rule(t, tc.rule)})
+			if got := run(e, "h1", tc.values...); got != tc.want {
+				t.Errorf("alerts %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEngineSeriesAreSeparate(t *testing.T) {
+	e := NewEngine([]Rule{rule(t, Rule{Field: "cpu", Op: ">", Raise: 90, For: 2})})
+	run(e, "h1", 95)
+	if got := run(e, "h2", 95); got != "." {
+		t.Errorf("h2 alerts %s on its first breach, the streak belongs to h1", got)
+	}
+	if got := run(e, "h1", 95); got != "R" {
+		t.Errorf("h1 alerts %s, want R", got)
+	}
+
+	// Fields a metric does not carry are skipped
+	e = NewEngine([]Rule{rule(t, Rule{Field: "disk.used", Op: ">", Raise: 1})})
+	if got := run(e, "h1", 95); got != "." {
+		t.Errorf("alerts %s for a missing field", got)
+	}
+}
+
+func TestEngineAlert(t *testing.T) {
+	e := NewEngine([]Rule{rule(t, Rule{Name: "hot", Field: "cpu", Op: ">", Raise: 90, Clear: ptr(70), For: 2, Severity: "critical"})})
+	e.Evaluate(&protocol.Metric{Service: "agent", Host: "h1", CPU: 95})
+	alerts := e.Evaluate(&protocol.Metric{Service: "agent", Host: "h1", CPU: 97})
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	a := alerts[0]
+	if a.Rule != "hot" || a.State != protocol.AlertRaised || a.Severity != "critical" || a.Host != "h1" ||
+		a.Field != "cpu" || a.Value != 97 || a.Threshold != 90 || a.Samples != 2 || a.Timestamp.IsZero() {
+		t.Errorf("raised %+v", a)
+	}
+	a = e.Evaluate(&protocol.Metric{Service: "agent", Host: "h1", CPU: 60})[0]
+	if a.State != protocol.AlertCleared || a.Threshold != 70 || a.Samples != 1 {
+		t.Errorf("cleared %+v", a)
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	rules, err := LoadRules(write("ok.json", `[{"field":"cpu","op":">","raise":90}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rules[0]
+	if r.Name != "cpu > 90" || r.For != 1 || r.ClearFor != 1 || r.Clear == nil || *r.Clear != 90 {
+		t.Errorf("defaults not applied: %+v", r)
+	}
+
+	bad := map[string]string{
+		"no field":         `[{"op":">","raise":90}]`,
+		"unknown op":       `[{"field":"cpu","op":">=","raise":90}]`,
+		"clear above >":    `[{"field":"cpu","op":">","raise":90,"clear":95}]`,
+		"clear below <":    `[{"field":"cpu","op":"<","raise":10,"clear":5}]`,
+		"not a rule array": `{"field":"cpu"}`,
+	}
+	for name, data := range bad {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadRules(write("bad.json", data)); err == nil {
+				t.Error("LoadRules accepted the rules")
+			}
+		})
+	}
+}
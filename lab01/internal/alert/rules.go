@@ -0,0 +1,88 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Rule raises an alert when Field crosses Raise for For consecutive samples
+// and clears it once the value is back past Clear for ClearFor samples.
+// Keeping Clear below Raise (for ">") gives the hysteresis band that stops flapping.
+type Rule struct {
+	Name     string   `json:"name"`
+	Field    string   `json:"field"`               // e.g. "cpu", "cpu.percent", "memory.used_mb"
+	Op       string   `json:"op"`                  // ">" or "<"
+	Raise    float64  `json:"raise"`               // threshold that raises the alert
+	Clear    *float64 `json:"clear,omitempty"`     // threshold that clears it; defaults to Raise
+	For      int      `json:"for,omitempty"`       // consecutive samples to raise; defaults to 1
+	ClearFor int      `json:"clear_for,omitempty"` // consecutive samples to clear; defaults to 1
+	Severity string   `json:"severity,omitempty"`
+}
+
+// LoadRules reads a JSON array of rules from path and validates them.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i := range rules {
+		if err := rules[i].normalize(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, rules[i].Name, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r *Rule) normalize() error {
+	if r.Field == "" {
+		return fmt.Errorf("missing field")
+	}
+	if r.Name == "" {
+		r.Name = fmt.Sprintf("%s %s %g", r.Field, r.Op, r.Raise)
+	}
+	if r.For < 1 {
+		r.For = 1
+	}
+	if r.ClearFor < 1 {
+		r.ClearFor = 1
+	}
+	if r.Clear == nil {
+		clear := r.Raise
+		r.Clear = &clear
+	}
+
+	switch r.Op {
+	case ">":
+		if *r.Clear > r.Raise {
+			return fmt.Errorf("clear (%g) must not be above raise (%g) for '>'", *r.Clear, r.Raise)
+		}
+	case "<":
+		if *r.Clear < r.Raise {
+			return fmt.Errorf("clear (%g) must not be below raise (%g) for '<'", *r.Clear, r.Raise)
+		}
+	default:
+		return fmt.Errorf("unsupported op %q (use '>' or '<')", r.Op)
+	}
+	return nil
+}
+
+// breached reports whether v is past the raise threshold.
+func (r *Rule) breached(v float64) bool {
+	if r.Op == "<" {
+		return v < r.Raise
+	}
+	return v > r.Raise
+}
+
+// recovered reports whether v is back past the clear threshold.
+func (r *Rule) recovered(v float64) bool {
+	if r.Op == "<" {
+		return v >= *r.Clear
+	}
+	return v <= *r.Clear
+}
@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"gemini-zeromq-labs/lab01/internal/protocol"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+const mb = 1024 * 1024
+
+// CPU reports total CPU utilisation since the previous call.
+type CPU struct{}
+
+func (c *CPU) Name() string { return "cpu" }
+
+func (c *CPU) Collect(ctx context.Context, m *protocol.Metric) error {
+	// A zero interval compares against the previous call instead of sleeping.
+	percent, err := cpu.PercentWithContext(ctx, 0, false)
+	if err != nil {
+		return err
+	}
+	if len(percent) > 0 {
+		m.CPU = percent[0]
+	}
+	m.Values = map[string]float64{"percent": m.CPU}
+	return nil
+}
+
+// Memory reports virtual memory usage in MB.
+type Memory struct{}
+
+func (c *Memory) Name() string { return "memory" }
+
+func (c *Memory) Collect(ctx context.Context, m *protocol.Metric) error {
+	v, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	m.Memory = float64(v.Used) / mb
+	m.Values = map[string]float64{
+		"total_mb":     float64(v.Total) / mb,
+		"used_mb":      float64(v.Used) / mb,
+		"available_mb": float64(v.Available) / mb,
+		"used_percent": v.UsedPercent,
+	}
+	return nil
+}
+
+// Disk reports usage summed over all physical partitions.
+type Disk struct{}
+
+func (c *Disk) Name() string { return "disk" }
+
+func (c *Disk) Collect(ctx context.Context, m *protocol.Metric) error {
+	parts, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	var total, used, free uint64
+	seen := make(map[string]bool)
+	for _, p := range parts {
+		// The same device can be mounted more than once
+		if seen[p.Device] {
+			continue
+		}
+		seen[p.Device] = true
+
+		u, err := disk.UsageWithContext(ctx, p.Mountpoint)
+		if err != nil {
+			continue
+		}
+		total += u.Total
+		used += u.Used
+		free += u.Free
+	}
+
+	var usedPercent float64
+	if total > 0 {
+		usedPercent = float64(used) / float64(total) * 100
+	}
+	m.Values = map[string]float64{
+		"total_mb":     float64(total) / mb,
+		"used_mb":      float64(used) / mb,
+		"free_mb":      float64(free) / mb,
+		"used_percent": usedPercent,
+		"partitions":   float64(len(seen)),
+	}
+	return nil
+}
+
+// Network reports interface counters summed over all NICs,
+// plus per-second rates relative to the previous sample.
+type Network struct {
+	last     *net.IOCountersStat
+	lastTime time.Time
+}
+
+func (c *Network) Name() string { return "network" }
+
+func (c *Network) Collect(ctx context.Context, m *protocol.Metric) error {
+	counters, err := net.IOCountersWithContext(ctx, false)
+	if err != nil {
+		return err
+	}
+	if len(counters) == 0 {
+		return nil
+	}
+	now := time.Now()
+	cur := counters[0]
+
+	m.Values = map[string]float64{
+		"bytes_sent":   float64(cur.BytesSent),
+		"bytes_recv":   float64(cur.BytesRecv),
+		"packets_sent": float64(cur.PacketsSent),
+		"packets_recv": float64(cur.PacketsRecv),
+		"errors":       float64(cur.Errin + cur.Errout),
+		"drops":        float64(cur.Dropin + cur.Dropout),
+	}
+
+	if c.last != nil {
+		if secs := now.Sub(c.lastTime).Seconds(); secs > 0 {
+			m.Values["bytes_sent_per_sec"] = rate(cur.BytesSent, c.last.BytesSent, secs)
+			m.Values["bytes_recv_per_sec"] = rate(cur.BytesRecv, c.last.BytesRecv, secs)
+		}
+	}
+	c.last = &cur
+	c.lastTime = now
+	return nil
+}
+
+func rate(cur, prev uint64, secs float64) float64 {
+	// Counters can reset when an interface goes away
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / secs
+}
+
+// Load reports the 1, 5 and 15 minute load averages.
+type Load struct{}
+
+func (c *Load) Name() string { return "load" }
+
+func (c *Load) Collect(ctx context.Context, m *protocol.Metric) error {
+	avg, err := load.AvgWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	m.Values = map[string]float64{
+		"load1":  avg.Load1,
+		"load5":  avg.Load5,
+		"load15": avg.Load15,
+	}
+	return nil
+}
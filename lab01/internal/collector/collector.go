@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gemini-zeromq-labs/lab01/internal/protocol"
+)
+
+// Collector gathers one family of host metrics.
+// Collect fills the Kind-specific fields of m (CPU, Memory, Values);
+// the caller sets Timestamp, Service, Host and Status.
+type Collector interface {
+	Name() string
+	Collect(ctx context.Context, m *protocol.Metric) error
+}
+
+// factories holds the built-in collectors, keyed by name.
+var factories = map[string]func() Collector{
+	"cpu":     func() Collector { return &CPU{} },
+	"memory":  func() Collector { return &Memory{} },
+	"disk":    func() Collector { return &Disk{} },
+	"network": func() Collector { return &Network{} },
+	"load":    func() Collector { return &Load{} },
+}
+
+// New returns a fresh instance of the built-in collector called name.
+func New(name string) (Collector, error) {
+	f, ok := factories[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown collector %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return f(), nil
+}
+
+// Names lists the built-in collectors in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for n := range factories {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Topic returns the publish topic of a collector for a host, e.g. "metrics.cpu.node-1".
+func Topic(prefix, name, host string) string {
+	return fmt.Sprintf("%s.%s.%s", prefix, name, host)
+}
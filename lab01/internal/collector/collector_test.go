@@ -0,0 +1,23 @@
+package collector
+
+import "testing"
+
+func TestNew(t *testing.T) {
+	for _, name := range Names() {
+		c, err := New(name)
+		if err != nil {
+			t.Fatalf("New(%q): %v", name, err)
+		}
+		if c.Name() != name {
+			t.Errorf("New(%q).Name() = %q", name, c.Name())
+		}
+	}
+	if _, err := New("CPU"); err != nil {
+		t.Errorf("New is case sensitive: %v", err)
+	}
+	for _, name := range []string{"gpu", ""} {
+		if _, err := New(name); err == nil {
+			t.Errorf("New(%q) succeeded, want an error", name)
+		}
+	}
+}
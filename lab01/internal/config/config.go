@@ -2,7 +2,10 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // Config holds the configuration for the application.
@@ -10,6 +13,31 @@ type Config struct {
 	Endpoint string
 	Topic    string
 	Interval int // in seconds
+
+	// Monitor agent
+	Service    string
+	Collectors []CollectorSpec
+	Codec      string // wire codec: "json" or "binary"
+	CodecFrame bool   // send the codec frame with json too
+
+	// Dashboard liveness tracking
+	EventsEndpoint string // PUB endpoint for liveness events
+	SuspectAfter   int    // missed intervals before an agent is SUSPECT
+	DownAfter      int    // missed intervals before an agent is DOWN
+
+	// Dashboard time series store
+	HTTPAddr       string // listen address for the query and /metrics endpoints
+	SamplesPerHost int    // ring buffer capacity per host
+
+	// Dashboard alerting
+	AlertRules     string // path to a JSON rules file; empty disables alerting
+	AlertsEndpoint string // PUSH endpoint of the downstream alert logger
+}
+
+// CollectorSpec enables a collector with its own publish interval.
+type CollectorSpec struct {
+	Name     string
+	Interval int // in seconds
 }
 
 // LoadConfig loads configuration from command-line flags or environment variables.
@@ -18,6 +46,17 @@ func LoadConfig() *Config {
 	endpoint := flag.String("endpoint", "tcp://127.0.0.1:5555", "ZeroMQ endpoint")
 	topic := flag.String("topic", "metrics", "Subscription topic")
 	interval := flag.Int("interval", 2, "Publish interval in seconds")
+	service := flag.String("service", "monitor-agent-01", "Service name reported by the agent")
+	collectors := flag.String("collectors", "cpu,memory,disk,network,load", "Enabled collectors as name[:interval_sec], comma separated")
+	codec := flag.String("codec", "json", "Metric wire codec (json, binary)")
+	codecFrame := flag.Bool("codec-frame", false, "Send json in the 3-frame codec layout; leave off while dashboards older than the codec frame run (binary always uses it)")
+	eventsEndpoint := flag.String("events-endpoint", "tcp://*:5556", "Dashboard PUB endpoint for liveness events")
+	suspectAfter := flag.Int("suspect-after", 2, "Missed intervals before an agent is marked SUSPECT")
+	downAfter := flag.Int("down-after", 5, "Missed intervals before an agent is marked DOWN")
+	httpAddr := flag.String("http", ":8080", "Dashboard HTTP listen address (query API and Prometheus /metrics)")
+	alertRules := flag.String("alert-rules", "", "Path to JSON threshold rules (empty disables alerting)")
+	alertsEndpoint := flag.String("alerts-endpoint", "tcp://127.0.0.1:5557", "Alert logger endpoint the dashboard PUSHes alerts to")
+	samplesPerHost := flag.Int("samples-per-host", 1000, "Number of samples kept in memory per host")
 
 	flag.Parse()
 
@@ -26,11 +65,44 @@ func LoadConfig() *Config {
 	if envEndpoint := os.Getenv("ZMQ_ENDPOINT"); envEndpoint != "" && !isFlagPassed("endpoint") {
 		*endpoint = envEndpoint
 	}
+	if envService := os.Getenv("MONITOR_SERVICE"); envService != "" && !isFlagPassed("service") {
+		*service = envService
+	}
+	if envCollectors := os.Getenv("MONITOR_COLLECTORS"); envCollectors != "" && !isFlagPassed("collectors") {
+		*collectors = envCollectors
+	}
+	if envAlerts := os.Getenv("ZMQ_ALERTS_ENDPOINT"); envAlerts != "" && !isFlagPassed("alerts-endpoint") {
+		*alertsEndpoint = envAlerts
+	}
+	if envEvents := os.Getenv("ZMQ_EVENTS_ENDPOINT"); envEvents != "" && !isFlagPassed("events-endpoint") {
+		*eventsEndpoint = envEvents
+	}
+
+	specs, err := parseCollectors(*collectors, *interval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -collectors: %v\n", err)
+		os.Exit(2)
+	}
 
 	return &Config{
 		Endpoint: *endpoint,
 		Topic:    *topic,
 		Interval: *interval,
+
+		Service:    *service,
+		Collectors: specs,
+		Codec:      *codec,
+		CodecFrame: *codecFrame,
+
+		EventsEndpoint: *eventsEndpoint,
+		SuspectAfter:   *suspectAfter,
+		DownAfter:      *downAfter,
+
+		HTTPAddr:       *httpAddr,
+		SamplesPerHost: *samplesPerHost,
+
+		AlertRules:     *alertRules,
+		AlertsEndpoint: *alertsEndpoint,
 	}
 }
 
@@ -43,3 +115,37 @@ func isFlagPassed(name string) bool {
 	})
 	return found
 }
+
+// parseCollectors parses "cpu,memory:5,disk:30" into collector specs.
+// Entries without an explicit interval use defaultInterval; empty entries are
+// skipped. Names are only checked for duplicates here, collector.New rejects
+// unknown ones.
+func parseCollectors(value string, defaultInterval int) ([]CollectorSpec, error) {
+	var specs []CollectorSpec
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		spec := CollectorSpec{Name: strings.ToLower(item), Interval: defaultInterval}
+		if name, iv, ok := strings.Cut(item, ":"); ok {
+			n, err := strconv.Atoi(iv)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("bad interval for collector %q", name)
+			}
+			spec.Name = strings.ToLower(strings.TrimSpace(name))
+			spec.Interval = n
+		}
+		if spec.Name == "" {
+			return nil, fmt.Errorf("missing collector name in %q", item)
+		}
+		// Two entries would publish the same topic twice per interval
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("collector %q listed twice", spec.Name)
+		}
+		seen[spec.Name] = true
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
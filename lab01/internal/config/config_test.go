@@ -0,0 +1,51 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCollectors(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  []CollectorSpec
+	}{
+		{"defaults", "cpu,memory", []CollectorSpec{{"cpu", 2}, {"memory", 2}}},
+		{"intervals", "cpu:5, Disk:30", []CollectorSpec{{"cpu", 5}, {"disk", 30}}},
+		{"empty entries", ",cpu,, ,load,", []CollectorSpec{{"cpu", 2}, {"load", 2}}},
+		{"nothing", "", nil},
+		// Unknown names pass through; collector.New reports them with the available list
+		{"unknown name", "gpu:10", []CollectorSpec{{"gpu", 10}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseCollectors(tc.value, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("parseCollectors(%q) = %v, want %v", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseCollectorsErrors(t *testing.T) {
+	cases := map[string]string{
+		"duplicate":          "cpu,memory,cpu",
+		"duplicate any case": "cpu:5,CPU",
+		"zero interval":      "cpu:0",
+		"negative interval":  "cpu:-1",
+		"non-numeric":        "cpu:fast",
+		"missing name":       ":5",
+		"missing interval":   "cpu:",
+	}
+	for name, value := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got, err := parseCollectors(value, 2); err == nil {
+				t.Errorf("parseCollectors(%q) = %v, want an error", value, got)
+			}
+		})
+	}
+}
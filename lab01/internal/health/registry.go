@@ -0,0 +1,129 @@
+package health
+
+import (
+	"sort"
+	"time"
+)
+
+// State is the liveness state of a monitored agent.
+type State string
+
+const (
+	StateAlive   State = "ALIVE"
+	StateSuspect State = "SUSPECT"
+	StateDown    State = "DOWN"
+)
+
+// Agent is the registry entry for a single Service@Host pair.
+type Agent struct {
+	Service  string
+	Host     string
+	LastSeen time.Time
+	State    State
+}
+
+// Key returns the registry key ("Service@Host") of the agent.
+func (a Agent) Key() string {
+	return a.Service + "@" + a.Host
+}
+
+// Transition describes a state change of an agent.
+// From is empty when the agent is seen for the first time.
+type Transition struct {
+	Agent  Agent
+	From   State
+	To     State
+	Missed int // Number of whole intervals missed at the time of the transition
+}
+
+// Registry keeps the last-seen time of every agent and derives its state
+// from the number of missed heartbeat intervals.
+// It is not safe for concurrent use; the dashboard drives it from a single loop.
+type Registry struct {
+	interval     time.Duration
+	suspectAfter int
+	downAfter    int
+	agents       map[string]*Agent
+}
+
+// NewRegistry creates a registry that marks an agent SUSPECT after suspectAfter
+// missed intervals and DOWN after downAfter missed intervals.
+func NewRegistry(interval time.Duration, suspectAfter, downAfter int) *Registry {
+	if suspectAfter < 1 {
+		suspectAfter = 1
+	}
+	if downAfter <= suspectAfter {
+		downAfter = suspectAfter + 1
+	}
+	return &Registry{
+		interval:     interval,
+		suspectAfter: suspectAfter,
+		downAfter:    downAfter,
+		agents:       make(map[string]*Agent),
+	}
+}
+
+// Observe records a heartbeat from service@host.
+// It returns a transition if the agent is new or came back from SUSPECT/DOWN.
+func (r *Registry) Observe(service, host string, now time.Time) *Transition {
+	key := service + "@" + host
+	a, ok := r.agents[key]
+	if !ok {
+		a = &Agent{Service: service, Host: host, LastSeen: now, State: StateAlive}
+		r.agents[key] = a
+		return &Transition{Agent: *a, To: StateAlive}
+	}
+
+	prev := a.State
+	missed := r.missed(a.LastSeen, now)
+	a.LastSeen = now
+	a.State = StateAlive
+	if prev == StateAlive {
+		return nil
+	}
+	return &Transition{Agent: *a, From: prev, To: StateAlive, Missed: missed}
+}
+
+// Sweep re-evaluates every agent against now and returns the resulting
+// transitions, ordered by agent key.
+func (r *Registry) Sweep(now time.Time) []Transition {
+	var out []Transition
+	for _, a := range r.agents {
+		missed := r.missed(a.LastSeen, now)
+
+		next := StateAlive
+		switch {
+		case missed >= r.downAfter:
+			next = StateDown
+		case missed >= r.suspectAfter:
+			next = StateSuspect
+		}
+
+		if next == a.State || next == StateAlive {
+			// Recovery only happens through Observe.
+			continue
+		}
+		prev := a.State
+		a.State = next
+		out = append(out, Transition{Agent: *a, From: prev, To: next, Missed: missed})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Agent.Key() < out[j].Agent.Key() })
+	return out
+}
+
+// Snapshot returns a copy of all agents, ordered by key.
+func (r *Registry) Snapshot() []Agent {
+	out := make([]Agent, 0, len(r.agents))
+	for _, a := range r.agents {
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key() < out[j].Key() })
+	return out
+}
+
+func (r *Registry) missed(lastSeen, now time.Time) int {
+	if r.interval <= 0 {
+		return 0
+	}
+	return int(now.Sub(lastSeen) / r.interval)
+}
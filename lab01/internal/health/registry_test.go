@@ -0,0 +1,103 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+const interval = time.Second
+
+var t0 = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestRegistryTransitions(t *testing.T) {
+	r := NewRegistry(interval, 2, 4)
+
+	if tr := r.Observe("api", "h1", t0); tr == nil || tr.From != "" || tr.To != StateAlive {
+		t.Fatalf("first heartbeat = %+v, want a new ALIVE agent", tr)
+	}
+
+	steps := []struct {
+		name   string
+		at     time.Duration
+		beat   bool // Observe instead of Sweep
+		from   State
+		to     State // empty = no transition
+		missed int
+	}{
+		{"heartbeat while alive", 1 * time.Second, true, "", "", 0},
+		{"one interval missed", 2 * time.Second, false, "", "", 0},
+		{"suspect", 3 * time.Second, false, StateAlive, StateSuspect, 2},
+		{"still suspect", 4 * time.Second, false, "", "", 0},
+		{"down", 5 * time.Second, false, StateSuspect, StateDown, 4},
+		{"stays down", time.Minute, false, "", "", 0},
+		{"back", time.Minute + time.Second, true, StateDown, StateAlive, 60},
+		{"straight to down", time.Minute + 6*time.Second, false, StateAlive, StateDown, 5},
+	}
+	for _, s := range steps {
+		t.Run(s.name, func(t *testing.T) {
+			now := t0.Add(s.at)
+			var got []Transition
+			if s.beat {
+				if tr := r.Observe("api", "h1", now); tr != nil {
+					got = append(got, *tr)
+				}
+			} else {
+				got = r.Sweep(now)
+			}
+			if s.to == "" {
+				if len(got) != 0 {
+					t.Errorf("transitions %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].From != s.from || got[0].To != s.to || got[0].Missed != s.missed {
+				t.Errorf("transitions %+v, want %s -> %s after %d missed", got, s.from, s.to, s.missed)
+			}
+		})
+	}
+}
+
+func TestRegistrySnapshot(t *testing.T) {
+	r := NewRegistry(interval, 1, 3)
+	r.Observe("web", "h2", t0)
+	r.Observe("api", "h1", t0)
+	r.Observe("web", "h1", t0.Add(2*time.Second))
+
+	if got := r.Sweep(t0.Add(2 * time.Second)); len(got) != 2 || got[0].Agent.Key() != "api@h1" || got[1].Agent.Key() != "web@h2" {
+		t.Errorf("Sweep = %+v, want api@h1 and web@h2 in key order", got)
+	}
+
+	want := []struct {
+		key   string
+		state State
+	}{{"api@h1", StateSuspect}, {"web@h1", StateAlive}, {"web@h2", StateSuspect}}
+	got := r.Snapshot()
+	if len(got) != len(want) {
+		t.Fatalf("Snapshot has %d agents, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Key() != w.key || got[i].State != w.state {
+			t.Errorf("agent %d = %s %s, want %s %s", i, got[i].Key(), got[i].State, w.key, w.state)
+		}
+	}
+}
+
+func TestNewRegistryThresholds(t *testing.T) {
+	cases := []struct {
+		name                  string
+		suspect, down         int
+		wantSuspect, wantDown int
+	}{
+		{"as given", 2, 4, 2, 4},
+		{"suspect at least one", 0, 3, 1, 3},
+		{"down after suspect", 3, 3, 3, 4},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRegistry(interval, tc.suspect, tc.down)
+			if r.suspectAfter != tc.wantSuspect || r.downAfter != tc.wantDown {
+				t.Errorf("thresholds %d/%d, want %d/%d", r.suspectAfter, r.downAfter, tc.wantSuspect, tc.wantDown)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Wire layout
+//
+//	Legacy:  [Topic] [JSON Payload]
+//	Current: [Topic] [Codec "name/version"] [Payload]
+//
+// The codec frame lets the dashboard decode JSON and binary agents side by side
+// during a rollout. Dashboards that predate it only read the legacy layout, so
+// agents keep sending JSON in 2 frames until told otherwise; upgrade dashboards
+// before switching agents to 3 frames or to the binary codec.
+
+var (
+	ErrUnknownCodec       = errors.New("protocol: unknown codec")
+	ErrUnsupportedVersion = errors.New("protocol: unsupported codec version")
+	ErrMalformed          = errors.New("protocol: malformed payload")
+)
+
+// Codec encodes and decodes Metrics for one wire format version.
+type Codec interface {
+	Name() string
+	Version() int
+	Marshal(m *Metric) ([]byte, error)
+	Unmarshal(data []byte) (*Metric, error)
+}
+
+// CodecFrame returns the "name/version" frame announcing c.
+func CodecFrame(c Codec) []byte {
+	return []byte(c.Name() + "/" + strconv.Itoa(c.Version()))
+}
+
+// codecs lists every codec the dashboard understands.
+var codecs = []Codec{JSONCodec{}, BinaryCodec{}}
+
+// LookupCodec returns the codec for a bare name (newest version) or a "name/version" frame.
+func LookupCodec(spec string) (Codec, error) {
+	name, ver, hasVer := strings.Cut(spec, "/")
+	var found Codec
+	known := false
+	for _, c := range codecs {
+		if c.Name() != name {
+			continue
+		}
+		known = true
+		if hasVer {
+			if strconv.Itoa(c.Version()) == ver {
+				return c, nil
+			}
+			continue
+		}
+		if found == nil || c.Version() > found.Version() {
+			found = c
+		}
+	}
+	switch {
+	case found != nil:
+		return found, nil
+	case known:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, spec)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, spec)
+	}
+}
+
+// EncodeFrames builds [Topic] [Codec] [Payload] for m.
+func EncodeFrames(topic string, m *Metric, c Codec) ([][]byte, error) {
+	payload, err := c.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{[]byte(topic), CodecFrame(c), payload}, nil
+}
+
+// EncodeLegacyFrames builds the legacy [Topic] [JSON Payload] for m.
+func EncodeLegacyFrames(topic string, m *Metric) ([][]byte, error) {
+	payload, err := m.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{[]byte(topic), payload}, nil
+}
+
+// DecodeFrames decodes a full message, accepting both the legacy 2-frame
+// JSON layout and the 3-frame codec layout.
+func DecodeFrames(frames [][]byte) (*Metric, error) {
+	switch len(frames) {
+	case 0, 1:
+		return nil, fmt.Errorf("%w: %d frames", ErrMalformed, len(frames))
+	case 2:
+		return FromJSON(frames[1])
+	default:
+		c, err := LookupCodec(string(frames[1]))
+		if err != nil {
+			return nil, err
+		}
+		return c.Unmarshal(frames[2])
+	}
+}
+
+// JSONCodec is the original JSON encoding.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return "json" }
+func (JSONCodec) Version() int { return 1 }
+
+func (JSONCodec) Marshal(m *Metric) ([]byte, error) {
+	return m.ToJSON()
+}
+
+func (JSONCodec) Unmarshal(data []byte) (*Metric, error) {
+	return FromJSON(data)
+}
+
+// BinaryCodec is a compact encoding: a fixed header followed by varint fields.
+//
+//	Header:  'M' 'B' version(1) flags(1)
+//	Body:    timestamp (varint unix nanos)
+//	         service, host, status, kind (uvarint length + bytes)
+//	         cpu, memory (8 byte little-endian float64)
+//	         values count (uvarint), then per value: key (uvarint length + bytes), float64
+//
+// Values are written in key order so the encoding is deterministic.
+type BinaryCodec struct{}
+
+const (
+	binaryMagic0      = 'M'
+	binaryMagic1      = 'B'
+	binaryVersion     = 1
+	binaryHeaderSize  = 4
+	binaryFlagNoValue = 0x01 // Values is nil (legacy-style metric)
+)
+
+func (BinaryCodec) Name() string { return "binary" }
+func (BinaryCodec) Version() int { return binaryVersion }
+
+func (BinaryCodec) Marshal(m *Metric) ([]byte, error) {
+	var flags byte
+	if m.Values == nil {
+		flags |= binaryFlagNoValue
+	}
+
+	buf := make([]byte, 0, 64+len(m.Service)+len(m.Host)+len(m.Kind)+len(m.Values)*16)
+	buf = append(buf, binaryMagic0, binaryMagic1, binaryVersion, flags)
+	buf = binary.AppendVarint(buf, m.Timestamp.UnixNano())
+	buf = appendString(buf, m.Service)
+	buf = appendString(buf, m.Host)
+	buf = appendString(buf, m.Status)
+	buf = appendString(buf, m.Kind)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(m.CPU))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(m.Memory))
+
+	keys := make([]string, 0, len(m.Values))
+	for k := range m.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf = binary.AppendUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		buf = appendString(buf, k)
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(m.Values[k]))
+	}
+	return buf, nil
+}
+
+func (BinaryCodec) Unmarshal(data []byte) (*Metric, error) {
+	if len(data) < binaryHeaderSize || data[0] != binaryMagic0 || data[1] != binaryMagic1 {
+		return nil, fmt.Errorf("%w: bad binary header", ErrMalformed)
+	}
+	if data[2] != binaryVersion {
+		return nil, fmt.Errorf("%w: binary/%d", ErrUnsupportedVersion, data[2])
+	}
+	flags := data[3]
+	r := reader{buf: data[binaryHeaderSize:]}
+
+	var m Metric
+	m.Timestamp = time.Unix(0, r.varint())
+	m.Service = r.string()
+	m.Host = r.string()
+	m.Status = r.string()
+	m.Kind = r.string()
+	m.CPU = r.float64()
+	m.Memory = r.float64()
+
+	n := r.uvarint()
+	if r.err == nil && n > uint64(len(r.buf)) {
+		// Every value needs more than one byte; reject absurd counts early.
+		r.err = ErrMalformed
+	}
+	if flags&binaryFlagNoValue == 0 {
+		m.Values = make(map[string]float64, n)
+	}
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		k := r.string()
+		v := r.float64()
+		if m.Values != nil {
+			m.Values[k] = v
+		}
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, r.err)
+	}
+	if len(r.buf) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrMalformed, len(r.buf))
+	}
+	return &m, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// reader consumes binary fields and remembers the first error.
+type reader struct {
+	buf []byte
+	err error
+}
+
+var errTruncated = errors.New("truncated")
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = errTruncated
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *reader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.err = errTruncated
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *reader) string() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+	if n > uint64(len(r.buf)) {
+		r.err = errTruncated
+		return ""
+	}
+	s := string(r.buf[:n])
+	r.buf = r.buf[n:]
+	return s
+}
+
+func (r *reader) float64() float64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 8 {
+		r.err = errTruncated
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.buf))
+	r.buf = r.buf[8:]
+	return v
+}
@@ -0,0 +1,176 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func sampleMetrics() map[string]*Metric {
+	ts := time.Date(2024, 5, 17, 12, 30, 45, 123456789, time.UTC)
+	return map[string]*Metric{
+		"legacy": {
+			Timestamp: ts,
+			Service:   "monitor-agent-01",
+			Host:      "node-1",
+			CPU:       42.5,
+			Memory:    8192.25,
+			Status:    "OK",
+		},
+		"collector": {
+			Timestamp: ts,
+			Service:   "monitor-agent-01",
+			Host:      "node-2",
+			Status:    "OK",
+			Kind:      "memory",
+			Memory:    1234.5,
+			Values: map[string]float64{
+				"total_mb":     16384,
+				"used_mb":      1234.5,
+				"used_percent": 7.53,
+			},
+		},
+		"empty values": {
+			Timestamp: ts,
+			Service:   "svc",
+			Host:      "h",
+			Status:    "ERROR",
+			Kind:      "load",
+			Values:    map[string]float64{},
+		},
+	}
+}
+
+// assertMetricEqual compares metrics, treating timestamps by instant.
+func assertMetricEqual(t *testing.T, want, got *Metric) {
+	t.Helper()
+	if !want.Timestamp.Equal(got.Timestamp) {
+		t.Errorf("timestamp: want %v, got %v", want.Timestamp, got.Timestamp)
+	}
+	w, g := *want, *got
+	w.Timestamp, g.Timestamp = time.Time{}, time.Time{}
+	if len(w.Values) == 0 && len(g.Values) == 0 {
+		w.Values, g.Values = nil, nil
+	}
+	if !reflect.DeepEqual(w, g) {
+		t.Errorf("metric mismatch:\nwant %+v\n got %+v", w, g)
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, c := range []Codec{JSONCodec{}, BinaryCodec{}} {
+		for name, m := range sampleMetrics() {
+			t.Run(c.Name()+"/"+name, func(t *testing.T) {
+				data, err := c.Marshal(m)
+				if err != nil {
+					t.Fatalf("marshal: %v", err)
+				}
+				got, err := c.Unmarshal(data)
+				if err != nil {
+					t.Fatalf("unmarshal: %v", err)
+				}
+				assertMetricEqual(t, m, got)
+			})
+		}
+	}
+}
+
+func TestBinaryIsDeterministicAndSmaller(t *testing.T) {
+	m := sampleMetrics()["collector"]
+
+	a, _ := BinaryCodec{}.Marshal(m)
+	b, _ := BinaryCodec{}.Marshal(m)
+	if !bytes.Equal(a, b) {
+		t.Fatal("binary encoding is not deterministic")
+	}
+
+	j, _ := JSONCodec{}.Marshal(m)
+	if len(a) >= len(j) {
+		t.Errorf("binary (%d bytes) not smaller than JSON (%d bytes)", len(a), len(j))
+	}
+}
+
+func TestDecodeFramesCompatibility(t *testing.T) {
+	m := sampleMetrics()["collector"]
+	legacyPayload, _ := m.ToJSON()
+
+	jsonFrames, err := EncodeFrames("metrics.memory.node-2", m, JSONCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	binFrames, err := EncodeFrames("metrics.memory.node-2", m, BinaryCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyFrames, err := EncodeLegacyFrames("metrics.memory.node-2", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][][]byte{
+		"legacy 2-frame json":  {[]byte("metrics"), legacyPayload},
+		"legacy agent encoder": legacyFrames,
+		"3-frame json":         jsonFrames,
+		"3-frame binary":       binFrames,
+	}
+	for name, frames := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := DecodeFrames(frames)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			assertMetricEqual(t, m, got)
+		})
+	}
+
+	if len(legacyFrames) != 2 || !bytes.Equal(legacyFrames[1], legacyPayload) {
+		t.Errorf("legacy encoder sent %d frames, want [topic, json]", len(legacyFrames))
+	}
+	if string(jsonFrames[1]) != "json/1" || string(binFrames[1]) != "binary/1" {
+		t.Errorf("unexpected codec frames %q, %q", jsonFrames[1], binFrames[1])
+	}
+}
+
+func TestDecodeFramesErrors(t *testing.T) {
+	m := sampleMetrics()["legacy"]
+	payload, _ := BinaryCodec{}.Marshal(m)
+
+	futureVersion := append([]byte(nil), payload...)
+	futureVersion[2] = 99
+
+	cases := []struct {
+		name   string
+		frames [][]byte
+		want   error
+	}{
+		{"too few frames", [][]byte{[]byte("metrics")}, ErrMalformed},
+		{"unknown codec", [][]byte{[]byte("t"), []byte("protobuf/1"), payload}, ErrUnknownCodec},
+		{"unsupported frame version", [][]byte{[]byte("t"), []byte("binary/2"), payload}, ErrUnsupportedVersion},
+		{"unsupported payload version", [][]byte{[]byte("t"), []byte("binary/1"), futureVersion}, ErrUnsupportedVersion},
+		{"truncated", [][]byte{[]byte("t"), []byte("binary/1"), payload[:len(payload)-3]}, ErrMalformed},
+		{"trailing bytes", [][]byte{[]byte("t"), []byte("binary/1"), append(payload, 0)}, ErrMalformed},
+		{"bad magic", [][]byte{[]byte("t"), []byte("binary/1"), []byte("{}")}, ErrMalformed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeFrames(tc.frames)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("want %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestLookupCodec(t *testing.T) {
+	for spec, want := range map[string]string{"json": "json/1", "binary": "binary/1", "binary/1": "binary/1"} {
+		c, err := LookupCodec(spec)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if got := string(CodecFrame(c)); got != want {
+			t.Errorf("%s: want %s, got %s", spec, want, got)
+		}
+	}
+}
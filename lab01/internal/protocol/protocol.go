@@ -13,6 +13,11 @@ type Metric struct {
 	CPU       float64   `json:"cpu"`
 	Memory    float64   `json:"memory"`
 	Status    string    `json:"status"`
+
+	// Kind names the collector that produced the sample ("cpu", "memory", ...).
+	// Legacy agents leave it empty and only fill CPU and Memory.
+	Kind   string             `json:"kind,omitempty"`
+	Values map[string]float64 `json:"values,omitempty"`
 }
 
 // ToJSON serializes the metric to JSON bytes.
@@ -20,6 +25,19 @@ func (m *Metric) ToJSON() ([]byte, error) {
 	return json.Marshal(m)
 }
 
+// Fields flattens the metric into named values.
+// Legacy metrics yield "cpu" and "memory"; collector metrics yield "<kind>.<value>".
+func (m *Metric) Fields() map[string]float64 {
+	if m.Kind == "" {
+		return map[string]float64{"cpu": m.CPU, "memory": m.Memory}
+	}
+	out := make(map[string]float64, len(m.Values))
+	for k, v := range m.Values {
+		out[m.Kind+"."+k] = v
+	}
+	return out
+}
+
 // FromJSON deserializes JSON bytes to a Metric.
 func FromJSON(data []byte) (*Metric, error) {
 	var m Metric
@@ -29,3 +47,65 @@ func FromJSON(data []byte) (*Metric, error) {
 	}
 	return &m, nil
 }
+
+// EventType identifies a liveness transition published by the dashboard.
+type EventType string
+
+const (
+	EventJoined    EventType = "JOINED"
+	EventSuspect   EventType = "SUSPECT"
+	EventDown      EventType = "DOWN"
+	EventRecovered EventType = "RECOVERED"
+)
+
+// EventTopicPrefix is the topic prefix for liveness events ("events.DOWN", ...).
+const EventTopicPrefix = "events."
+
+// HostEvent is published on the events endpoint whenever an agent changes state.
+type HostEvent struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Type          EventType `json:"type"`
+	Service       string    `json:"service"`
+	Host          string    `json:"host"`
+	PreviousState string    `json:"previous_state,omitempty"`
+	State         string    `json:"state"`
+	LastSeen      time.Time `json:"last_seen"`
+	Missed        int       `json:"missed_intervals"`
+}
+
+// Topic returns the PUB topic for the event.
+func (e *HostEvent) Topic() string {
+	return EventTopicPrefix + string(e.Type)
+}
+
+// ToJSON serializes the event to JSON bytes.
+func (e *HostEvent) ToJSON() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// AlertState is the lifecycle state carried by an Alert.
+type AlertState string
+
+const (
+	AlertRaised  AlertState = "RAISED"
+	AlertCleared AlertState = "CLEARED"
+)
+
+// Alert is pushed by the dashboard when a threshold rule raises or clears.
+type Alert struct {
+	Timestamp time.Time  `json:"timestamp"`
+	Rule      string     `json:"rule"`
+	State     AlertState `json:"state"`
+	Severity  string     `json:"severity,omitempty"`
+	Service   string     `json:"service"`
+	Host      string     `json:"host"`
+	Field     string     `json:"field"`
+	Value     float64    `json:"value"`
+	Threshold float64    `json:"threshold"`
+	Samples   int        `json:"samples"` // consecutive samples that triggered the change
+}
+
+// ToJSON serializes the alert to JSON bytes.
+func (a *Alert) ToJSON() ([]byte, error) {
+	return json.Marshal(a)
+}
@@ -0,0 +1,106 @@
+package tsdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricPrefix is prepended to every exported Prometheus metric name.
+const MetricPrefix = "lab01_"
+
+// Handler returns an HTTP handler serving:
+//
+//	GET /api/query?host=&field=&from=&to=   JSON points (times in RFC3339 or unix seconds)
+//	GET /api/hosts                          JSON list of known hosts
+//	GET /metrics                            Prometheus text exposition of the latest values
+func Handler(s *Store) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/query", func(w http.ResponseWriter, r *http.Request) {
+		q := Query{
+			Host:  r.URL.Query().Get("host"),
+			Field: r.URL.Query().Get("field"),
+		}
+		var err error
+		if q.From, err = parseTime(r.URL.Query().Get("from")); err != nil {
+			http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if q.To, err = parseTime(r.URL.Query().Get("to")); err != nil {
+			http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		points := s.Query(q)
+		if points == nil {
+			points = []Point{}
+		}
+		writeJSON(w, points)
+	})
+
+	mux.HandleFunc("/api/hosts", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.Hosts())
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writePrometheus(w, s)
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writePrometheus renders the latest value of each series as a gauge.
+// Points from Latest are sorted by field, so each metric family is contiguous.
+func writePrometheus(w http.ResponseWriter, s *Store) {
+	lastName := ""
+	for _, p := range s.Latest() {
+		name := MetricPrefix + sanitizeName(p.Field)
+		if name != lastName {
+			fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+			lastName = name
+		}
+		fmt.Fprintf(w, "%s{host=\"%s\",service=\"%s\"} %g %d\n",
+			name, escapeLabel(p.Host), escapeLabel(p.Service), p.Value, p.Timestamp.UnixMilli())
+	}
+
+	fmt.Fprintf(w, "# TYPE %sstored_samples gauge\n", MetricPrefix)
+	fmt.Fprintf(w, "%sstored_samples %d\n", MetricPrefix, s.Len())
+}
+
+// sanitizeName maps a field name onto the Prometheus name charset [a-zA-Z0-9_].
+func sanitizeName(field string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, field)
+}
+
+func escapeLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	return strings.ReplaceAll(v, "\n", `\n`)
+}
+
+// parseTime accepts RFC3339 or unix seconds; empty means "unbounded".
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
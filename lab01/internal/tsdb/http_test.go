@@ -0,0 +1,46 @@
+package tsdb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	s := NewStore(10)
+	s.Append(metric("h1", 0, 10))
+	s.Append(metric("h1", 1, 11))
+	h := Handler(s)
+
+	cases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/api/query?host=h1&field=cpu&from=1704110401", http.StatusOK, `"value":11`},
+		{"/api/query?host=nobody", http.StatusOK, "[]"},
+		{"/api/query?from=yesterday", http.StatusBadRequest, "invalid from"},
+		{"/api/hosts", http.StatusOK, `["h1"]`},
+		{"/metrics", http.StatusOK, "# TYPE lab01_cpu gauge\nlab01_cpu{host=\"h1\",service=\"agent\"} 11 1704110401000\n"},
+		{"/metrics", http.StatusOK, "lab01_stored_samples 2\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.status || !strings.Contains(rec.Body.String(), tc.body) {
+				t.Errorf("GET %s = %d %q, want %d containing %q", tc.path, rec.Code, rec.Body.String(), tc.status, tc.body)
+			}
+		})
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	if got := sanitizeName("disk.used-pct"); got != "disk_used_pct" {
+		t.Errorf("sanitizeName = %q", got)
+	}
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("escapeLabel = %q", got)
+	}
+}
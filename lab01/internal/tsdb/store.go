@@ -0,0 +1,182 @@
+package tsdb
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"gemini-zeromq-labs/lab01/internal/protocol"
+)
+
+// Point is a single field value extracted from a Metric.
+type Point struct {
+	Timestamp time.Time `json:"timestamp"`
+	Service   string    `json:"service"`
+	Host      string    `json:"host"`
+	Field     string    `json:"field"`
+	Value     float64   `json:"value"`
+}
+
+// Query filters points. Empty strings and zero times match everything.
+type Query struct {
+	Host  string
+	Field string
+	From  time.Time
+	To    time.Time
+}
+
+// Store keeps a bounded ring buffer of samples per host.
+// It is safe for concurrent use: the dashboard writes, the HTTP server reads.
+type Store struct {
+	mu       sync.RWMutex
+	capacity int
+	hosts    map[string]*ring
+}
+
+// NewStore creates a store that keeps at most capacity samples per host.
+func NewStore(capacity int) *Store {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Store{capacity: capacity, hosts: make(map[string]*ring)}
+}
+
+// Append stores a copy of m, evicting the oldest sample of the host when full.
+func (s *Store) Append(m protocol.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.hosts[m.Host]
+	if !ok {
+		r = &ring{buf: make([]protocol.Metric, s.capacity)}
+		s.hosts[m.Host] = r
+	}
+	r.push(m)
+}
+
+// Hosts returns the known hosts in alphabetical order.
+func (s *Store) Hosts() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]string, 0, len(s.hosts))
+	for h := range s.hosts {
+		out = append(out, h)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Len returns the total number of stored samples.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, r := range s.hosts {
+		n += r.len
+	}
+	return n
+}
+
+// Query returns matching points ordered by host, then time.
+func (s *Store) Query(q Query) []Point {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []Point
+	for _, host := range s.sortedHostsLocked() {
+		if q.Host != "" && q.Host != host {
+			continue
+		}
+		s.hosts[host].each(func(m *protocol.Metric) {
+			if !q.From.IsZero() && m.Timestamp.Before(q.From) {
+				return
+			}
+			if !q.To.IsZero() && m.Timestamp.After(q.To) {
+				return
+			}
+			for field, v := range m.Fields() {
+				if q.Field != "" && q.Field != field {
+					continue
+				}
+				out = append(out, Point{Timestamp: m.Timestamp, Service: m.Service, Host: m.Host, Field: field, Value: v})
+			}
+		})
+	}
+	// Fields come from a map; make the order within a sample stable.
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Host != out[j].Host {
+			return out[i].Host < out[j].Host
+		}
+		if !out[i].Timestamp.Equal(out[j].Timestamp) {
+			return out[i].Timestamp.Before(out[j].Timestamp)
+		}
+		return out[i].Field < out[j].Field
+	})
+	return out
+}
+
+// Latest returns the most recent value of every (service, host, field) series.
+func (s *Store) Latest() []Point {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type key struct{ service, host, field string }
+	latest := make(map[key]Point)
+	for _, r := range s.hosts {
+		r.each(func(m *protocol.Metric) {
+			for field, v := range m.Fields() {
+				// Samples are visited oldest first, so later ones win.
+				latest[key{m.Service, m.Host, field}] = Point{Timestamp: m.Timestamp, Service: m.Service, Host: m.Host, Field: field, Value: v}
+			}
+		})
+	}
+
+	out := make([]Point, 0, len(latest))
+	for _, p := range latest {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Field != out[j].Field {
+			return out[i].Field < out[j].Field
+		}
+		if out[i].Host != out[j].Host {
+			return out[i].Host < out[j].Host
+		}
+		return out[i].Service < out[j].Service
+	})
+	return out
+}
+
+func (s *Store) sortedHostsLocked() []string {
+	out := make([]string, 0, len(s.hosts))
+	for h := range s.hosts {
+		out = append(out, h)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ring is a fixed-size circular buffer of metrics.
+type ring struct {
+	buf  []protocol.Metric
+	head int // next write position
+	len  int
+}
+
+func (r *ring) push(m protocol.Metric) {
+	r.buf[r.head] = m
+	r.head = (r.head + 1) % len(r.buf)
+	if r.len < len(r.buf) {
+		r.len++
+	}
+}
+
+// each visits the stored metrics from oldest to newest.
+func (r *ring) each(fn func(m *protocol.Metric)) {
+	start := (r.head - r.len + len(r.buf)) % len(r.buf)
+	for i := 0; i < r.len; i++ {
+		fn(&r.buf[(start+i)%len(r.buf)])
+	}
+}
@@ -0,0 +1,106 @@
+package tsdb
+
+import (
+	"testing"
+	"time"
+
+	"gemini-zeromq-labs/lab01/internal/protocol"
+)
+
+var t0 = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func metric(host string, sec int, cpu float64) protocol.Metric {
+	return protocol.Metric{Timestamp: t0.Add(time.Duration(sec) * time.Second), Service: "agent", Host: host, CPU: cpu, Memory: 100}
+}
+
+func TestStoreRetention(t *testing.T) {
+	s := NewStore(3)
+	for i := range 5 {
+		s.Append(metric("h1", i, float64(i)))
+	}
+	s.Append(metric("h2", 0, 50))
+
+	if s.Len() != 4 {
+		t.Errorf("Len() = %d, want 3 for h1 plus 1 for h2", s.Len())
+	}
+	var got []float64
+	for _, p := range s.Query(Query{Host: "h1", Field: "cpu"}) {
+		got = append(got, p.Value)
+	}
+	if len(got) != 3 || got[0] != 2 || got[1] != 3 || got[2] != 4 {
+		t.Errorf("h1 cpu = %v, want the newest three [2 3 4]", got)
+	}
+
+	if NewStore(0).capacity != 1 {
+		t.Error("a capacity below 1 is not raised to 1")
+	}
+}
+
+func TestStoreQuery(t *testing.T) {
+	s := NewStore(10)
+	s.Append(metric("h2", 0, 20))
+	s.Append(metric("h1", 0, 10))
+	s.Append(metric("h1", 1, 11))
+	s.Append(metric("h1", 2, 12))
+	s.Append(protocol.Metric{Timestamp: t0, Service: "agent", Host: "h1", Kind: "disk", Values: map[string]float64{"used": 70}})
+
+	cases := []struct {
+		name string
+		q    Query
+		want []string // host/field@second
+	}{
+		{"everything", Query{}, []string{
+			"h1/cpu@0", "h1/disk.used@0", "h1/memory@0", "h1/cpu@1", "h1/memory@1", "h1/cpu@2", "h1/memory@2",
+			"h2/cpu@0", "h2/memory@0",
+		}},
+		{"host", Query{Host: "h2"}, []string{"h2/cpu@0", "h2/memory@0"}},
+		{"field", Query{Field: "cpu"}, []string{"h1/cpu@0", "h1/cpu@1", "h1/cpu@2", "h2/cpu@0"}},
+		{"collector field", Query{Field: "disk.used"}, []string{"h1/disk.used@0"}},
+		{"time range", Query{Host: "h1", Field: "cpu", From: t0.Add(time.Second), To: t0.Add(time.Second)}, []string{"h1/cpu@1"}},
+		{"unknown host", Query{Host: "h3"}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, p := range s.Query(tc.q) {
+				got = append(got, p.Host+"/"+p.Field+"@"+p.Timestamp.Format("5"))
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("Query = %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("Query = %v, want %v", got, tc.want)
+					break
+				}
+			}
+		})
+	}
+
+	if hosts := s.Hosts(); len(hosts) != 2 || hosts[0] != "h1" || hosts[1] != "h2" {
+		t.Errorf("Hosts() = %v, want [h1 h2]", hosts)
+	}
+}
+
+func TestStoreLatest(t *testing.T) {
+	s := NewStore(2)
+	s.Append(metric("h1", 0, 10))
+	s.Append(metric("h1", 1, 11))
+	s.Append(metric("h2", 0, 20))
+
+	got := s.Latest()
+	want := []Point{
+		{Host: "h1", Field: "cpu", Value: 11},
+		{Host: "h2", Field: "cpu", Value: 20},
+		{Host: "h1", Field: "memory", Value: 100},
+		{Host: "h2", Field: "memory", Value: 100},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Latest() = %+v, want %d points", got, len(want))
+	}
+	for i, w := range want {
+		if got[i].Host != w.Host || got[i].Field != w.Field || got[i].Value != w.Value {
+			t.Errorf("point %d = %s/%s %g, want %s/%s %g", i, got[i].Host, got[i].Field, got[i].Value, w.Host, w.Field, w.Value)
+		}
+	}
+}
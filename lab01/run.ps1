@@ -6,7 +6,7 @@ go mod tidy
 ./build.ps1
 
 Write-Host "Starting Dashboard..."
-Start-Process ".\dashboard.exe" -NoNewWindow
+Start-Process ".\dashboard.exe" -ArgumentList "-alert-rules", "alert_rules.json" -NoNewWindow
 
 Write-Host "Starting Monitor Agent..."
 Start-Process ".\monitor_agent.exe" -NoNewWindow
@@ -14,4 +14,10 @@ if ($LASTEXITCODE -ne 0) { Write-Error "Build log_parser failed"; exit 1 }
 go build -o storage_writer.exe ./cmd/storage_writer
 if ($LASTEXITCODE -ne 0) { Write-Error "Build storage_writer failed"; exit 1 }
 
+go build -o log_reader.exe ./cmd/log_reader
+if ($LASTEXITCODE -ne 0) { Write-Error "Build log_reader failed"; exit 1 }
+
+go build -o dlq_consumer.exe ./cmd/dlq_consumer
+if ($LASTEXITCODE -ne 0) { Write-Error "Build dlq_consumer failed"; exit 1 }
+
 Write-Host "Build complete." -ForegroundColor Green
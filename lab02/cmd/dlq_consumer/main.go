@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/config"
+	"gemini-zeromq-labs/lab02/internal/dlq"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+func main() {
+	// Mode flags are registered before LoadConfig, which parses the shared flag set
+	list := flag.Bool("list", false, "Print the stored dead letters and exit")
+	reinject := flag.Bool("reinject", false, "Re-inject stored entries into the Collector's reinject source and exit")
+	fixedOnly := flag.Bool("fixed-only", false, "With -reinject, only send records that have a 'fixed' entry")
+
+	cfg := config.LoadConfig()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	switch {
+	case *list:
+		if err := listRecords(cfg.DLQFile); err != nil {
+			logger.Error("List failed", "file", cfg.DLQFile, "error", err)
+			os.Exit(1)
+		}
+	case *reinject:
+		if err := reinjectRecords(cfg, *fixedOnly, logger); err != nil {
+			logger.Error("Reinject failed", "file", cfg.DLQFile, "error", err)
+			os.Exit(1)
+		}
+	default:
+		serve(cfg, logger)
+	}
+}
+
+// serve receives dead letters from the Workers and appends them to the DLQ file.
+func serve(cfg *config.Config, logger *slog.Logger) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-c
+		logger.Info("Interrupt received, shutting down...")
+		cancel()
+	}()
+
+	store, err := dlq.OpenAppender(cfg.DLQFile)
+	if err != nil {
+		logger.Error("Failed to open DLQ file", "file", cfg.DLQFile, "error", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	pull := zmq4.NewPull(ctx)
+	defer pull.Close()
+
+	bindAddr := cfg.DLQBindAddr()
+	logger.Info("DLQ consumer binding", "endpoint", bindAddr, "file", cfg.DLQFile)
+	if err := pull.Listen(bindAddr); err != nil {
+		logger.Error("Failed to bind DLQ", "error", err)
+		os.Exit(1)
+	}
+
+	count := 0
+	for {
+		msg, err := pull.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			logger.Error("DLQ error receiving", "error", err)
+			continue
+		}
+
+		var rec dlq.Record
+		if err := json.Unmarshal(msg.Bytes(), &rec.DeadLetter); err != nil {
+			logger.Error("DLQ error unmarshalling", "error", err)
+			continue
+		}
+		if err := store.Append(rec); err != nil {
+			logger.Error("DLQ error storing", "original_id", rec.OriginalID, "error", err)
+			continue
+		}
+		count++
+		logger.Info("Dead letter stored",
+			"stage", rec.Stage,
+			"worker", rec.WorkerID,
+			"original_id", rec.OriginalID,
+			"batch_id", rec.BatchID,
+			"error", rec.Error,
+		)
+	}
+
+	logger.Info("DLQ consumer finished", "stored", count)
+}
+
+// listRecords prints one line per stored dead letter.
+func listRecords(path string) error {
+	records, err := dlq.Load(path)
+	if err != nil {
+		return err
+	}
+	for i, r := range records {
+		fixed := ""
+		if len(r.Fixed) > 0 {
+			fixed = " [fixed]"
+		}
+		fmt.Printf("%3d [%s] %-8s %s %s: %s%s\n",
+			i, r.Timestamp.Format(time.RFC3339), r.Stage, r.WorkerID, orDash(r.OriginalID), r.Error, fixed)
+	}
+	fmt.Printf("%d dead letters in %s\n", len(records), path)
+	return nil
+}
+
+// reinjectRecords pushes every record that now yields a valid LogEntry to the
+// Collector and marks the sent ones as re-injected. The DLQ file itself is
+// left alone, so a consumer serving it can keep appending meanwhile.
+func reinjectRecords(cfg *config.Config, fixedOnly bool, logger *slog.Logger) error {
+	records, err := dlq.Load(cfg.DLQFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	push := zmq4.NewPush(ctx)
+	defer push.Close()
+
+	endpoint := cfg.ReinjectConnectAddr()
+	logger.Info("Connecting to collector reinject source", "endpoint", endpoint)
+	if err := push.Dial(endpoint); err != nil {
+		return err
+	}
+
+	var sent []dlq.Record
+	kept := 0
+	for _, r := range records {
+		if fixedOnly && len(r.Fixed) == 0 {
+			kept++
+			continue
+		}
+		entry, err := r.Entry()
+		if err != nil {
+			logger.Warn("Still broken, keeping", "original_id", r.OriginalID, "error", err)
+			kept++
+			continue
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := push.Send(zmq4.NewMsg(data)); err != nil {
+			// Keep the rest so nothing is lost
+			logger.Error("Failed to reinject", "original_id", entry.ID, "error", err)
+			kept++
+			continue
+		}
+		sent = append(sent, r)
+		logger.Info("Re-injected", "original_id", entry.ID)
+	}
+
+	// Give time for packets to flush out before closing
+	time.Sleep(1 * time.Second)
+
+	if err := dlq.MarkReinjected(cfg.DLQFile, sent); err != nil {
+		return err
+	}
+	logger.Info("Reinject finished", "sent", len(sent), "kept", kept)
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -5,14 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"math/rand"
+	"math"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
 	"gemini-zeromq-labs/lab02/internal/config"
+	"gemini-zeromq-labs/lab02/internal/flow"
 	"gemini-zeromq-labs/lab02/internal/protocol"
+	"gemini-zeromq-labs/lab02/internal/source"
 
 	"github.com/go-zeromq/zmq4"
 )
@@ -34,8 +38,19 @@ func main() {
 		cancel()
 	}()
 
-	// 1. Create a PUSH socket (Ventilator)
-	ventilator := zmq4.NewPush(ctx)
+	sources, err := source.Parse(cfg.Sources, source.Options{
+		OffsetsFile:  cfg.OffsetsFile,
+		BatchSize:    cfg.BatchSize,
+		ReinjectAddr: cfg.ReinjectBindAddr(),
+		Logger:       logger,
+	})
+	if err != nil {
+		logger.Error("Invalid sources", "error", err)
+		os.Exit(1)
+	}
+
+	// 1. Create a ROUTER socket (Ventilator); workers connect with DEALER and grant credit
+	ventilator := zmq4.NewRouter(ctx, zmq4.WithID(zmq4.SocketIdentity("collector")))
 	defer ventilator.Close()
 
 	// 2. Bind to the endpoint
@@ -46,55 +61,290 @@ func main() {
 		os.Exit(1)
 	}
 
-	// 3. Wait for workers to connect
-	logger.Info("Collector ready. Waiting 5 seconds for workers to connect...")
-	time.Sleep(5 * time.Second)
+	// 3. Control channel straight to the Sink for BATCH_START / BATCH_END
+	sinkCtl := zmq4.NewPush(ctx)
+	defer sinkCtl.Close()
+
+	sinkAddr := cfg.SinkConnectAddr()
+	logger.Info("Collector connecting to sink", "endpoint", sinkAddr)
+	if err := sinkCtl.Dial(sinkAddr); err != nil {
+		logger.Error("Failed to dial sink", "error", err)
+		os.Exit(1)
+	}
+
+	// 4. Receive credit grants in a goroutine; the main loop owns the ledger
+	creditChan := make(chan zmq4.Msg)
+	go func() {
+		for {
+			msg, err := ventilator.Recv()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logger.Error("Collector error receiving credit", "error", err)
+				continue
+			}
+			select {
+			case creditChan <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// 5. Start every source; they all feed the same ventilator
+	entries := make(chan protocol.LogEntry, 1024)
+	var wg sync.WaitGroup
+	for _, src := range sources {
+		wg.Add(1)
+		go func(src source.Source) {
+			defer wg.Done()
+			logger.Info("Source started", "source", src.Name())
+			if err := src.Run(ctx, entries); err != nil && ctx.Err() == nil {
+				logger.Error("Source failed", "source", src.Name(), "error", err)
+				return
+			}
+			logger.Info("Source finished", "source", src.Name())
+		}(src)
+	}
+	go func() {
+		wg.Wait()
+		close(entries)
+	}()
+
+	b := &batcher{sinkCtl: sinkCtl, maxSize: cfg.BatchSize, logger: logger}
+	flush := time.NewTicker(cfg.BatchInterval)
+	defer flush.Stop()
+
+	ledger := flow.NewLedger(cfg.WorkerTimeout)
+	stats := time.NewTicker(cfg.StatsInterval)
+	defer stats.Stop()
 
-	logger.Info("Starting log generation...")
-	start := time.Now()
+	logger.Info("Starting log shipping, waiting for worker credit...", "sources", len(sources), "batch_size", cfg.BatchSize, "batch_interval", cfg.BatchInterval.String())
 
-	// Generate a batch of logs
-	const batchSize = 10000
-	sources := []string{"Firewall-1", "Auth-Server", "Web-Gateway", "DB-Cluster"}
-	levels := []protocol.LogLevel{protocol.INFO, protocol.WARN, protocol.ERROR, protocol.DEBUG}
+	// pending holds an entry read from the sources that could not be delivered yet
+	var pending *protocol.LogEntry
+	done := false
 
-	for i := 0; i < batchSize; i++ {
-		// Check for cancellation
-		if ctx.Err() != nil {
-			break
+	for {
+		// Only pull from the sources while some worker has credit; otherwise
+		// the sources block and back-pressure reaches them instead of a queue.
+		var in <-chan protocol.LogEntry
+		if pending == nil && !done && ledger.Available() > 0 {
+			in = entries
 		}
 
-		// Simulate some "IP" in the message to be anonymized later
-		msgContent := fmt.Sprintf("User login attempt from IP: 192.168.1.%d", rand.Intn(255))
-		if rand.Float32() < 0.1 {
-			msgContent = fmt.Sprintf("Malware detected from IP: 10.0.0.%d", rand.Intn(255))
+		select {
+		case <-ctx.Done():
+			b.close()
+			logThroughput(ledger, logger)
+			// Give time for packets to flush out before closing
+			time.Sleep(1 * time.Second)
+			return
+
+		case <-flush.C:
+			if b.age() >= cfg.BatchInterval {
+				b.close()
+			}
+
+		case now := <-stats.C:
+			for _, w := range ledger.Expire(now) {
+				logger.Warn("Worker timed out", "worker", w.ID, "sent", w.Sent, "credit_lost", w.Credit)
+			}
+			logThroughput(ledger, logger)
+
+		case msg := <-creditChan:
+			handleCredit(ledger, msg, logger)
+
+		case entry, ok := <-in:
+			if !ok {
+				done = true
+				break
+			}
+			pending = &entry
 		}
 
-		entry := protocol.LogEntry{
-			ID:        fmt.Sprintf("log-%d", i),
-			Timestamp: time.Now(),
-			Level:     levels[rand.Intn(len(levels))],
-			Source:    sources[rand.Intn(len(sources))],
-			Message:   msgContent,
-			RawData:   "0xDEADBEEF...", // filler
+		if pending != nil && dispatch(ventilator, ledger, b, *pending, logger) {
+			pending = nil
+			if b.full() {
+				b.close()
+			}
 		}
 
-		data, err := json.Marshal(entry)
-		if err != nil {
-			logger.Error("Error marshalling log", "error", err)
-			continue
+		if done && pending == nil {
+			// Every source is exhausted; the Sink stops once its batches finish
+			b.close()
+			if err := sendControl(sinkCtl, protocol.BatchControl{Type: protocol.StreamEnd, Timestamp: time.Now()}); err != nil {
+				logger.Error("Failed to send STREAM_END", "error", err)
+			}
+			logThroughput(ledger, logger)
+			time.Sleep(1 * time.Second)
+			return
+		}
+	}
+}
+
+// handleCredit applies a [worker ID, CREDIT|HEARTBEAT, n] or [worker ID, BYE] message to the ledger.
+func handleCredit(ledger *flow.Ledger, msg zmq4.Msg, logger *slog.Logger) {
+	if len(msg.Frames) < 2 {
+		logger.Warn("Malformed worker message", "frames", len(msg.Frames))
+		return
+	}
+	id := string(msg.Frames[0])
+
+	switch kind := string(msg.Frames[1]); kind {
+	case protocol.CreditFrame, protocol.HeartbeatFrame:
+		if len(msg.Frames) != 3 {
+			logger.Warn("Malformed credit message", "worker", id, "frames", len(msg.Frames))
+			return
+		}
+		n, err := strconv.Atoi(string(msg.Frames[2]))
+		if err != nil || n < 0 {
+			logger.Warn("Invalid credit", "worker", id, "credit", string(msg.Frames[2]))
+			return
 		}
+		if kind == protocol.HeartbeatFrame {
+			if ledger.Heartbeat(id, n, time.Now()) {
+				logger.Info("Worker rejoined", "worker", id, "credit", n, "workers", ledger.Len())
+			}
+			return
+		}
+		if ledger.Grant(id, n, time.Now()) {
+			logger.Info("Worker joined", "worker", id, "credit", n, "workers", ledger.Len())
+		}
+
+	case protocol.ByeFrame:
+		ledger.Remove(id)
+		logger.Info("Worker left", "worker", id, "workers", ledger.Len())
+
+	default:
+		logger.Warn("Unknown worker message", "worker", id, "type", string(msg.Frames[1]))
+	}
+}
 
-		// Send task
-		msg := zmq4.NewMsg(data)
-		if err := ventilator.Send(msg); err != nil {
-			logger.Error("Failed to send log", "error", err)
+// dispatch sends entry to the worker with the most credit left.
+// Workers that fail to receive are dropped from the ledger and the next one is tried;
+// it reports false when no worker could take the entry.
+// A ROUTER silently drops a message to an identity that is no longer
+// connected, so a send error only covers part of it: the ledger also passes
+// over workers that stopped heartbeating. Anything still sent to a vanished
+// worker is reported missing by the Sink.
+func dispatch(ventilator zmq4.Socket, ledger *flow.Ledger, b *batcher, entry protocol.LogEntry, logger *slog.Logger) bool {
+	for {
+		id, ok := ledger.Next(time.Now())
+		if !ok {
+			return false
+		}
+		if err := b.send(ventilator, id, entry); err != nil {
+			logger.Error("Failed to send log, dropping worker", "worker", id, "error", err)
+			ledger.Remove(id)
+			continue
 		}
+		ledger.Sent(id)
+		return true
+	}
+}
+
+// logThroughput logs how many entries every worker received since the last call.
+func logThroughput(ledger *flow.Ledger, logger *slog.Logger) {
+	for _, s := range ledger.Stats(time.Now()) {
+		logger.Info("Worker throughput",
+			"worker", s.ID,
+			"sent", s.Sent,
+			"rate_per_sec", math.Round(s.Rate*10)/10,
+			"total", s.Total,
+			"credit", s.Credit,
+		)
+	}
+}
+
+// batcher groups outgoing entries into batches delimited by BATCH_START / BATCH_END.
+type batcher struct {
+	sinkCtl zmq4.Socket
+	maxSize int
+	logger  *slog.Logger
+
+	id      string
+	seq     int
+	started time.Time
+	sentIDs []string
+}
+
+func (b *batcher) open() {
+	b.seq++
+	b.id = fmt.Sprintf("batch-%d-%d", time.Now().Unix(), b.seq)
+	b.started = time.Now()
+	b.sentIDs = make([]string, 0, b.maxSize)
+
+	if err := sendControl(b.sinkCtl, protocol.BatchControl{
+		Type:      protocol.BatchStart,
+		BatchID:   b.id,
+		Expected:  b.maxSize,
+		Timestamp: b.started,
+	}); err != nil {
+		b.logger.Error("Failed to send BATCH_START", "error", err)
+	}
+	b.logger.Info("Batch started", "batch_id", b.id)
+}
+
+// send delivers entry to worker id as [worker ID, LogEntry JSON].
+func (b *batcher) send(ventilator zmq4.Socket, id string, entry protocol.LogEntry) error {
+	if b.id == "" {
+		b.open()
+	}
+	entry.BatchID = b.id
+	if entry.ID == "" {
+		entry.ID = fmt.Sprintf("%s-%d", b.id, len(b.sentIDs))
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// Send task; the ROUTER routes on the first frame
+	if err := ventilator.Send(zmq4.NewMsgFrom([]byte(id), data)); err != nil {
+		return err
+	}
+	b.sentIDs = append(b.sentIDs, entry.ID)
+	return nil
+}
+
+func (b *batcher) full() bool {
+	return b.id != "" && len(b.sentIDs) >= b.maxSize
+}
+
+func (b *batcher) age() time.Duration {
+	if b.id == "" {
+		return 0
 	}
+	return time.Since(b.started)
+}
 
-	elapsed := time.Since(start)
-	logger.Info("Batch completed", "count", batchSize, "duration", elapsed.String())
+// close announces what was actually sent so the Sink can detect losses.
+func (b *batcher) close() {
+	if b.id == "" {
+		return
+	}
+	if err := sendControl(b.sinkCtl, protocol.BatchControl{
+		Type:      protocol.BatchEnd,
+		BatchID:   b.id,
+		Expected:  len(b.sentIDs),
+		IDs:       b.sentIDs,
+		Timestamp: time.Now(),
+	}); err != nil {
+		b.logger.Error("Failed to send BATCH_END", "error", err)
+	}
+	b.logger.Info("Batch completed", "batch_id", b.id, "count", len(b.sentIDs), "duration", time.Since(b.started).String())
+	b.id = ""
+	b.sentIDs = nil
+}
 
-	// Give time for packets to flush out before closing
-	time.Sleep(1 * time.Second)
+// sendControl sends a batch control message as [ControlFrame, JSON].
+func sendControl(sock zmq4.Socket, c protocol.BatchControl) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return sock.Send(zmq4.NewMsgFrom([]byte(protocol.ControlFrame), data))
 }
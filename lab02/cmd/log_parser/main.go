@@ -3,15 +3,20 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
-	"regexp"
+	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"gemini-zeromq-labs/lab02/internal/config"
+	"gemini-zeromq-labs/lab02/internal/enrich"
 	"gemini-zeromq-labs/lab02/internal/protocol"
+	"gemini-zeromq-labs/lab02/internal/redact"
 
 	"github.com/go-zeromq/zmq4"
 )
@@ -31,16 +36,70 @@ func main() {
 		cancel()
 	}()
 
-	// 1. Socket to receive messages on (PULL) from Collector
-	receiver := zmq4.NewPull(ctx)
+	// Redaction rules; SIGHUP swaps in a freshly loaded engine without restarting
+	engine, err := redact.LoadFile(cfg.RedactionRules)
+	if err != nil {
+		logger.Error("Failed to load redaction rules", "path", cfg.RedactionRules, "error", err)
+		os.Exit(1)
+	}
+	var rules atomic.Pointer[redact.Engine]
+	rules.Store(engine)
+	logger.Info("Redaction rules loaded", "path", cfg.RedactionRules, "rules", engine.Rules())
+
+	// Enrichment (GeoIP + severity) runs before redaction and reloads the same way
+	enricher, err := enrich.Load(cfg.GeoIPDB, cfg.SeverityRules)
+	if err != nil {
+		logger.Error("Failed to load enrichment data", "geoip_db", cfg.GeoIPDB, "severity_rules", cfg.SeverityRules, "error", err)
+		os.Exit(1)
+	}
+	var enrichment atomic.Pointer[enrich.Enricher]
+	enrichment.Store(enricher)
+	logger.Info("Enrichment loaded", "networks", enricher.Networks(), "severity_rules", enricher.SeverityRules())
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			engine, err := redact.LoadFile(cfg.RedactionRules)
+			if err != nil {
+				// Keep serving with the previous rules
+				logger.Error("Redaction reload failed, keeping previous rules", "error", err)
+			} else {
+				rules.Store(engine)
+				logger.Info("Redaction rules reloaded", "rules", engine.Rules())
+			}
+
+			enricher, err := enrich.Load(cfg.GeoIPDB, cfg.SeverityRules)
+			if err != nil {
+				logger.Error("Enrichment reload failed, keeping previous data", "error", err)
+				continue
+			}
+			enrichment.Store(enricher)
+			logger.Info("Enrichment reloaded", "networks", enricher.Networks(), "severity_rules", enricher.SeverityRules())
+		}
+	}()
+
+	workerID := cfg.WorkerID
+	if workerID == "" {
+		hostname, _ := os.Hostname()
+		workerID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	// 1. Socket to receive messages on (DEALER) from Collector; the identity keys our credit.
+	// It has its own context so BYE can still be sent after a shutdown signal.
+	dealerCtx, stopDealer := context.WithCancel(context.Background())
+	defer stopDealer()
+	receiver := zmq4.NewDealer(dealerCtx, zmq4.WithID(zmq4.SocketIdentity(workerID)), zmq4.WithDialerMaxRetries(-1))
 	defer receiver.Close()
-	
+
 	collectorAddr := cfg.CollectorConnectAddr()
-	logger.Info("Worker connecting to collector", "endpoint", collectorAddr)
+	logger.Info("Worker connecting to collector", "endpoint", collectorAddr, "worker", workerID)
+	stopDial := context.AfterFunc(ctx, stopDealer) // still interruptible while waiting for the Collector
 	if err := receiver.Dial(collectorAddr); err != nil {
 		logger.Error("Failed to dial collector", "error", err)
 		os.Exit(1)
 	}
+	stopDial()
 
 	// 2. Socket to send messages to (PUSH) to Sink
 	sender := zmq4.NewPush(ctx)
@@ -53,51 +112,246 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Regex for basic IP redaction
-	ipRegex := regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	// 3. Dead letters go through a queue so an absent DLQ consumer never stalls the worker
+	deadChan := make(chan protocol.DeadLetter, 1024)
+	go runDeadLetterPusher(ctx, cfg.DLQConnectAddr(), deadChan, logger)
+	dead := &deadLetterer{workerID: workerID, out: deadChan, sink: sender, logger: logger}
 
-	logger.Info("Worker started. Waiting for tasks...")
+	// 4. Grant the initial credit window; the Collector sends nothing more than that
+	window := cfg.CreditWindow
+	if window < 1 {
+		window = 1
+	}
+	credits := &creditor{sock: receiver, logger: logger}
+	credits.grant(window)
 
-	for {
-		// Read raw message
-		msg, err := receiver.Recv()
-		if err != nil {
-			// Context canceled or error
-			if ctx.Err() != nil {
-				break
+	// Receive in a goroutine so the main loop can also send heartbeats
+	msgChan := make(chan zmq4.Msg)
+	go func() {
+		for {
+			msg, err := receiver.Recv()
+			if err != nil {
+				// Context canceled or error
+				if ctx.Err() != nil {
+					return
+				}
+				logger.Error("Worker error receiving", "error", err)
+				continue
+			}
+			select {
+			case msgChan <- msg:
+			case <-ctx.Done():
+				return
 			}
-			logger.Error("Worker error receiving", "error", err)
-			continue
 		}
+	}()
 
-		// Unmarshal
-		var entry protocol.LogEntry
-		if err := json.Unmarshal(msg.Bytes(), &entry); err != nil {
-			logger.Error("Worker error unmarshalling", "error", err)
+	heartbeat := time.NewTicker(cfg.WorkerTimeout / 3)
+	defer heartbeat.Stop()
+
+	logger.Info("Worker started. Waiting for tasks...", "credit_window", window)
+
+	// Re-grant credit in chunks of half the window to keep the Collector's view current
+	// without a credit message per entry.
+	regrant := window / 2
+	if regrant < 1 {
+		regrant = 1
+	}
+	processedSince := 0
+
+	for {
+		var msg zmq4.Msg
+		select {
+		case <-ctx.Done():
+			credits.bye()
+			return
+		case <-heartbeat.C:
+			credits.heartbeat()
 			continue
+		case msg = <-msgChan:
 		}
+		credits.received()
 
-		// Process / Anonymize
-		cleanMsg := ipRegex.ReplaceAllString(entry.Message, "[REDACTED]")
-		sanitized := cleanMsg != entry.Message
+		// Unmarshal, validate and process; anything that fails is dead-lettered
+		raw := msg.Bytes()
+		var entry protocol.LogEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			dead.send(raw, protocol.StageDecode, err, entry)
+		} else if err := validate(entry); err != nil {
+			dead.send(raw, protocol.StageValidate, err, entry)
+		} else if err := process(entry, enrichment.Load(), rules.Load(), sender, logger); err != nil {
+			dead.send(raw, protocol.StageRedact, err, entry)
+		}
 
-		processed := protocol.ProcessedLogEntry{
-			OriginalID:   entry.ID,
-			ProcessedAt:  time.Now(),
-			Level:        entry.Level,
-			Sanitized:    sanitized,
-			CleanMessage: cleanMsg,
+		processedSince++
+		if processedSince >= regrant {
+			credits.grant(processedSince)
+			processedSince = 0
 		}
+	}
+}
 
-		outData, err := json.Marshal(processed)
-		if err != nil {
-			logger.Error("Worker error marshalling processed data", "error", err)
-			continue
+// validate checks the fields the Sink relies on.
+func validate(entry protocol.LogEntry) error {
+	if entry.ID == "" {
+		return errors.New("missing id")
+	}
+	if !entry.Level.Valid() {
+		return fmt.Errorf("unknown level %q", entry.Level)
+	}
+	return nil
+}
+
+// process enriches and redacts entry and forwards it to the Sink.
+// It returns an error if a reject rule refused the entry.
+func process(entry protocol.LogEntry, enricher *enrich.Enricher, engine *redact.Engine, sender zmq4.Socket, logger *slog.Logger) error {
+	// Enrich while addresses are still in the message
+	extra := enricher.Apply(entry)
+
+	// Process / Anonymize
+	res := engine.Apply(entry.Message)
+	if res.Reject != "" {
+		return fmt.Errorf("rejected by rule %s", res.Reject)
+	}
+
+	processed := protocol.ProcessedLogEntry{
+		OriginalID:   entry.ID,
+		BatchID:      entry.BatchID,
+		Timestamp:    entry.Timestamp,
+		ProcessedAt:  time.Now(),
+		Level:        entry.Level,
+		Sanitized:    len(res.Fired) > 0,
+		CleanMessage: res.Value,
+		RulesFired:   res.Fired,
+		Severity:     extra.Severity,
+	}
+	if len(extra.Tags) > 0 {
+		processed.Tags = extra.Tags
+	}
+
+	outData, err := json.Marshal(processed)
+	if err != nil {
+		logger.Error("Worker error marshalling processed data", "error", err)
+		return nil
+	}
+
+	// Send to Sink
+	if err := sender.Send(zmq4.NewMsg(outData)); err != nil {
+		logger.Error("Worker error sending to sink", "error", err)
+	}
+	return nil
+}
+
+// deadLetterer queues failed entries for the DLQ and tells the Sink,
+// so the batch report counts them as dead-lettered rather than missing.
+type deadLetterer struct {
+	workerID string
+	out      chan<- protocol.DeadLetter
+	sink     zmq4.Socket
+	logger   *slog.Logger
+}
+
+// send dead-letters raw; entry holds whatever could be decoded from it.
+func (d *deadLetterer) send(raw []byte, stage protocol.DeadLetterStage, cause error, entry protocol.LogEntry) {
+	d.logger.Warn("Dead-lettering entry", "stage", stage, "original_id", entry.ID, "error", cause)
+
+	letter := protocol.DeadLetter{
+		Original:   raw,
+		Stage:      stage,
+		Error:      cause.Error(),
+		WorkerID:   d.workerID,
+		OriginalID: entry.ID,
+		BatchID:    entry.BatchID,
+		Timestamp:  time.Now(),
+	}
+	select {
+	case d.out <- letter:
+	default:
+		d.logger.Error("Dead-letter queue full, dropping entry", "original_id", entry.ID)
+		return
+	}
+
+	if entry.ID == "" || entry.BatchID == "" {
+		// Undecodable entries can't be matched to their batch; the Sink reports them missing
+		return
+	}
+	data, err := json.Marshal(protocol.BatchControl{
+		Type:      protocol.BatchDeadLetter,
+		BatchID:   entry.BatchID,
+		IDs:       []string{entry.ID},
+		Timestamp: letter.Timestamp,
+	})
+	if err != nil {
+		d.logger.Error("Worker error marshalling dead-letter notice", "error", err)
+		return
+	}
+	if err := d.sink.Send(zmq4.NewMsgFrom([]byte(protocol.ControlFrame), data)); err != nil {
+		d.logger.Error("Worker error sending dead-letter notice", "error", err)
+	}
+}
+
+// runDeadLetterPusher connects a PUSH socket to the DLQ consumer and forwards dead letters as JSON.
+func runDeadLetterPusher(ctx context.Context, endpoint string, letters <-chan protocol.DeadLetter, logger *slog.Logger) {
+	push := zmq4.NewPush(ctx, zmq4.WithDialerMaxRetries(-1))
+	defer push.Close()
+
+	if err := push.Dial(endpoint); err != nil {
+		if ctx.Err() == nil {
+			logger.Error("Failed to connect to DLQ", "endpoint", endpoint, "error", err)
 		}
+		return
+	}
+	logger.Info("Connected to DLQ", "endpoint", endpoint)
 
-		// Send to Sink
-		if err := sender.Send(zmq4.NewMsg(outData)); err != nil {
-			logger.Error("Worker error sending to sink", "error", err)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case l := <-letters:
+			payload, err := json.Marshal(l)
+			if err != nil {
+				logger.Error("Error serializing dead letter", "error", err)
+				continue
+			}
+			if err := push.Send(zmq4.NewMsg(payload)); err != nil {
+				logger.Error("Error pushing dead letter", "error", err)
+			}
 		}
 	}
 }
+
+// creditor sends flow control messages to the Collector and tracks
+// how much granted credit has not been used yet.
+type creditor struct {
+	sock        zmq4.Socket
+	logger      *slog.Logger
+	outstanding int
+}
+
+func (c *creditor) grant(n int) {
+	c.outstanding += n
+	c.send(protocol.CreditFrame, n)
+}
+
+func (c *creditor) received() {
+	if c.outstanding > 0 {
+		c.outstanding--
+	}
+}
+
+func (c *creditor) heartbeat() {
+	c.send(protocol.HeartbeatFrame, c.outstanding)
+}
+
+func (c *creditor) bye() {
+	if err := c.sock.Send(zmq4.NewMsg([]byte(protocol.ByeFrame))); err != nil {
+		c.logger.Error("Worker error sending bye", "error", err)
+	}
+}
+
+// send writes [kind, n] to the Collector.
+func (c *creditor) send(kind string, n int) {
+	if err := c.sock.Send(zmq4.NewMsgFrom([]byte(kind), []byte(strconv.Itoa(n)))); err != nil {
+		c.logger.Error("Worker error sending credit", "type", kind, "error", err)
+	}
+}
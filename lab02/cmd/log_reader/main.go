@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/config"
+	"gemini-zeromq-labs/lab02/internal/protocol"
+	"gemini-zeromq-labs/lab02/internal/segment"
+)
+
+func main() {
+	// Query flags are registered before LoadConfig, which parses the shared flag set
+	from := flag.String("from", "", "Start of the time range (RFC3339 or duration ago, e.g. 15m)")
+	to := flag.String("to", "", "End of the time range (RFC3339 or duration ago)")
+	level := flag.String("level", "", "Only show this level (DEBUG, INFO, WARN, ERROR)")
+	limit := flag.Int("limit", 0, "Maximum number of records to print (0 = all)")
+	asJSON := flag.Bool("json", false, "Print raw JSON lines")
+
+	cfg := config.LoadConfig()
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+	now := time.Now()
+	q := segment.Query{Level: protocol.LogLevel(strings.ToUpper(*level))}
+	var err error
+	if q.From, err = parseTime(*from, now); err != nil {
+		logger.Error("Invalid -from", "error", err)
+		os.Exit(1)
+	}
+	if q.To, err = parseTime(*to, now); err != nil {
+		logger.Error("Invalid -to", "error", err)
+		os.Exit(1)
+	}
+
+	n := 0
+	err = segment.Scan(cfg.StorageDir, q, func(e *protocol.ProcessedLogEntry) bool {
+		if *asJSON {
+			b, _ := json.Marshal(e)
+			fmt.Println(string(b))
+		} else {
+			fmt.Printf("[%s] %-5s sev=%-3d %s %s%s\n", e.Time().Format(time.RFC3339Nano), e.Level, e.Severity, e.OriginalID, e.CleanMessage, formatTags(e.Tags))
+		}
+		n++
+		return *limit == 0 || n < *limit
+	})
+	if err != nil {
+		logger.Error("Query failed", "dir", cfg.StorageDir, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Query finished", "matched", n)
+}
+
+// parseTime accepts RFC3339 or a duration relative to now ("15m" = 15 minutes ago).
+func parseTime(v string, now time.Time) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// formatTags renders tags as " {k=v ...}" in key order, or nothing.
+func formatTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + tags[k]
+	}
+	return " {" + strings.Join(parts, " ") + "}"
+}
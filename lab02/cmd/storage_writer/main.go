@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
+	"gemini-zeromq-labs/lab02/internal/batch"
 	"gemini-zeromq-labs/lab02/internal/config"
+	"gemini-zeromq-labs/lab02/internal/dedup"
+	"gemini-zeromq-labs/lab02/internal/protocol"
+	"gemini-zeromq-labs/lab02/internal/segment"
 
 	"github.com/go-zeromq/zmq4"
 )
@@ -22,6 +28,7 @@ func main() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	go func() {
 		<-c
@@ -41,36 +48,243 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger.Info("Sink ready. Waiting for processed logs...")
+	// 3. Open durable segment storage
+	store, err := segment.Open(segment.Options{
+		Dir:           cfg.StorageDir,
+		MaxBytes:      cfg.SegmentMaxBytes,
+		MaxAge:        cfg.SegmentMaxAge,
+		Compress:      cfg.Compress,
+		Fsync:         segment.FsyncPolicy(cfg.Fsync),
+		FsyncInterval: cfg.FsyncInterval,
+	})
+	if err != nil {
+		logger.Error("Failed to open storage", "dir", cfg.StorageDir, "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			logger.Error("Failed to close storage", "error", err)
+		}
+	}()
+	logger.Info("Storage opened", "dir", cfg.StorageDir, "compress", cfg.Compress, "fsync", cfg.Fsync)
 
-	var count int
-	start := time.Now()
-	first := true
+	// 4. Restore the dedup filter and catch up on records written after the last checkpoint
+	checkpointPath := filepath.Join(cfg.StorageDir, checkpointFile)
+	filter, replayed, err := restoreFilter(cfg, checkpointPath)
+	if err != nil {
+		logger.Error("Failed to restore dedup state", "path", checkpointPath, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Dedup state ready", "replayed", replayed, "unique_total", filter.Stats().Unique, "window", cfg.DedupWindow, "capacity", cfg.DedupCapacity)
 
-	for {
-		_, err := sink.Recv()
-		if err != nil {
-			if ctx.Err() != nil {
-				break
+	// The Bloom generations make the checkpoint large, so it is only
+	// rewritten when the filter saw entries since the last one.
+	var saved *dedup.Stats
+	checkpoint := func() {
+		stats := filter.Stats()
+		if saved != nil && *saved == stats {
+			return
+		}
+		// Sync first so the checkpoint never covers records a crash could lose
+		if err := store.Sync(); err != nil {
+			logger.Error("Storage sync failed, skipping checkpoint", "error", err)
+			return
+		}
+		cp := filter.Checkpoint()
+		pos := store.Position()
+		cp.SegmentFile, cp.SegmentCount = pos.File, pos.Count
+		if err := cp.Save(checkpointPath); err != nil {
+			logger.Error("Failed to save dedup checkpoint", "error", err)
+			return
+		}
+		saved = &stats
+	}
+
+	logger.Info("Sink ready. Waiting for processed logs...", "exit_after", cfg.ExitAfter)
+
+	// Receive in a goroutine so the main loop can also expire finished batches
+	msgChan := make(chan zmq4.Msg)
+	go func() {
+		for {
+			msg, err := sink.Recv()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logger.Error("Sink error receiving", "error", err)
+				continue
+			}
+			select {
+			case msgChan <- msg:
+			case <-ctx.Done():
+				return
 			}
-			logger.Error("Sink error receiving", "error", err)
-			continue
 		}
+	}()
+
+	tracker := batch.NewTracker(cfg.BatchGrace)
+	expire := time.NewTicker(time.Second)
+	defer expire.Stop()
+	checkpointTicker := time.NewTicker(cfg.CheckpointInterval)
+	defer checkpointTicker.Stop()
 
-		if first {
-			start = time.Now()
-			first = false
-			logger.Info("Batch started")
+	var count, finished, unique, duplicates, probable int
+	start := time.Now()
+	// Set by STREAM_END: the Collector has no more batches to send
+	streamEnded := false
+
+	report := func(r batch.Report) {
+		finished++
+		fmt.Println()
+		logger.Info("Batch finished",
+			"batch_id", r.BatchID,
+			"complete", r.Complete,
+			"expected", r.Expected,
+			"received", r.Received,
+			"duplicates", r.Duplicate,
+			"dead_lettered", r.Dead,
+			"missing", len(r.Missing),
+			"duration", r.Duration.String(),
+			"end_to_end", r.LastSeen.Sub(r.Started).String(),
+		)
+		if len(r.Missing) > 0 {
+			logger.Warn("Missing entries", "batch_id", r.BatchID, "original_ids", r.Missing)
+		}
+		if cfg.ExitAfter > 0 && finished >= cfg.ExitAfter {
+			cancel()
 		}
+		if streamEnded && tracker.Open() == 0 {
+			logger.Info("Stream ended and every batch finished")
+			cancel()
+		}
+	}
 
-		count++
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+
+		case now := <-expire.C:
+			if err := store.Tick(now); err != nil {
+				logger.Error("Storage tick failed", "error", err)
+			}
+			for _, r := range tracker.Expire(now) {
+				report(r)
+			}
+
+		case <-checkpointTicker.C:
+			checkpoint()
+
+		case msg := <-msgChan:
+			// Control messages: [BATCH] [BatchControl JSON]
+			if len(msg.Frames) == 2 && string(msg.Frames[0]) == protocol.ControlFrame {
+				var ctl protocol.BatchControl
+				if err := json.Unmarshal(msg.Frames[1], &ctl); err != nil {
+					logger.Error("Sink error unmarshalling control", "error", err)
+					continue
+				}
+				logger.Info("Batch control", "type", ctl.Type, "batch_id", ctl.BatchID, "expected", ctl.Expected)
+				if ctl.Type == protocol.StreamEnd {
+					// Every batch was announced before this on the same socket
+					streamEnded = true
+					if tracker.Open() == 0 {
+						logger.Info("Stream ended and every batch finished")
+						cancel()
+					}
+					continue
+				}
+				if r := tracker.Control(ctl); r != nil {
+					report(*r)
+				}
+				continue
+			}
+
+			var entry protocol.ProcessedLogEntry
+			if err := json.Unmarshal(msg.Bytes(), &entry); err != nil {
+				logger.Error("Sink error unmarshalling", "error", err)
+				continue
+			}
 
-		if count%1000 == 0 {
-			// Using fmt here just for progress visibility in console if needed, or logger
-			fmt.Printf("\rSink: Processed %d messages...", count)
+			// Redelivered entries still count for their batch, but are written once.
+			// A Bloom hit may be a false positive, so probable duplicates are
+			// written too: a rare second copy beats losing an entry. The ID is
+			// only recorded once the write succeeded, so a redelivery of an
+			// entry that failed to store is not skipped.
+			switch v := filter.Check(entry.OriginalID); v {
+			case dedup.Duplicate:
+				filter.Record(entry.OriginalID, v)
+				duplicates++
+				logger.Debug("Skipping duplicate", "original_id", entry.OriginalID)
+			default:
+				if err := store.Write(&entry); err != nil {
+					logger.Error("Sink error storing entry", "original_id", entry.OriginalID, "error", err)
+					break
+				}
+				filter.Record(entry.OriginalID, v)
+				if v == dedup.New {
+					unique++
+				} else {
+					probable++
+					logger.Debug("Stored probable duplicate", "original_id", entry.OriginalID)
+				}
+			}
+
+			count++
+			if count%1000 == 0 {
+				// Using fmt here just for progress visibility in console if needed, or logger
+				fmt.Printf("\rSink: Processed %d messages...", count)
+			}
+
+			if r := tracker.Entry(entry, time.Now()); r != nil {
+				report(*r)
+			}
 		}
 	}
 
+	checkpoint()
+
 	duration := time.Since(start)
-	logger.Info("Sink finished", "total", count, "duration", duration.String())
+	total := filter.Stats()
+	logger.Info("Sink finished",
+		"total", count,
+		"unique", unique,
+		"duplicates", duplicates,
+		"probable_duplicates", probable,
+		"batches", finished,
+		"open_batches", tracker.Open(),
+		"duration", duration.String(),
+		"unique_total", total.Unique,
+		"duplicates_total", total.Duplicate+total.Probable,
+	)
+}
+
+// checkpointFile holds the dedup state inside the storage directory.
+const checkpointFile = "dedup_checkpoint.json"
+
+// restoreFilter loads the last checkpoint (if any) and replays every record the
+// segment store holds beyond it, so IDs written just before a crash are known too.
+func restoreFilter(cfg *config.Config, path string) (*dedup.Filter, int, error) {
+	opts := dedup.Options{Capacity: cfg.DedupCapacity, FPRate: cfg.DedupFPRate, Window: cfg.DedupWindow}
+
+	cp, err := dedup.LoadCheckpoint(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	filter := dedup.NewFilter(opts)
+	var pos segment.Position
+	if cp != nil {
+		if filter, err = dedup.Restore(opts, cp); err != nil {
+			return nil, 0, err
+		}
+		pos = segment.Position{File: cp.SegmentFile, Count: cp.SegmentCount}
+	}
+
+	replayed := 0
+	err = segment.ScanFrom(cfg.StorageDir, pos, func(e *protocol.ProcessedLogEntry) bool {
+		filter.Seen(e.OriginalID)
+		replayed++
+		return true
+	})
+	return filter, replayed, err
 }
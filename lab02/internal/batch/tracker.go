@@ -0,0 +1,188 @@
+package batch
+
+import (
+	"sort"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+// Report summarises a finished batch.
+type Report struct {
+	BatchID   string
+	Expected  int
+	Received  int
+	Duplicate int      // entries received more than once
+	Dead      int      // entries a Worker moved to the dead-letter queue
+	Missing   []string // OriginalIDs announced in BATCH_END but neither received nor dead-lettered
+	Complete  bool
+	Started   time.Time // BATCH_START timestamp from the collector
+	FirstSeen time.Time // first processed entry at the sink
+	LastSeen  time.Time // last processed entry at the sink
+	Duration  time.Duration
+}
+
+// Batch is the sink-side state of a single batch.
+type Batch struct {
+	ID        string
+	Expected  int
+	Started   time.Time
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Received  map[string]struct{}
+	Dead      map[string]struct{}
+	Duplicate int
+
+	ended      bool
+	endedAt    time.Time
+	sentIDs    []string
+	finished   bool
+	finishedAt time.Time
+}
+
+// Tracker follows every open batch at the sink.
+// It is not safe for concurrent use; the sink drives it from its main loop.
+type Tracker struct {
+	grace   time.Duration
+	batches map[string]*Batch
+}
+
+// NewTracker creates a tracker that waits up to grace after BATCH_END
+// for in-flight entries before declaring the rest missing.
+func NewTracker(grace time.Duration) *Tracker {
+	return &Tracker{grace: grace, batches: make(map[string]*Batch)}
+}
+
+func (t *Tracker) get(id string) *Batch {
+	b, ok := t.batches[id]
+	if !ok {
+		b = &Batch{ID: id, Received: make(map[string]struct{}), Dead: make(map[string]struct{})}
+		t.batches[id] = b
+	}
+	return b
+}
+
+// Control applies a BATCH_START, BATCH_END or DEAD_LETTER message.
+// It returns a report if the message completes the batch.
+func (t *Tracker) Control(c protocol.BatchControl) *Report {
+	b := t.get(c.BatchID)
+	switch c.Type {
+	case protocol.BatchStart:
+		b.Expected = c.Expected
+		b.Started = c.Timestamp
+	case protocol.BatchEnd:
+		// The collector may have sent fewer entries than planned (e.g. on shutdown)
+		b.Expected = c.Expected
+		b.sentIDs = c.IDs
+		b.ended = true
+		b.endedAt = time.Now()
+	case protocol.BatchDeadLetter:
+		if b.finished {
+			return nil
+		}
+		// Dead-lettered entries are accounted for, not missing
+		for _, id := range c.IDs {
+			if _, ok := b.Received[id]; !ok {
+				b.Dead[id] = struct{}{}
+			}
+		}
+	}
+	return t.check(b)
+}
+
+// Entry records a processed entry. Entries may arrive before BATCH_START,
+// since control messages and data take different paths to the sink.
+// It returns a report if the entry completes the batch.
+func (t *Tracker) Entry(e protocol.ProcessedLogEntry, now time.Time) *Report {
+	b := t.get(e.BatchID)
+	if b.finished {
+		// Late arrival after the batch was already reported
+		return nil
+	}
+	if b.FirstSeen.IsZero() {
+		b.FirstSeen = now
+	}
+	b.LastSeen = now
+	if _, dup := b.Received[e.OriginalID]; dup {
+		b.Duplicate++
+		return nil
+	}
+	b.Received[e.OriginalID] = struct{}{}
+	delete(b.Dead, e.OriginalID)
+	return t.check(b)
+}
+
+// Expire finishes every ended batch whose grace period has passed, and
+// forgets the batches finished more than grace ago. Until then, late
+// entries of a finished batch are recognised and ignored.
+func (t *Tracker) Expire(now time.Time) []Report {
+	var out []Report
+	for id, b := range t.batches {
+		switch {
+		case b.finished:
+			if now.Sub(b.finishedAt) >= t.grace {
+				delete(t.batches, id)
+			}
+		case b.ended && now.Sub(b.endedAt) >= t.grace:
+			out = append(out, t.finish(b))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].BatchID < out[j].BatchID })
+	return out
+}
+
+// Open returns the number of batches that have not finished yet.
+func (t *Tracker) Open() int {
+	n := 0
+	for _, b := range t.batches {
+		if !b.finished {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *Tracker) check(b *Batch) *Report {
+	if b.finished || b.Expected == 0 || len(b.Received)+len(b.Dead) < b.Expected {
+		return nil
+	}
+	r := t.finish(b)
+	return &r
+}
+
+func (t *Tracker) finish(b *Batch) Report {
+	b.finished = true
+	b.finishedAt = time.Now()
+
+	var missing []string
+	for _, id := range b.sentIDs {
+		if _, ok := b.Received[id]; ok {
+			continue
+		}
+		if _, ok := b.Dead[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+
+	r := Report{
+		BatchID:   b.ID,
+		Expected:  b.Expected,
+		Received:  len(b.Received),
+		Duplicate: b.Duplicate,
+		Dead:      len(b.Dead),
+		Missing:   missing,
+		Complete:  len(b.Received)+len(b.Dead) >= b.Expected && len(missing) == 0,
+		Started:   b.Started,
+		FirstSeen: b.FirstSeen,
+		LastSeen:  b.LastSeen,
+	}
+	if !b.FirstSeen.IsZero() {
+		r.Duration = b.LastSeen.Sub(b.FirstSeen)
+	}
+
+	// Keep only the summary; the per-ID set can be large.
+	b.Received = nil
+	b.Dead = nil
+	b.sentIDs = nil
+	return r
+}
@@ -0,0 +1,100 @@
+package batch
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+func entry(batchID, id string) protocol.ProcessedLogEntry {
+	return protocol.ProcessedLogEntry{BatchID: batchID, OriginalID: id}
+}
+
+func control(typ protocol.ControlType, batchID string, ids ...string) protocol.BatchControl {
+	return protocol.BatchControl{Type: typ, BatchID: batchID, Expected: len(ids), IDs: ids, Timestamp: time.Now()}
+}
+
+func TestTrackerCompletes(t *testing.T) {
+	cases := []struct {
+		name  string
+		steps func(tr *Tracker) *Report
+	}{
+		{"entries after end", func(tr *Tracker) *Report {
+			tr.Control(control(protocol.BatchStart, "b1", "a", "b"))
+			tr.Control(control(protocol.BatchEnd, "b1", "a", "b"))
+			tr.Entry(entry("b1", "a"), time.Now())
+			return tr.Entry(entry("b1", "b"), time.Now())
+		}},
+		{"entries before start", func(tr *Tracker) *Report {
+			tr.Entry(entry("b1", "a"), time.Now())
+			tr.Entry(entry("b1", "b"), time.Now())
+			return tr.Control(control(protocol.BatchStart, "b1", "a", "b"))
+		}},
+		{"dead-lettered entry", func(tr *Tracker) *Report {
+			tr.Control(control(protocol.BatchStart, "b1", "a", "b"))
+			tr.Entry(entry("b1", "a"), time.Now())
+			return tr.Control(control(protocol.BatchDeadLetter, "b1", "b"))
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := NewTracker(time.Minute)
+			r := tc.steps(tr)
+			if r == nil {
+				t.Fatal("batch did not finish")
+			}
+			if !r.Complete || r.Expected != 2 || len(r.Missing) != 0 {
+				t.Errorf("report = %+v, want complete with 2 expected", *r)
+			}
+			if tr.Open() != 0 {
+				t.Errorf("Open() = %d, want 0", tr.Open())
+			}
+		})
+	}
+}
+
+func TestTrackerDetectsLoss(t *testing.T) {
+	tr := NewTracker(time.Second)
+	tr.Control(control(protocol.BatchStart, "b1", "a", "b", "c"))
+	tr.Entry(entry("b1", "a"), time.Now())
+	tr.Entry(entry("b1", "a"), time.Now())
+	if r := tr.Control(control(protocol.BatchEnd, "b1", "a", "b", "c")); r != nil {
+		t.Fatalf("batch finished before its grace period: %+v", *r)
+	}
+
+	if got := tr.Expire(time.Now()); len(got) != 0 {
+		t.Fatalf("Expire within grace = %d reports, want 0", len(got))
+	}
+	got := tr.Expire(time.Now().Add(2 * time.Second))
+	if len(got) != 1 {
+		t.Fatalf("Expire after grace = %d reports, want 1", len(got))
+	}
+	r := got[0]
+	if r.Complete || r.Received != 1 || r.Duplicate != 1 || !slices.Equal(r.Missing, []string{"b", "c"}) {
+		t.Errorf("report = %+v, want 1 received, 1 duplicate, b and c missing", r)
+	}
+}
+
+func TestTrackerForgetsFinishedBatches(t *testing.T) {
+	tr := NewTracker(time.Second)
+	tr.Control(control(protocol.BatchStart, "b1", "a"))
+	if r := tr.Entry(entry("b1", "a"), time.Now()); r == nil {
+		t.Fatal("batch did not finish")
+	}
+
+	// A late duplicate within the grace period is ignored, not a new batch
+	if r := tr.Entry(entry("b1", "a"), time.Now()); r != nil {
+		t.Fatalf("late entry reported again: %+v", *r)
+	}
+	tr.Expire(time.Now())
+	if len(tr.batches) != 1 {
+		t.Fatalf("finished batch dropped within grace, %d held", len(tr.batches))
+	}
+
+	tr.Expire(time.Now().Add(2 * time.Second))
+	if len(tr.batches) != 0 {
+		t.Errorf("finished batch kept after grace, %d held", len(tr.batches))
+	}
+}
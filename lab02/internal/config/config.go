@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 )
 
 // Config holds the configuration for the application.
@@ -12,13 +13,80 @@ type Config struct {
 	Host          string
 	CollectorPort int
 	SinkPort      int
+	DLQPort       int // dead-letter queue (DLQ consumer binds, Workers connect)
+	ReinjectPort  int // fixed dead letters back into the Collector (Collector binds)
+
+	// Collector sources
+	Sources       string        // comma separated source specs, see source.Parse
+	OffsetsFile   string        // persisted read offsets of tailed files
+	BatchInterval time.Duration // close a batch after this long even if not full
+
+	// Flow control
+	WorkerID      string        // DEALER identity of a worker, empty = <hostname>-<pid>
+	CreditWindow  int           // entries a worker accepts before it re-grants credit (Worker)
+	WorkerTimeout time.Duration // forget workers silent for this long (Collector); workers heartbeat at a third of it
+	StatsInterval time.Duration // per-worker throughput log interval (Collector)
+
+	// Worker
+	RedactionRules string // path to the redaction rules file, empty = built-in IPv4 rule
+	GeoIPDB        string // CIDR database (CSV) for country/ASN enrichment, empty = disabled
+	SeverityRules  string // path to the severity rules file, empty = level only
+
+	// Dead-letter queue
+	DLQFile string // JSON lines file the DLQ consumer stores dead letters in
+
+	// Sink storage
+	StorageDir      string        // directory for segment files and their index
+	SegmentMaxBytes int64         // rotate segments at this size
+	SegmentMaxAge   time.Duration // rotate segments at this age
+	Compress        bool          // gzip segments
+	Fsync           string        // "always", "interval" or "never"
+	FsyncInterval   time.Duration
+
+	// Sink deduplication
+	DedupCapacity      int     // IDs per Bloom generation
+	DedupFPRate        float64 // Bloom false-positive rate
+	DedupWindow        int     // recent IDs remembered exactly
+	CheckpointInterval time.Duration
+
+	// Batch accounting
+	BatchSize  int           // entries generated per batch (Collector)
+	BatchGrace time.Duration // wait after BATCH_END before declaring entries missing (Sink)
+	ExitAfter  int           // exit after this many finished batches, 0 = at STREAM_END only (Sink)
 }
 
 // LoadConfig loads configuration from command-line flags.
 func LoadConfig() *Config {
 	host := flag.String("host", "127.0.0.1", "Host IP for workers to connect to")
-	collectorPort := flag.Int("collector-port", 5557, "Port for the Log Collector (Ventilator)")
+	collectorPort := flag.Int("collector-port", 5557, "Port for the Log Collector (Ventilator, ROUTER)")
 	sinkPort := flag.Int("sink-port", 5558, "Port for the Storage Writer (Sink)")
+	dlqPort := flag.Int("dlq-port", 5559, "Port for the dead-letter queue (DLQ Consumer)")
+	reinjectPort := flag.Int("reinject-port", 5560, "Port for re-injected entries (Collector 'reinject' source)")
+
+	sources := flag.String("sources", "synthetic", "Comma separated log sources: synthetic, file:<path>, syslog-udp:<addr>, syslog-tcp:<addr>, reinject[:<endpoint>] (Collector)")
+	offsetsFile := flag.String("offsets-file", "collector_offsets.json", "File for persisted tail offsets (Collector)")
+	batchInterval := flag.Duration("batch-interval", 5*time.Second, "Close a batch after this long even if not full (Collector)")
+	workerID := flag.String("worker-id", "", "Worker identity on the collector channel, default <hostname>-<pid> (Worker)")
+	creditWindow := flag.Int("credit-window", 100, "Entries a worker may have in flight (Worker)")
+	workerTimeout := flag.Duration("worker-timeout", 3*time.Second, "Drop workers that sent no credit or heartbeat for this long (Collector, Worker)")
+	statsInterval := flag.Duration("stats-interval", 5*time.Second, "Per-worker throughput log interval (Collector)")
+	redactionRules := flag.String("redaction-rules", "", "Path to the redaction rules JSON file (Worker, reloaded on SIGHUP)")
+	geoIPDB := flag.String("geoip-db", "", "CIDR to country/ASN database in CSV form (Worker, reloaded on SIGHUP)")
+	severityRules := flag.String("severity-rules", "", "Path to the severity rules JSON file (Worker, reloaded on SIGHUP)")
+	dlqFile := flag.String("dlq-file", "dead_letters.jsonl", "File the dead letters are stored in (DLQ Consumer)")
+	storageDir := flag.String("storage-dir", "log_data", "Directory for stored log segments (Sink, Reader)")
+	segmentMaxBytes := flag.Int64("segment-max-bytes", 16*1024*1024, "Rotate segments at this size in bytes (Sink)")
+	segmentMaxAge := flag.Duration("segment-max-age", time.Hour, "Rotate segments at this age (Sink)")
+	compress := flag.Bool("compress", false, "Gzip segment files (Sink)")
+	fsync := flag.String("fsync", "interval", "Fsync policy: always, interval or never (Sink)")
+	fsyncInterval := flag.Duration("fsync-interval", time.Second, "Fsync interval for the 'interval' policy (Sink)")
+	dedupCapacity := flag.Int("dedup-capacity", 1000000, "IDs per Bloom filter generation, two are kept (Sink)")
+	dedupFPRate := flag.Float64("dedup-fp-rate", 0.001, "Bloom filter false-positive rate (Sink)")
+	dedupWindow := flag.Int("dedup-window", 100000, "Most recent IDs remembered exactly (Sink)")
+	checkpointInterval := flag.Duration("checkpoint-interval", 5*time.Second, "Dedup checkpoint interval (Sink)")
+	batchSize := flag.Int("batch-size", 10000, "Number of log entries per batch (Collector)")
+	batchGrace := flag.Duration("batch-grace", 5*time.Second, "Time to wait after BATCH_END for in-flight entries (Sink)")
+	exitAfter := flag.Int("exit-after", 0, "Sink exits after this many finished batches; 0 = only once the Collector's sources are exhausted (Sink)")
 
 	flag.Parse()
 
@@ -31,16 +99,57 @@ func LoadConfig() *Config {
 			*collectorPort = p
 		}
 	}
+	if v := os.Getenv("LAB02_STORAGE_DIR"); v != "" {
+		*storageDir = v
+	}
 	if v := os.Getenv("LAB02_SINK_PORT"); v != "" {
 		if p, err := strconv.Atoi(v); err == nil {
 			*sinkPort = p
 		}
 	}
+	if v := os.Getenv("LAB02_DLQ_PORT"); v != "" {
+		if p, err := strconv.Atoi(v); err == nil {
+			*dlqPort = p
+		}
+	}
 
 	return &Config{
 		Host:          *host,
 		CollectorPort: *collectorPort,
 		SinkPort:      *sinkPort,
+		DLQPort:       *dlqPort,
+		ReinjectPort:  *reinjectPort,
+
+		Sources:       *sources,
+		OffsetsFile:   *offsetsFile,
+		BatchInterval: *batchInterval,
+
+		WorkerID:      *workerID,
+		CreditWindow:  *creditWindow,
+		WorkerTimeout: *workerTimeout,
+		StatsInterval: *statsInterval,
+
+		RedactionRules: *redactionRules,
+		GeoIPDB:        *geoIPDB,
+		SeverityRules:  *severityRules,
+
+		DLQFile: *dlqFile,
+
+		StorageDir:      *storageDir,
+		SegmentMaxBytes: *segmentMaxBytes,
+		SegmentMaxAge:   *segmentMaxAge,
+		Compress:        *compress,
+		Fsync:           *fsync,
+		FsyncInterval:   *fsyncInterval,
+
+		DedupCapacity:      *dedupCapacity,
+		DedupFPRate:        *dedupFPRate,
+		DedupWindow:        *dedupWindow,
+		CheckpointInterval: *checkpointInterval,
+
+		BatchSize:  *batchSize,
+		BatchGrace: *batchGrace,
+		ExitAfter:  *exitAfter,
 	}
 }
 
@@ -63,3 +172,23 @@ func (c *Config) SinkBindAddr() string {
 func (c *Config) SinkConnectAddr() string {
 	return fmt.Sprintf("tcp://%s:%d", c.Host, c.SinkPort)
 }
+
+// DLQBindAddr returns the endpoint for the DLQ consumer to bind to.
+func (c *Config) DLQBindAddr() string {
+	return fmt.Sprintf("tcp://*:%d", c.DLQPort)
+}
+
+// DLQConnectAddr returns the endpoint for Workers to push dead letters to.
+func (c *Config) DLQConnectAddr() string {
+	return fmt.Sprintf("tcp://%s:%d", c.Host, c.DLQPort)
+}
+
+// ReinjectBindAddr returns the endpoint the Collector's reinject source binds to.
+func (c *Config) ReinjectBindAddr() string {
+	return fmt.Sprintf("tcp://*:%d", c.ReinjectPort)
+}
+
+// ReinjectConnectAddr returns the endpoint the DLQ consumer re-injects entries to.
+func (c *Config) ReinjectConnectAddr() string {
+	return fmt.Sprintf("tcp://%s:%d", c.Host, c.ReinjectPort)
+}
@@ -0,0 +1,65 @@
+package dedup
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// bloom is a fixed-size Bloom filter using double hashing (Kirsch-Mitzenmacher).
+type bloom struct {
+	bits  []uint64
+	k     int
+	count int // items added
+}
+
+// newBloom sizes a filter for n items at false-positive rate p.
+func newBloom(n int, p float64) *bloom {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.001
+	}
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloom{bits: make([]uint64, (int(m)+63)/64), k: k}
+}
+
+func (b *bloom) m() uint64 {
+	return uint64(len(b.bits)) * 64
+}
+
+func (b *bloom) add(id string) {
+	h1, h2 := hashes(id)
+	m := b.m()
+	for i := 0; i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+	b.count++
+}
+
+func (b *bloom) has(id string) bool {
+	h1, h2 := hashes(id)
+	m := b.m()
+	for i := 0; i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hashes derives two independent 64-bit hashes of id.
+func hashes(id string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	h1 := h.Sum64()
+	h.Write([]byte{0xff})
+	h2 := h.Sum64() | 1 // odd, so the probe sequence covers the table
+	return h1, h2
+}
@@ -0,0 +1,135 @@
+package dedup
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// checkpointVersion is bumped when the checkpoint layout changes.
+const checkpointVersion = 1
+
+// Generation is one persisted Bloom generation.
+type Generation struct {
+	Bits  []byte `json:"bits"` // little-endian words, base64 in JSON
+	K     int    `json:"k"`
+	Count int    `json:"count"`
+}
+
+// Checkpoint is the persisted state of the Sink's deduplication.
+// SegmentFile/SegmentCount record how far the segment store had been written
+// (and fsynced) when the checkpoint was taken; records after that point are
+// replayed into the filter on startup.
+type Checkpoint struct {
+	Version      int          `json:"version"`
+	SavedAt      time.Time    `json:"saved_at"`
+	SegmentFile  string       `json:"segment_file"`
+	SegmentCount int          `json:"segment_count"`
+	Stats        Stats        `json:"stats"`
+	Window       []string     `json:"window"`      // oldest first
+	Generations  []Generation `json:"generations"` // oldest first
+}
+
+// Checkpoint captures the filter state; the caller fills in the segment position.
+func (f *Filter) Checkpoint() *Checkpoint {
+	cp := &Checkpoint{
+		Version: checkpointVersion,
+		Stats:   f.stats,
+		Window:  f.win.ids(),
+	}
+	if f.prev != nil {
+		cp.Generations = append(cp.Generations, encodeBloom(f.prev))
+	}
+	cp.Generations = append(cp.Generations, encodeBloom(f.cur))
+	return cp
+}
+
+// Restore creates a filter from a checkpoint. Generations keep the size they
+// were created with; new ones use opts.
+func Restore(opts Options, cp *Checkpoint) (*Filter, error) {
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d", cp.Version)
+	}
+	f := NewFilter(opts)
+	f.stats = cp.Stats
+	for _, id := range cp.Window {
+		f.win.add(id)
+	}
+	switch len(cp.Generations) {
+	case 0:
+	case 1:
+		f.cur = decodeBloom(cp.Generations[0])
+	default:
+		n := len(cp.Generations)
+		f.prev = decodeBloom(cp.Generations[n-2])
+		f.cur = decodeBloom(cp.Generations[n-1])
+	}
+	for _, b := range []*bloom{f.prev, f.cur} {
+		if b != nil && (len(b.bits) == 0 || b.k < 1) {
+			return nil, errors.New("empty bloom generation")
+		}
+	}
+	return f, nil
+}
+
+// LoadCheckpoint reads a checkpoint. A missing file returns nil, nil.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// Save writes the checkpoint atomically (temp file + fsync + rename).
+func (cp *Checkpoint) Save(path string) error {
+	cp.SavedAt = time.Now()
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	// Sync before the rename, or a crash can leave an empty file in its place
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func encodeBloom(b *bloom) Generation {
+	buf := make([]byte, len(b.bits)*8)
+	for i, w := range b.bits {
+		binary.LittleEndian.PutUint64(buf[i*8:], w)
+	}
+	return Generation{Bits: buf, K: b.k, Count: b.count}
+}
+
+func decodeBloom(g Generation) *bloom {
+	words := make([]uint64, len(g.Bits)/8)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint64(g.Bits[i*8:])
+	}
+	return &bloom{bits: words, k: g.K, count: g.Count}
+}
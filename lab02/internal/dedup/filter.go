@@ -0,0 +1,148 @@
+package dedup
+
+// Verdict is the outcome of checking an ID against the filter.
+type Verdict int
+
+const (
+	New       Verdict = iota // never seen
+	Duplicate                // in the exact recent window
+	Probable                 // older than the window, but the Bloom filter has it (may be a false positive)
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Duplicate:
+		return "duplicate"
+	case Probable:
+		return "probable_duplicate"
+	}
+	return "new"
+}
+
+// Options sizes a Filter.
+type Options struct {
+	Capacity int     // IDs per Bloom generation; two generations are kept
+	FPRate   float64 // target false-positive rate of one generation
+	Window   int     // most recent IDs remembered exactly
+}
+
+// Stats counts verdicts over the life of the filter, across restarts.
+type Stats struct {
+	Unique    int `json:"unique"`
+	Duplicate int `json:"duplicate"`
+	Probable  int `json:"probable"`
+}
+
+// Filter remembers which IDs were already seen with bounded memory.
+// The exact window catches the common case (redelivery shortly after the
+// original) without false positives; the Bloom filter extends the memory to
+// roughly 2 × Capacity IDs. When the current generation is full it becomes the
+// previous one and the oldest generation is dropped.
+// It is not safe for concurrent use.
+type Filter struct {
+	opts  Options
+	cur   *bloom
+	prev  *bloom
+	win   *window
+	stats Stats
+}
+
+// NewFilter creates an empty filter.
+func NewFilter(opts Options) *Filter {
+	if opts.Capacity < 1 {
+		opts.Capacity = 1
+	}
+	return &Filter{
+		opts: opts,
+		cur:  newBloom(opts.Capacity, opts.FPRate),
+		win:  newWindow(opts.Window),
+	}
+}
+
+// Check classifies id without recording it.
+func (f *Filter) Check(id string) Verdict {
+	if f.win.has(id) {
+		return Duplicate
+	}
+	if f.cur.has(id) || (f.prev != nil && f.prev.has(id)) {
+		return Probable
+	}
+	return New
+}
+
+// Seen classifies id, records it unless it is a known duplicate and updates
+// the stats. A probable duplicate may be a false positive, so the caller
+// keeps it; remembering it exactly makes a redelivery of it a Duplicate.
+func (f *Filter) Seen(id string) Verdict {
+	v := f.Check(id)
+	f.Record(id, v)
+	return v
+}
+
+// Record counts id under verdict v from Check and remembers it unless it is
+// a duplicate. A caller that stores entries checks first and records only
+// once the entry is stored, so an entry that failed to store is still New
+// when it is redelivered.
+func (f *Filter) Record(id string, v Verdict) {
+	switch v {
+	case New:
+		f.add(id)
+		f.stats.Unique++
+	case Duplicate:
+		f.stats.Duplicate++
+	case Probable:
+		f.win.add(id)
+		f.stats.Probable++
+	}
+}
+
+// Stats returns the verdict counters.
+func (f *Filter) Stats() Stats {
+	return f.stats
+}
+
+func (f *Filter) add(id string) {
+	if f.cur.count >= f.opts.Capacity {
+		f.prev = f.cur
+		f.cur = newBloom(f.opts.Capacity, f.opts.FPRate)
+	}
+	f.cur.add(id)
+	f.win.add(id)
+}
+
+// window is a FIFO set of the most recent IDs.
+type window struct {
+	ring []string
+	next int
+	set  map[string]struct{}
+}
+
+func newWindow(size int) *window {
+	if size < 1 {
+		size = 1
+	}
+	return &window{ring: make([]string, 0, size), set: make(map[string]struct{}, size)}
+}
+
+func (w *window) has(id string) bool {
+	_, ok := w.set[id]
+	return ok
+}
+
+func (w *window) add(id string) {
+	if len(w.ring) < cap(w.ring) {
+		w.ring = append(w.ring, id)
+	} else {
+		delete(w.set, w.ring[w.next])
+		w.ring[w.next] = id
+		w.next = (w.next + 1) % len(w.ring)
+	}
+	w.set[id] = struct{}{}
+}
+
+// ids returns the window oldest first.
+func (w *window) ids() []string {
+	out := make([]string, 0, len(w.ring))
+	out = append(out, w.ring[w.next:]...)
+	return append(out, w.ring[:w.next]...)
+}
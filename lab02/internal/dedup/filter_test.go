@@ -0,0 +1,154 @@
+package dedup
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFilterVerdicts(t *testing.T) {
+	f := NewFilter(Options{Capacity: 100, FPRate: 0.001, Window: 3})
+
+	steps := []struct {
+		id   string
+		want Verdict
+	}{
+		{"a", New},
+		{"b", New},
+		{"a", Duplicate},
+		{"c", New},
+		{"d", New},       // pushes "a" out of the window
+		{"a", Probable},  // only the Bloom filter remembers it
+		{"a", Duplicate}, // back in the window
+		{"e", New},
+	}
+	for i, s := range steps {
+		if got := f.Seen(s.id); got != s.want {
+			t.Errorf("step %d: Seen(%q) = %s, want %s", i, s.id, got, s.want)
+		}
+	}
+	want := Stats{Unique: 5, Duplicate: 2, Probable: 1}
+	if got := f.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWindowOrder(t *testing.T) {
+	w := newWindow(3)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		w.add(id)
+	}
+	if got := w.ids(); !slices.Equal(got, []string{"c", "d", "e"}) {
+		t.Errorf("ids() = %v, want [c d e]", got)
+	}
+	if w.has("b") || !w.has("c") {
+		t.Error("window kept an evicted ID or lost a recent one")
+	}
+}
+
+func TestBloomFalsePositiveRate(t *testing.T) {
+	const n = 10000
+	b := newBloom(n, 0.01)
+	for i := range n {
+		b.add(fmt.Sprintf("in-%d", i))
+	}
+	for i := range n {
+		if !b.has(fmt.Sprintf("in-%d", i)) {
+			t.Fatalf("false negative for in-%d", i)
+		}
+	}
+	fp := 0
+	for i := range n {
+		if b.has(fmt.Sprintf("out-%d", i)) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / n; rate > 0.02 {
+		t.Errorf("false positive rate %.4f, want about 0.01", rate)
+	}
+}
+
+func TestFilterGenerations(t *testing.T) {
+	f := NewFilter(Options{Capacity: 2, FPRate: 0.001, Window: 1})
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		f.Seen(id)
+	}
+	// "a" and "b" filled the first generation, which was dropped when "e"
+	// started the third
+	if got := f.Check("c"); got != Probable {
+		t.Errorf("Check(c) = %s, want probable from the previous generation", got)
+	}
+	if got := f.Check("a"); got != New {
+		t.Errorf("Check(a) = %s, want new after its generation was dropped", got)
+	}
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	opts := Options{Capacity: 3, FPRate: 0.001, Window: 2}
+	f := NewFilter(opts)
+	for _, id := range []string{"a", "b", "c", "d", "a"} {
+		f.Seen(id)
+	}
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	cp := f.Checkpoint()
+	cp.SegmentFile, cp.SegmentCount = "segment-000001.jsonl", 4
+	if err := cp.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.SegmentFile != cp.SegmentFile || loaded.SegmentCount != 4 || loaded.SavedAt.IsZero() {
+		t.Errorf("loaded position %s:%d saved %v", loaded.SegmentFile, loaded.SegmentCount, loaded.SavedAt)
+	}
+
+	r, err := Restore(opts, loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Stats() != f.Stats() {
+		t.Errorf("restored stats %+v, want %+v", r.Stats(), f.Stats())
+	}
+	for _, id := range []string{"a", "b", "c", "d", "x"} {
+		if got, want := r.Check(id), f.Check(id); got != want {
+			t.Errorf("Check(%q) = %s after restore, want %s", id, got, want)
+		}
+	}
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	cp, err := LoadCheckpoint(filepath.Join(dir, "missing.json"))
+	if cp != nil || err != nil {
+		t.Errorf("missing checkpoint = %v, %v; want nil, nil", cp, err)
+	}
+
+	future := &Checkpoint{Version: checkpointVersion + 1}
+	if _, err := Restore(Options{}, future); err == nil {
+		t.Error("Restore accepted an unknown version")
+	}
+}
+
+func TestFilterCheckThenRecord(t *testing.T) {
+	f := NewFilter(Options{Capacity: 100, FPRate: 0.001, Window: 10})
+
+	// A failed write leaves the ID unrecorded, so its redelivery is new
+	if v := f.Check("a"); v != New {
+		t.Fatalf("Check(a) = %s, want new", v)
+	}
+	if v := f.Check("a"); v != New {
+		t.Errorf("Check(a) = %s after an unrecorded check, want new", v)
+	}
+
+	f.Record("a", f.Check("a"))
+	if v := f.Check("a"); v != Duplicate {
+		t.Errorf("Check(a) = %s after Record, want duplicate", v)
+	}
+	f.Record("a", Duplicate)
+	if want := (Stats{Unique: 1, Duplicate: 1}); f.Stats() != want {
+		t.Errorf("Stats() = %+v, want %+v", f.Stats(), want)
+	}
+}
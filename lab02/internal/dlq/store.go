@@ -0,0 +1,167 @@
+package dlq
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+// Record is one stored dead letter. To re-inject an entry, an operator either
+// fixes whatever made it fail (e.g. the rules) or writes the corrected
+// LogEntry JSON into Fixed.
+type Record struct {
+	protocol.DeadLetter
+	Fixed json.RawMessage `json:"fixed,omitempty"`
+}
+
+// Entry returns the LogEntry to re-inject: Fixed if present, else the original bytes.
+func (r *Record) Entry() (protocol.LogEntry, error) {
+	data := []byte(r.Fixed)
+	if len(data) == 0 {
+		data = r.Original
+	}
+	var e protocol.LogEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return e, err
+	}
+	if e.ID == "" {
+		return e, errors.New("missing id")
+	}
+	if !e.Level.Valid() {
+		return e, fmt.Errorf("unknown level %q", e.Level)
+	}
+	return e, nil
+}
+
+// Appender appends records to a JSON lines file.
+type Appender struct {
+	file *os.File
+	enc  *json.Encoder
+}
+
+// OpenAppender opens path for appending, creating it if needed.
+func OpenAppender(path string) (*Appender, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Appender{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Append writes r as one line. Each line goes straight to the file,
+// dead letters are rare and should survive a crash.
+func (a *Appender) Append(r Record) error {
+	return a.enc.Encode(r)
+}
+
+func (a *Appender) Close() error {
+	return a.file.Close()
+}
+
+// ReinjectedPath returns the file that lists the re-injected records of the
+// store at path. The store itself is only ever appended to, so a running
+// consumer can keep writing while another process re-injects.
+func ReinjectedPath(path string) string {
+	return path + ".reinjected"
+}
+
+// Key identifies a record by its dead letter, so it stays the same when an
+// operator edits Fixed or reorders the file.
+func (r *Record) Key() (string, error) {
+	data, err := json.Marshal(r.DeadLetter)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// Load reads the records of path that have not been re-injected. A missing
+// file is an empty store.
+func Load(path string) ([]Record, error) {
+	done, err := loadReinjected(ReinjectedPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []Record
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		key, err := r.Key()
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if !done[key] {
+			out = append(out, r)
+		}
+	}
+	return out, sc.Err()
+}
+
+// loadReinjected reads the keys listed in path. A missing file lists none.
+func loadReinjected(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool)
+	for _, key := range strings.Fields(string(data)) {
+		done[key] = true
+	}
+	return done, nil
+}
+
+// MarkReinjected appends the keys of records to the re-injected list of the
+// store at path, so Load leaves them out from then on. The list is synced
+// before it returns.
+func MarkReinjected(path string, records []Record) error {
+	var buf bytes.Buffer
+	for i := range records {
+		key, err := records[i].Key()
+		if err != nil {
+			return err
+		}
+		buf.WriteString(key + "\n")
+	}
+	f, err := os.OpenFile(ReinjectedPath(path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
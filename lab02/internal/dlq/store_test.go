@@ -0,0 +1,57 @@
+package dlq
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+func TestMarkReinjected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead_letters.jsonl")
+	a, err := OpenAppender(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, id := range []string{"a", "b"} {
+		if err := a.Append(Record{DeadLetter: protocol.DeadLetter{OriginalID: id, Stage: protocol.StageValidate, Timestamp: now}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := Load(path)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Load = %d records, %v; want 2", len(records), err)
+	}
+	// An operator's fix does not change which record it is
+	records[0].Fixed = []byte(`{"id":"a","level":"INFO"}`)
+	if err := MarkReinjected(path, records[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// The appender still writes to the store the next Load reads
+	if err := a.Append(Record{DeadLetter: protocol.DeadLetter{OriginalID: "c", Stage: protocol.StageDecode, Timestamp: now}}); err != nil {
+		t.Fatal(err)
+	}
+	records, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, r := range records {
+		ids = append(ids, r.OriginalID)
+	}
+	if len(ids) != 2 || ids[0] != "b" || ids[1] != "c" {
+		t.Errorf("Load after re-injecting a = %v, want [b c]", ids)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	records, err := Load(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if records != nil || err != nil {
+		t.Errorf("Load = %v, %v; want nil, nil", records, err)
+	}
+}
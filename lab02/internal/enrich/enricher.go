@@ -0,0 +1,98 @@
+package enrich
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+// Tag keys set by the enrichment stage
+const (
+	TagCountry       = "country"
+	TagASN           = "asn"
+	TagASOrg         = "as_org"
+	TagSeverityRules = "severity_rules" // comma separated names of matched severity rules
+)
+
+// ipCandidate finds things that look like IPv4 or IPv6 addresses; netip decides.
+var ipCandidate = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|(?i:[0-9a-f]{0,4}:[0-9a-f:]*:[0-9a-f]*(?:\.\d+){0,3})`)
+
+// Enricher runs before redaction, while addresses are still in the message.
+// It is immutable once built, so a reload can swap in a new one.
+type Enricher struct {
+	geo      *GeoDB // nil = no GeoIP lookup
+	severity *Classifier
+}
+
+// Result is what enrichment adds to an entry
+type Result struct {
+	Severity int
+	Tags     map[string]string
+}
+
+// Load builds an Enricher from a CIDR database (CSV, optional) and a severity rules file (optional).
+func Load(geoPath, severityPath string) (*Enricher, error) {
+	e := &Enricher{}
+	if geoPath != "" {
+		db, err := LoadGeoCSV(geoPath)
+		if err != nil {
+			return nil, err
+		}
+		e.geo = db
+	}
+	c, err := LoadSeverityRules(severityPath)
+	if err != nil {
+		return nil, err
+	}
+	e.severity = c
+	return e, nil
+}
+
+// Networks returns the size of the GeoIP database.
+func (e *Enricher) Networks() int {
+	if e.geo == nil {
+		return 0
+	}
+	return e.geo.Len()
+}
+
+// SeverityRules returns the number of severity rules.
+func (e *Enricher) SeverityRules() int {
+	return e.severity.Rules()
+}
+
+// Apply scores entry and tags it with the location of the first address in
+// the message the database knows. The address itself is not copied into the tags.
+func (e *Enricher) Apply(entry protocol.LogEntry) Result {
+	res := Result{Tags: make(map[string]string)}
+
+	var matched []string
+	res.Severity, matched = e.severity.Score(entry.Level, entry.Message)
+	if len(matched) > 0 {
+		res.Tags[TagSeverityRules] = strings.Join(matched, ",")
+	}
+
+	if e.geo != nil {
+		for _, m := range ipCandidate.FindAllString(entry.Message, -1) {
+			addr, err := netip.ParseAddr(m)
+			if err != nil {
+				continue
+			}
+			rec, ok := e.geo.Lookup(addr)
+			if !ok {
+				continue
+			}
+			res.Tags[TagCountry] = rec.Country
+			if rec.ASN != "" {
+				res.Tags[TagASN] = rec.ASN
+			}
+			if rec.Org != "" {
+				res.Tags[TagASOrg] = rec.Org
+			}
+			break
+		}
+	}
+	return res
+}
@@ -0,0 +1,121 @@
+package enrich
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+const testGeoCSV = `# cidr,country,asn,org
+81.2.0.0/16,GB,20712,Andrews & Arnold Ltd
+81.2.69.0/24,DE,AS3320,Deutsche Telekom AG
+2001:db8::/32,NL,,
+10.0.0.0/8,zz
+`
+
+func loadTestGeo(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "geo.csv")
+	if err := os.WriteFile(path, []byte(testGeoCSV), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGeoLookup(t *testing.T) {
+	db, err := LoadGeoCSV(loadTestGeo(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", db.Len())
+	}
+
+	cases := []struct {
+		addr string
+		want GeoRecord
+		ok   bool
+	}{
+		{"81.2.69.142", GeoRecord{"DE", "AS3320", "Deutsche Telekom AG"}, true}, // longest prefix wins
+		{"81.2.1.1", GeoRecord{"GB", "AS20712", "Andrews & Arnold Ltd"}, true},
+		{"::ffff:81.2.1.1", GeoRecord{"GB", "AS20712", "Andrews & Arnold Ltd"}, true},
+		{"2001:db8::1", GeoRecord{Country: "NL"}, true},
+		{"10.1.2.3", GeoRecord{Country: "ZZ"}, true},
+		{"192.0.2.1", GeoRecord{}, false},
+		{"2001:db9::1", GeoRecord{}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.addr, func(t *testing.T) {
+			got, ok := db.Lookup(netip.MustParseAddr(tc.addr))
+			if ok != tc.ok || got != tc.want {
+				t.Errorf("Lookup = %+v, %v; want %+v, %v", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestSeverityScore(t *testing.T) {
+	c, err := CompileSeverity(SeverityConfig{
+		Levels: map[protocol.LogLevel]int{"warn": 50},
+		Rules: []SeverityRuleConfig{
+			{Name: "auth", Keywords: []string{"Login Failed"}, Score: 20},
+			{Name: "disk", Pattern: `disk \d+% full`, Score: 40},
+			{Name: "noise", Keywords: []string{"healthcheck"}, Score: -30},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		level   protocol.LogLevel
+		msg     string
+		score   int
+		matched []string
+	}{
+		{"level only", protocol.INFO, "started", 10, nil},
+		{"level override", protocol.WARN, "slow", 50, nil},
+		{"keyword any case", protocol.INFO, "LOGIN FAILED for bob", 30, []string{"auth"}},
+		{"capped", protocol.ERROR, "login failed, disk 99% full", MaxSeverity, []string{"auth", "disk"}},
+		{"floored", protocol.DEBUG, "healthcheck ok", 0, []string{"noise"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			score, matched := c.Score(tc.level, tc.msg)
+			if score != tc.score || !slices.Equal(matched, tc.matched) {
+				t.Errorf("Score = %d, %v; want %d, %v", score, matched, tc.score, tc.matched)
+			}
+		})
+	}
+
+	if _, err := CompileSeverity(SeverityConfig{Rules: []SeverityRuleConfig{{Name: "empty", Score: 1}}}); err == nil {
+		t.Error("a rule without keywords or pattern compiled")
+	}
+}
+
+func TestEnricherApply(t *testing.T) {
+	e, err := Load(loadTestGeo(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := e.Apply(protocol.LogEntry{Level: protocol.WARN, Message: "denied 192.0.2.1 then 81.2.69.142 and 81.2.1.1"})
+	want := map[string]string{TagCountry: "DE", TagASN: "AS3320", TagASOrg: "Deutsche Telekom AG"}
+	if res.Severity != 40 || len(res.Tags) != len(want) {
+		t.Fatalf("Apply = %+v, want severity 40 and tags %v", res, want)
+	}
+	for k, v := range want {
+		if res.Tags[k] != v {
+			t.Errorf("tag %s = %q, want %q", k, res.Tags[k], v)
+		}
+	}
+
+	if res := e.Apply(protocol.LogEntry{Level: protocol.INFO, Message: "no address here"}); len(res.Tags) != 0 {
+		t.Errorf("tags %v for a message without addresses", res.Tags)
+	}
+}
@@ -0,0 +1,134 @@
+package enrich
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GeoRecord is what the CIDR database knows about a network.
+type GeoRecord struct {
+	Country string // ISO 3166 code, e.g. "DE"
+	ASN     string // e.g. "AS3320"
+	Org     string // AS organisation
+}
+
+// v6Offset keeps IPv4 and IPv6 prefix lengths apart in GeoDB.byLen.
+const v6Offset = 1000
+
+// GeoDB maps CIDR prefixes to GeoRecords with longest-prefix matching.
+// It is immutable once loaded.
+type GeoDB struct {
+	byLen map[int]map[netip.Prefix]GeoRecord // prefix length (+v6Offset for IPv6) -> masked prefix -> record
+	lens  []int                              // keys of byLen, longest first
+	size  int
+}
+
+// LoadGeoCSV reads a CIDR database in CSV form:
+//
+//	# cidr,country,asn,org
+//	81.2.69.0/24,GB,AS20712,Andrews & Arnold Ltd
+//
+// Lines starting with '#' are comments. asn and org may be empty.
+func LoadGeoCSV(path string) (*GeoDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	db := &GeoDB{byLen: make(map[int]map[netip.Prefix]GeoRecord)}
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(row) < 2 {
+			line, _ := r.FieldPos(0)
+			return nil, fmt.Errorf("%s:%d: need at least cidr,country", path, line)
+		}
+		p, err := netip.ParsePrefix(strings.TrimSpace(row[0]))
+		if err != nil {
+			line, _ := r.FieldPos(0)
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		rec := GeoRecord{Country: strings.ToUpper(strings.TrimSpace(row[1]))}
+		if len(row) > 2 {
+			rec.ASN = normalizeASN(row[2])
+		}
+		if len(row) > 3 {
+			rec.Org = strings.TrimSpace(row[3])
+		}
+		db.add(p, rec)
+	}
+	return db, nil
+}
+
+func (db *GeoDB) add(p netip.Prefix, rec GeoRecord) {
+	p = p.Masked()
+	bits := p.Bits()
+	if !p.Addr().Is4() {
+		bits += v6Offset
+	}
+	m, ok := db.byLen[bits]
+	if !ok {
+		m = make(map[netip.Prefix]GeoRecord)
+		db.byLen[bits] = m
+		db.lens = append(db.lens, bits)
+		sort.Sort(sort.Reverse(sort.IntSlice(db.lens)))
+	}
+	if _, dup := m[p]; !dup {
+		db.size++
+	}
+	m[p] = rec
+}
+
+// Len returns the number of networks in the database.
+func (db *GeoDB) Len() int {
+	return db.size
+}
+
+// Lookup returns the record of the most specific network containing addr.
+func (db *GeoDB) Lookup(addr netip.Addr) (GeoRecord, bool) {
+	addr = addr.Unmap()
+	offset := 0
+	if !addr.Is4() {
+		offset = v6Offset
+	}
+	for _, l := range db.lens {
+		bits := l - offset
+		if bits < 0 || bits > addr.BitLen() {
+			continue
+		}
+		p, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if rec, ok := db.byLen[l][p]; ok {
+			return rec, true
+		}
+	}
+	return GeoRecord{}, false
+}
+
+// normalizeASN accepts "3320" or "AS3320".
+func normalizeASN(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" || strings.HasPrefix(s, "AS") {
+		return s
+	}
+	return "AS" + s
+}
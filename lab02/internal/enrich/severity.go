@@ -0,0 +1,134 @@
+package enrich
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+// MaxSeverity caps the severity score.
+const MaxSeverity = 100
+
+// SeverityRuleConfig is one entry of the severity rules file. A rule matches
+// if any keyword appears in the message (case-insensitive) or the pattern matches.
+type SeverityRuleConfig struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`
+	Score    int      `json:"score"`
+}
+
+// SeverityConfig is the layout of the severity rules file
+type SeverityConfig struct {
+	Levels map[protocol.LogLevel]int `json:"levels,omitempty"` // base score per level
+	Rules  []SeverityRuleConfig      `json:"rules"`
+}
+
+// DefaultLevelScores is used for levels the rules file does not mention.
+var DefaultLevelScores = map[protocol.LogLevel]int{
+	protocol.DEBUG: 0,
+	protocol.INFO:  10,
+	protocol.WARN:  40,
+	protocol.ERROR: 70,
+}
+
+type severityRule struct {
+	name     string
+	keywords []string // lower case
+	re       *regexp.Regexp
+	score    int
+}
+
+// Classifier scores entries from their level plus every matching rule, capped at MaxSeverity.
+type Classifier struct {
+	levels map[protocol.LogLevel]int
+	rules  []severityRule
+}
+
+// LoadSeverityRules reads and compiles a severity rules file.
+// An empty path returns a classifier that only scores the level.
+func LoadSeverityRules(path string) (*Classifier, error) {
+	var cfg SeverityConfig
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	return CompileSeverity(cfg)
+}
+
+// CompileSeverity validates a configuration and builds a Classifier.
+func CompileSeverity(cfg SeverityConfig) (*Classifier, error) {
+	c := &Classifier{levels: make(map[protocol.LogLevel]int)}
+	for l, s := range DefaultLevelScores {
+		c.levels[l] = s
+	}
+	for l, s := range cfg.Levels {
+		c.levels[protocol.LogLevel(strings.ToUpper(string(l)))] = s
+	}
+
+	for i, rc := range cfg.Rules {
+		if rc.Name == "" {
+			rc.Name = fmt.Sprintf("severity-%d", i)
+		}
+		if len(rc.Keywords) == 0 && rc.Pattern == "" {
+			return nil, fmt.Errorf("rule %s: needs keywords or a pattern", rc.Name)
+		}
+		r := severityRule{name: rc.Name, score: rc.Score}
+		for _, k := range rc.Keywords {
+			r.keywords = append(r.keywords, strings.ToLower(k))
+		}
+		if rc.Pattern != "" {
+			re, err := regexp.Compile(rc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rc.Name, err)
+			}
+			r.re = re
+		}
+		c.rules = append(c.rules, r)
+	}
+	return c, nil
+}
+
+// Rules returns the number of compiled rules.
+func (c *Classifier) Rules() int {
+	return len(c.rules)
+}
+
+// Score returns the severity of a message and the names of the rules that matched.
+func (c *Classifier) Score(level protocol.LogLevel, message string) (int, []string) {
+	score := c.levels[level]
+	lower := strings.ToLower(message)
+
+	var matched []string
+	for _, r := range c.rules {
+		if r.matches(message, lower) {
+			score += r.score
+			matched = append(matched, r.name)
+		}
+	}
+	if score > MaxSeverity {
+		score = MaxSeverity
+	}
+	if score < 0 {
+		score = 0
+	}
+	return score, matched
+}
+
+func (r *severityRule) matches(message, lower string) bool {
+	for _, k := range r.keywords {
+		if strings.Contains(lower, k) {
+			return true
+		}
+	}
+	return r.re != nil && r.re.MatchString(message)
+}
@@ -0,0 +1,163 @@
+package flow
+
+import (
+	"sort"
+	"time"
+)
+
+// Worker is the Collector's view of one connected worker.
+type Worker struct {
+	ID       string
+	Credit   int       // entries the worker is still willing to accept
+	Sent     int       // entries sent since the worker joined
+	Joined   time.Time // first message from the worker
+	LastSeen time.Time // last credit grant or heartbeat
+
+	intervalSent int
+}
+
+// Stats is the per-worker throughput over one reporting interval.
+type Stats struct {
+	ID       string
+	Sent     int     // entries sent during the interval
+	Rate     float64 // entries per second during the interval
+	Total    int     // entries sent since the worker joined
+	Credit   int     // credit left at the end of the interval
+	LastSeen time.Time
+}
+
+// Ledger tracks the credit every worker has granted the Collector.
+// Entries are only dispatched to workers with credit left, so a slow worker
+// simply stops being picked instead of building up a queue.
+// It is not safe for concurrent use; the Collector drives it from its main loop.
+type Ledger struct {
+	timeout   time.Duration
+	workers   map[string]*Worker
+	available int
+	lastStats time.Time
+}
+
+// NewLedger creates a ledger that forgets workers silent for longer than timeout.
+func NewLedger(timeout time.Duration) *Ledger {
+	return &Ledger{
+		timeout:   timeout,
+		workers:   make(map[string]*Worker),
+		lastStats: time.Now(),
+	}
+}
+
+// Grant adds n credits for worker id.
+// It reports whether id is a worker the ledger has not seen before.
+func (l *Ledger) Grant(id string, n int, now time.Time) bool {
+	w, ok := l.workers[id]
+	if !ok {
+		w = &Worker{ID: id, Joined: now}
+		l.workers[id] = w
+	}
+	w.Credit += n
+	w.LastSeen = now
+	l.available += n
+	return !ok
+}
+
+// Heartbeat marks worker id as alive. A worker the ledger does not know
+// (expired, or the Collector restarted) rejoins with the credit it still
+// considers outstanding, so it is not starved of work forever.
+// It reports whether the worker rejoined.
+func (l *Ledger) Heartbeat(id string, outstanding int, now time.Time) bool {
+	if w, ok := l.workers[id]; ok {
+		w.LastSeen = now
+		return false
+	}
+	return l.Grant(id, outstanding, now)
+}
+
+// Remove forgets worker id and its remaining credit.
+func (l *Ledger) Remove(id string) {
+	w, ok := l.workers[id]
+	if !ok {
+		return
+	}
+	l.available -= w.Credit
+	delete(l.workers, id)
+}
+
+// Available returns the total credit over all workers.
+func (l *Ledger) Available() int {
+	return l.available
+}
+
+// Len returns the number of known workers.
+func (l *Ledger) Len() int {
+	return len(l.workers)
+}
+
+// Next picks the worker with the most credit left, ties broken by ID.
+// Picking the largest credit favours idle workers over busy ones.
+// Workers heartbeat at a third of the timeout, so one silent for two thirds
+// of it has missed two heartbeats and is passed over until it is heard from
+// again or expires.
+func (l *Ledger) Next(now time.Time) (string, bool) {
+	var best *Worker
+	for _, w := range l.workers {
+		if w.Credit == 0 || now.Sub(w.LastSeen) > l.timeout*2/3 {
+			continue
+		}
+		if best == nil || w.Credit > best.Credit || (w.Credit == best.Credit && w.ID < best.ID) {
+			best = w
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return best.ID, true
+}
+
+// Sent consumes one credit of worker id after an entry was delivered to it.
+func (l *Ledger) Sent(id string) {
+	w, ok := l.workers[id]
+	if !ok || w.Credit == 0 {
+		return
+	}
+	w.Credit--
+	w.Sent++
+	w.intervalSent++
+	l.available--
+}
+
+// Expire removes workers that have been silent for longer than the timeout and returns them.
+func (l *Ledger) Expire(now time.Time) []Worker {
+	var gone []Worker
+	for id, w := range l.workers {
+		if now.Sub(w.LastSeen) > l.timeout {
+			gone = append(gone, *w)
+			l.Remove(id)
+		}
+	}
+	sort.Slice(gone, func(i, j int) bool { return gone[i].ID < gone[j].ID })
+	return gone
+}
+
+// Stats returns per-worker throughput since the previous call, sorted by worker ID.
+func (l *Ledger) Stats(now time.Time) []Stats {
+	elapsed := now.Sub(l.lastStats).Seconds()
+	l.lastStats = now
+
+	out := make([]Stats, 0, len(l.workers))
+	for _, w := range l.workers {
+		s := Stats{
+			ID:       w.ID,
+			Sent:     w.intervalSent,
+			Total:    w.Sent,
+			Credit:   w.Credit,
+			LastSeen: w.LastSeen,
+		}
+		if elapsed > 0 {
+			s.Rate = float64(w.intervalSent) / elapsed
+		}
+		w.intervalSent = 0
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
@@ -0,0 +1,90 @@
+package flow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLedgerNext(t *testing.T) {
+	now := time.Now()
+	l := NewLedger(3 * time.Second)
+	l.Grant("w1", 2, now)
+	l.Grant("w2", 5, now)
+	l.Grant("w3", 5, now)
+
+	// Most credit first, ties by ID
+	want := []string{"w2", "w3", "w2", "w3", "w2", "w3", "w1"}
+	for i, w := range want {
+		id, ok := l.Next(now)
+		if !ok || id != w {
+			t.Fatalf("pick %d = %q, %v; want %q", i, id, ok, w)
+		}
+		l.Sent(id)
+	}
+	if got := l.Available(); got != 5 {
+		t.Errorf("Available() = %d, want 5", got)
+	}
+}
+
+func TestLedgerPassesOverSilentWorkers(t *testing.T) {
+	start := time.Now()
+	l := NewLedger(3 * time.Second)
+	l.Grant("quiet", 10, start)
+	l.Grant("alive", 1, start)
+
+	now := start.Add(2500 * time.Millisecond)
+	l.Heartbeat("alive", 1, now)
+	if id, _ := l.Next(now); id != "alive" {
+		t.Fatalf("Next() = %q, want the worker still heartbeating", id)
+	}
+	l.Sent("alive")
+	if id, ok := l.Next(now); ok {
+		t.Fatalf("Next() = %q, want none: the other worker missed two heartbeats", id)
+	}
+
+	// Heard from again, it is picked again
+	l.Heartbeat("quiet", 10, now)
+	if id, _ := l.Next(now); id != "quiet" {
+		t.Errorf("Next() = %q after a heartbeat, want quiet", id)
+	}
+}
+
+func TestLedgerExpireAndRejoin(t *testing.T) {
+	start := time.Now()
+	l := NewLedger(time.Second)
+	l.Grant("w1", 4, start)
+	l.Sent("w1")
+
+	gone := l.Expire(start.Add(2 * time.Second))
+	if len(gone) != 1 || gone[0].ID != "w1" || gone[0].Credit != 3 {
+		t.Fatalf("Expire() = %+v, want w1 with 3 credits", gone)
+	}
+	if l.Len() != 0 || l.Available() != 0 {
+		t.Fatalf("Len() = %d, Available() = %d after expiry", l.Len(), l.Available())
+	}
+
+	if !l.Heartbeat("w1", 3, start.Add(3*time.Second)) {
+		t.Error("heartbeat from a forgotten worker did not rejoin it")
+	}
+	if l.Available() != 3 {
+		t.Errorf("Available() = %d after rejoin, want 3", l.Available())
+	}
+}
+
+func TestLedgerStats(t *testing.T) {
+	start := time.Now()
+	l := NewLedger(time.Minute)
+	l.lastStats = start
+	l.Grant("w1", 10, start)
+	for range 4 {
+		l.Sent("w1")
+	}
+
+	s := l.Stats(start.Add(2 * time.Second))
+	if len(s) != 1 || s[0].Sent != 4 || s[0].Rate != 2 || s[0].Credit != 6 {
+		t.Fatalf("Stats() = %+v, want 4 sent at 2/s with 6 credits left", s)
+	}
+	if s = l.Stats(start.Add(3 * time.Second)); s[0].Sent != 0 || s[0].Total != 4 {
+		t.Errorf("second Stats() = %+v, want 0 sent, 4 total", s)
+	}
+}
@@ -12,6 +12,15 @@ const (
 	ERROR LogLevel = "ERROR"
 )
 
+// Valid reports whether l is one of the known levels
+func (l LogLevel) Valid() bool {
+	switch l {
+	case DEBUG, INFO, WARN, ERROR:
+		return true
+	}
+	return false
+}
+
 // LogEntry represents a raw log message to be processed
 type LogEntry struct {
 	ID        string    `json:"id"`
@@ -20,13 +29,82 @@ type LogEntry struct {
 	Source    string    `json:"source"`
 	Message   string    `json:"message"`
 	RawData   string    `json:"raw_data,omitempty"` // Simulating some bulky data
+	Peer      string    `json:"peer,omitempty"`     // network address of the sender (syslog)
+	BatchID   string    `json:"batch_id,omitempty"`
 }
 
 // ProcessedLogEntry represents the log after parsing/anonymization
 type ProcessedLogEntry struct {
-	OriginalID  string    `json:"original_id"`
-	ProcessedAt time.Time `json:"processed_at"`
-	Level       LogLevel  `json:"level"`
-	Sanitized   bool      `json:"sanitized"`
-	CleanMessage string   `json:"clean_message"`
+	OriginalID   string    `json:"original_id"`
+	BatchID      string    `json:"batch_id,omitempty"`
+	Timestamp    time.Time `json:"timestamp,omitempty"` // original log time
+	ProcessedAt  time.Time `json:"processed_at"`
+	Level        LogLevel  `json:"level"`
+	Sanitized    bool      `json:"sanitized"`
+	CleanMessage string    `json:"clean_message"`
+	RulesFired   []string  `json:"rules_fired,omitempty"` // redaction rules that matched
+
+	// Enrichment, computed before redaction
+	Severity int               `json:"severity"`       // 0-100 from level and severity rules
+	Tags     map[string]string `json:"tags,omitempty"` // e.g. country, asn, as_org, severity_rules
 }
+
+// Time returns the original log time, falling back to the processing time
+func (p *ProcessedLogEntry) Time() time.Time {
+	if p.Timestamp.IsZero() {
+		return p.ProcessedAt
+	}
+	return p.Timestamp
+}
+
+// ControlFrame is the first frame of a batch control message on the sink socket.
+// Processed logs are single-frame, control messages are [ControlFrame, BatchControl JSON].
+const ControlFrame = "BATCH"
+
+// ControlType defines the kind of batch control message
+type ControlType string
+
+const (
+	BatchStart      ControlType = "BATCH_START"
+	BatchEnd        ControlType = "BATCH_END"
+	BatchDeadLetter ControlType = "DEAD_LETTER" // sent by a Worker for entries it moved to the dead-letter queue
+	StreamEnd       ControlType = "STREAM_END"  // sent by the Collector once every source is exhausted; no batch ID
+)
+
+// BatchControl is sent by the Collector directly to the Sink to delimit a batch
+type BatchControl struct {
+	Type      ControlType `json:"type"`
+	BatchID   string      `json:"batch_id"`
+	Expected  int         `json:"expected"`      // BATCH_START: planned size, BATCH_END: entries actually sent
+	IDs       []string    `json:"ids,omitempty"` // BATCH_END: IDs actually sent, used for loss detection; DEAD_LETTER: IDs dead-lettered
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// DeadLetterStage tells where in the Worker an entry failed
+type DeadLetterStage string
+
+const (
+	StageDecode   DeadLetterStage = "decode"   // the message is not a valid LogEntry
+	StageValidate DeadLetterStage = "validate" // required fields are missing or invalid
+	StageRedact   DeadLetterStage = "redact"   // a reject rule matched
+)
+
+// DeadLetter is pushed by a Worker to the dead-letter queue instead of dropping an entry
+type DeadLetter struct {
+	Original   []byte          `json:"original"` // message bytes exactly as received (base64 in JSON)
+	Stage      DeadLetterStage `json:"stage"`
+	Error      string          `json:"error"`
+	WorkerID   string          `json:"worker_id"`
+	OriginalID string          `json:"original_id,omitempty"` // when the entry could be decoded
+	BatchID    string          `json:"batch_id,omitempty"`
+	Timestamp  time.Time       `json:"timestamp"`
+}
+
+// Flow control between Workers (DEALER) and the Collector (ROUTER).
+// Workers send [CreditFrame, n] to accept n more entries, [HeartbeatFrame, outstanding]
+// while idle and [ByeFrame] when they shut down. The Collector answers with single-frame LogEntry JSON.
+const (
+	CreditFrame    = "CREDIT"
+	HeartbeatFrame = "HEARTBEAT"
+	ByeFrame       = "BYE"
+)
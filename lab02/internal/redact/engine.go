@@ -0,0 +1,183 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"regexp"
+)
+
+// detector finds candidate matches; validate (optional) filters false positives.
+type detector struct {
+	re       *regexp.Regexp
+	validate func(match string) bool
+}
+
+var builtins = map[string]detector{
+	TypeIPv4: {
+		re: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`),
+	},
+	TypeIPv6: {
+		re:       regexp.MustCompile(`(?i)[0-9a-f]{0,4}:[0-9a-f:]*:[0-9a-f]*(?:\.\d+){0,3}`),
+		validate: isIPv6,
+	},
+	TypeEmail: {
+		re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	TypeCreditCard: {
+		re:       regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		validate: luhnValid,
+	},
+	TypeAPIToken: {
+		re: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]{16,}=*` +
+			`|\bAKIA[0-9A-Z]{16}\b` +
+			`|\bgh[pousr]_[A-Za-z0-9]{36}\b` +
+			`|\b(?:sk|pk|api|key|token)[_-][A-Za-z0-9]{16,}\b`),
+	},
+}
+
+type rule struct {
+	name        string
+	action      Action
+	replacement string
+	detector
+}
+
+// Engine applies compiled redaction rules. It is immutable once built,
+// so workers can share it while a reload swaps in a new one.
+type Engine struct {
+	rules   []rule
+	hashKey []byte
+}
+
+// Result describes the outcome of redacting one field
+type Result struct {
+	Value   string
+	Fired   []string // names of the rules that matched, in rule order
+	Dropped bool     // a drop_field rule matched; Value is empty
+	Reject  string   // name of the reject rule that matched; Value is empty
+}
+
+// MinHashKey is the shortest HMAC key ActionHash accepts. Addresses and
+// e-mails are a small input space, so with a short or published key the
+// hashes can be reversed by trying every value.
+const MinHashKey = 16
+
+// Compile validates a configuration and builds an Engine.
+func Compile(cfg FileConfig) (*Engine, error) {
+	e := &Engine{hashKey: []byte(cfg.HashKey)}
+	for i, rc := range cfg.Rules {
+		if rc.Name == "" {
+			rc.Name = fmt.Sprintf("rule-%d", i)
+		}
+
+		var d detector
+		if rc.Type == TypeRegex {
+			re, err := regexp.Compile(rc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rc.Name, err)
+			}
+			d = detector{re: re}
+		} else {
+			var ok bool
+			if d, ok = builtins[rc.Type]; !ok {
+				return nil, fmt.Errorf("rule %s: unknown type %q", rc.Name, rc.Type)
+			}
+		}
+
+		switch rc.Action {
+		case ActionReplace:
+			if rc.Replacement == "" {
+				rc.Replacement = "[REDACTED]"
+			}
+		case ActionHash:
+			if len(e.hashKey) < MinHashKey {
+				return nil, fmt.Errorf("rule %s: hash action requires LAB02_HASH_KEY of at least %d bytes, e.g. from `openssl rand -hex 32`", rc.Name, MinHashKey)
+			}
+		case ActionDropField, ActionReject:
+		default:
+			return nil, fmt.Errorf("rule %s: unknown action %q", rc.Name, rc.Action)
+		}
+
+		e.rules = append(e.rules, rule{name: rc.Name, action: rc.Action, replacement: rc.Replacement, detector: d})
+	}
+	return e, nil
+}
+
+// Rules returns the number of compiled rules.
+func (e *Engine) Rules() int {
+	return len(e.rules)
+}
+
+// Apply runs every rule over value in order.
+func (e *Engine) Apply(value string) Result {
+	res := Result{Value: value}
+	for i := range e.rules {
+		r := &e.rules[i]
+		fired := false
+		out := r.re.ReplaceAllStringFunc(res.Value, func(m string) string {
+			if r.validate != nil && !r.validate(m) {
+				return m
+			}
+			fired = true
+			switch r.action {
+			case ActionHash:
+				return e.hash(m)
+			case ActionReplace:
+				return r.replacement
+			}
+			return m
+		})
+		if !fired {
+			continue
+		}
+		res.Fired = append(res.Fired, r.name)
+		switch r.action {
+		case ActionDropField:
+			res.Value = ""
+			res.Dropped = true
+			return res
+		case ActionReject:
+			res.Value = ""
+			res.Reject = r.name
+			return res
+		}
+		res.Value = out
+	}
+	return res
+}
+
+// hash returns a short keyed digest, so equal values stay linkable without being revealed.
+func (e *Engine) hash(v string) string {
+	mac := hmac.New(sha256.New, e.hashKey)
+	mac.Write([]byte(v))
+	return "[HASH:" + hex.EncodeToString(mac.Sum(nil))[:16] + "]"
+}
+
+func isIPv6(s string) bool {
+	addr, err := netip.ParseAddr(s)
+	return err == nil && addr.Is6()
+}
+
+// luhnValid checks the Luhn checksum of the digits in s.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
@@ -0,0 +1,116 @@
+package redact
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLuhnValid(t *testing.T) {
+	cases := []struct {
+		in   string
+		want bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"4111-1111-1111-1111", true},
+		{"4111111111111112", false},
+		{"411111111111", false}, // too short
+		{"79927398713", false},  // valid checksum, too short for a card
+		{"", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			if got := luhnValid(tc.in); got != tc.want {
+				t.Errorf("luhnValid(%q) = %v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyActions(t *testing.T) {
+	e, err := Compile(FileConfig{
+		HashKey: "test-key-0123456789",
+		Rules: []RuleConfig{
+			{Name: "card", Type: TypeCreditCard, Action: ActionReplace, Replacement: "[CARD]"},
+			{Name: "email", Type: TypeEmail, Action: ActionHash},
+			{Name: "ip", Type: TypeIPv4, Action: ActionReplace},
+			{Name: "token", Type: TypeAPIToken, Action: ActionDropField},
+			{Name: "secret", Type: TypeRegex, Pattern: `(?i)password=\S+`, Action: ActionReject},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		in      string
+		want    string
+		fired   []string
+		dropped bool
+		reject  string
+	}{
+		{"no match", "all good", "all good", nil, false, ""},
+		{"valid card", "paid with 4111 1111 1111 1111", "paid with [CARD]", []string{"card"}, false, ""},
+		{"invalid card", "order 4111111111111112", "order 4111111111111112", nil, false, ""},
+		{"ip default replacement", "from 10.0.0.1 and 10.0.0.2", "from [REDACTED] and [REDACTED]", []string{"ip"}, false, ""},
+		{"drop field", "auth bearer abcdefghijklmnopqrstu from 10.0.0.1", "", []string{"ip", "token"}, true, ""},
+		{"reject", "login password=hunter2", "", []string{"secret"}, false, "secret"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := e.Apply(tc.in)
+			if got.Value != tc.want || got.Dropped != tc.dropped || got.Reject != tc.reject {
+				t.Errorf("Apply(%q) = %+v, want value %q dropped %v reject %q", tc.in, got, tc.want, tc.dropped, tc.reject)
+			}
+			if !slices.Equal(got.Fired, tc.fired) {
+				t.Errorf("fired = %v, want %v", got.Fired, tc.fired)
+			}
+		})
+	}
+}
+
+func TestHashIsKeyedAndStable(t *testing.T) {
+	compile := func(key string) *Engine {
+		e, err := Compile(FileConfig{HashKey: key, Rules: []RuleConfig{{Type: TypeEmail, Action: ActionHash}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	a, b := compile("first-key-0123456789"), compile("second-key-0123456789")
+
+	first := a.Apply("from alice@example.com").Value
+	if strings.Contains(first, "alice") || !strings.Contains(first, "[HASH:") {
+		t.Fatalf("email not hashed: %q", first)
+	}
+	if again := a.Apply("from alice@example.com").Value; again != first {
+		t.Errorf("same key gave %q then %q", first, again)
+	}
+	if other := b.Apply("from alice@example.com").Value; other == first {
+		t.Errorf("different keys gave the same hash %q", other)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := map[string]RuleConfig{
+		"unknown type":   {Type: "ssn", Action: ActionReplace},
+		"unknown action": {Type: TypeIPv4, Action: "mask"},
+		"bad pattern":    {Type: TypeRegex, Pattern: "(", Action: ActionReplace},
+		"hash no key":    {Type: TypeEmail, Action: ActionHash},
+	}
+	for name, rc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Compile(FileConfig{Rules: []RuleConfig{rc}}); err == nil {
+				t.Error("Compile succeeded, want an error")
+			}
+		})
+	}
+
+	// The placeholder once committed in redaction_rules.json is too short
+	hash := []RuleConfig{{Type: TypeEmail, Action: ActionHash}}
+	if _, err := Compile(FileConfig{HashKey: "change-me-lab02", Rules: hash}); err == nil {
+		t.Error("Compile accepted a short hash key")
+	}
+}
@@ -0,0 +1,96 @@
+package segment
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// IndexFile is the name of the index kept next to the segments.
+const IndexFile = "index.json"
+
+// Info describes one segment file in the index.
+type Info struct {
+	File       string    `json:"file"`
+	MinTime    time.Time `json:"min_time"`
+	MaxTime    time.Time `json:"max_time"`
+	Count      int       `json:"count"`
+	Bytes      int64     `json:"bytes"` // on-disk size
+	Compressed bool      `json:"compressed"`
+	Closed     bool      `json:"closed"` // false for the segment still being written
+}
+
+// Overlaps reports whether the segment may hold records in [from, to].
+// Zero bounds are open. An open segment is treated as extending to "now".
+func (i Info) Overlaps(from, to time.Time) bool {
+	if i.Count == 0 {
+		return !i.Closed
+	}
+	if !to.IsZero() && i.MinTime.After(to) {
+		return false
+	}
+	if !from.IsZero() && i.Closed && i.MaxTime.Before(from) {
+		return false
+	}
+	return true
+}
+
+// Index maps time ranges to segments, oldest first.
+type Index struct {
+	Segments []Info `json:"segments"`
+}
+
+// LoadIndex reads the index from dir. A missing index is an empty one.
+func LoadIndex(dir string) (*Index, error) {
+	data, err := os.ReadFile(filepath.Join(dir, IndexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Index{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, err
+	}
+	return &idx, nil
+}
+
+// Save writes the index atomically (temp file + fsync + rename).
+func (idx *Index) Save(dir string) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, IndexFile+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	// Sync before the rename, or a crash can leave an empty index in its place
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, IndexFile))
+}
+
+// Find returns the segments that may hold records in [from, to].
+func (idx *Index) Find(from, to time.Time) []Info {
+	var out []Info
+	for _, s := range idx.Segments {
+		if s.Overlaps(from, to) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
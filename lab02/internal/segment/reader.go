@@ -0,0 +1,137 @@
+package segment
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+// Query selects stored records. Zero times and an empty level match everything.
+type Query struct {
+	From  time.Time
+	To    time.Time
+	Level protocol.LogLevel
+}
+
+// Match reports whether e satisfies the query.
+func (q Query) Match(e *protocol.ProcessedLogEntry) bool {
+	t := e.Time()
+	if !q.From.IsZero() && t.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && t.After(q.To) {
+		return false
+	}
+	return q.Level == "" || q.Level == e.Level
+}
+
+// Scan calls fn for every stored record matching q, segment by segment.
+// Returning false from fn stops the scan. Segments are picked through the index,
+// and a truncated tail (e.g. an active gzip segment) ends that segment quietly.
+func Scan(dir string, q Query, fn func(e *protocol.ProcessedLogEntry) bool) error {
+	idx, err := LoadIndex(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range idx.Find(q.From, q.To) {
+		more, err := scanFile(filepath.Join(dir, info.File), info.Compressed, q, fn)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+// ScanFrom calls fn for every record stored after pos, oldest first: the records
+// of pos.File past the first pos.Count, then every later segment. An empty
+// pos.File scans the whole store.
+func ScanFrom(dir string, pos Position, fn func(e *protocol.ProcessedLogEntry) bool) error {
+	idx, err := LoadIndex(dir)
+	if err != nil {
+		return err
+	}
+	start := 0
+	if pos.File != "" {
+		start = -1
+		for i, info := range idx.Segments {
+			if info.File == pos.File {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			return fmt.Errorf("segment %s not in index", pos.File)
+		}
+	}
+
+	for i, info := range idx.Segments[start:] {
+		skip := 0
+		if i == 0 {
+			skip = pos.Count
+		}
+		more, err := scanFile(filepath.Join(dir, info.File), info.Compressed, Query{}, func(e *protocol.ProcessedLogEntry) bool {
+			if skip > 0 {
+				skip--
+				return true
+			}
+			return fn(e)
+		})
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+func scanFile(path string, compressed bool, q Query, fn func(e *protocol.ProcessedLogEntry) bool) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if compressed {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				// Nothing flushed yet
+				return true, nil
+			}
+			return false, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var e protocol.ProcessedLogEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// Partially written last line
+			continue
+		}
+		if q.Match(&e) && !fn(&e) {
+			return false, nil
+		}
+	}
+	if err := sc.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, err
+	}
+	return true, nil
+}
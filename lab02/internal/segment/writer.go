@@ -0,0 +1,314 @@
+package segment
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+// FsyncPolicy controls when segment data is forced to disk.
+type FsyncPolicy string
+
+const (
+	FsyncAlways   FsyncPolicy = "always"   // after every record
+	FsyncInterval FsyncPolicy = "interval" // on Tick, at most every Options.FsyncInterval
+	FsyncNever    FsyncPolicy = "never"    // only on rotation and Close
+)
+
+// Options configures a Writer.
+type Options struct {
+	Dir           string
+	MaxBytes      int64         // rotate when the segment reaches this size (0 = no limit)
+	MaxAge        time.Duration // rotate when the segment is this old (0 = no limit)
+	Compress      bool          // gzip each segment
+	Fsync         FsyncPolicy
+	FsyncInterval time.Duration
+}
+
+// Writer appends ProcessedLogEntry records as JSON lines to rotating segment files.
+// It is not safe for concurrent use.
+type Writer struct {
+	opts  Options
+	index *Index
+
+	file     *os.File
+	counter  *countingWriter
+	buf      *bufio.Writer
+	gz       *gzip.Writer
+	enc      io.Writer // top of the stack: gzip or bufio
+	info     *Info     // index entry of the active segment
+	opened   time.Time
+	lastSync time.Time
+	dirty    bool // data written since the last fsync
+	stale    bool // index behind the active segment
+}
+
+// Open creates dir if needed, loads the index and starts a fresh segment.
+func Open(opts Options) (*Writer, error) {
+	if opts.Fsync == "" {
+		opts.Fsync = FsyncInterval
+	}
+	if opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = time.Second
+	}
+	switch opts.Fsync {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("unknown fsync policy %q", opts.Fsync)
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+	idx, err := LoadIndex(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("load index: %w", err)
+	}
+	// A crash leaves the last segment marked open, with an index entry that
+	// can be behind its file; recount it before sealing it.
+	for i := range idx.Segments {
+		if !idx.Segments[i].Closed {
+			recoverSegment(opts.Dir, &idx.Segments[i])
+		}
+	}
+
+	w := &Writer{opts: opts, index: idx}
+	if err := w.openSegment(time.Now()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends one record, rotating first if the active segment is full.
+func (w *Writer) Write(e *protocol.ProcessedLogEntry) error {
+	now := time.Now()
+	if w.shouldRotate(now) {
+		if err := w.rotate(now); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := w.enc.Write(line); err != nil {
+		return err
+	}
+
+	t := e.Time()
+	if w.info.Count == 0 || t.Before(w.info.MinTime) {
+		w.info.MinTime = t
+	}
+	if t.After(w.info.MaxTime) {
+		w.info.MaxTime = t
+	}
+	w.info.Count++
+	w.dirty = true
+	w.stale = true
+
+	if w.opts.Fsync == FsyncAlways {
+		return w.sync(now)
+	}
+	return nil
+}
+
+// Tick handles time-based rotation and interval fsync; call it periodically.
+func (w *Writer) Tick(now time.Time) error {
+	if w.info.Count > 0 && w.opts.MaxAge > 0 && now.Sub(w.opened) >= w.opts.MaxAge {
+		return w.rotate(now)
+	}
+	if now.Sub(w.lastSync) < w.opts.FsyncInterval {
+		return nil
+	}
+	if w.opts.Fsync == FsyncInterval && w.dirty {
+		return w.sync(now)
+	}
+	if w.stale {
+		// Keep the index roughly current even when fsync is per-record or disabled
+		w.lastSync = now
+		return w.saveIndex()
+	}
+	return nil
+}
+
+// Position identifies a point in the segment store: the first Count records of File.
+type Position struct {
+	File  string
+	Count int
+}
+
+// Position returns how far the store has been written.
+func (w *Writer) Position() Position {
+	return Position{File: w.info.File, Count: w.info.Count}
+}
+
+// Sync forces everything written so far to disk regardless of the fsync policy,
+// so a checkpoint taken afterwards never claims records a crash could lose.
+func (w *Writer) Sync() error {
+	if err := w.sync(time.Now()); err != nil {
+		return err
+	}
+	if w.stale {
+		return w.saveIndex()
+	}
+	return nil
+}
+
+// Close seals the active segment and writes the index.
+func (w *Writer) Close() error {
+	return w.closeSegment(time.Now())
+}
+
+func (w *Writer) shouldRotate(now time.Time) bool {
+	if w.info.Count == 0 {
+		return false
+	}
+	if w.opts.MaxBytes > 0 && w.size() >= w.opts.MaxBytes {
+		return true
+	}
+	return w.opts.MaxAge > 0 && now.Sub(w.opened) >= w.opts.MaxAge
+}
+
+// size estimates the active segment size: bytes on disk plus still-buffered bytes.
+func (w *Writer) size() int64 {
+	return w.counter.n + int64(w.buf.Buffered())
+}
+
+func (w *Writer) rotate(now time.Time) error {
+	if err := w.closeSegment(now); err != nil {
+		return err
+	}
+	return w.openSegment(now)
+}
+
+func (w *Writer) openSegment(now time.Time) error {
+	// A crash between creating a segment and saving the index leaves a file
+	// the index does not know; skip its number rather than truncate it.
+	var name string
+	var f *os.File
+	for n := len(w.index.Segments) + 1; ; n++ {
+		name = fmt.Sprintf("segment-%06d.jsonl", n)
+		if w.opts.Compress {
+			name += ".gz"
+		}
+		var err error
+		f, err = os.OpenFile(filepath.Join(w.opts.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+	}
+
+	w.file = f
+	w.counter = &countingWriter{w: f}
+	w.buf = bufio.NewWriterSize(w.counter, 64*1024)
+	w.enc = w.buf
+	w.gz = nil
+	if w.opts.Compress {
+		w.gz = gzip.NewWriter(w.buf)
+		w.enc = w.gz
+	}
+	w.opened = now
+	w.lastSync = now
+
+	w.index.Segments = append(w.index.Segments, Info{File: name, Compressed: w.opts.Compress})
+	w.info = &w.index.Segments[len(w.index.Segments)-1]
+	return w.saveIndex()
+}
+
+func (w *Writer) closeSegment(now time.Time) error {
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			return err
+		}
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.info.Bytes = w.counter.n
+	w.info.Closed = true
+	w.dirty = false
+	return w.saveIndex()
+}
+
+// recoverSegment recounts the records of a segment left open by a crash and
+// seals it. A damaged tail ends the count; the records before it still count.
+func recoverSegment(dir string, info *Info) {
+	path := filepath.Join(dir, info.File)
+	var count int
+	var minTime, maxTime time.Time
+	scanFile(path, info.Compressed, Query{}, func(e *protocol.ProcessedLogEntry) bool {
+		t := e.Time()
+		if count == 0 || t.Before(minTime) {
+			minTime = t
+		}
+		if t.After(maxTime) {
+			maxTime = t
+		}
+		count++
+		return true
+	})
+	if count >= info.Count {
+		info.Count, info.MinTime, info.MaxTime = count, minTime, maxTime
+	}
+	if st, err := os.Stat(path); err == nil {
+		info.Bytes = st.Size()
+	}
+	info.Closed = true
+}
+
+func (w *Writer) saveIndex() error {
+	w.stale = false
+	return w.index.Save(w.opts.Dir)
+}
+
+// sync flushes buffered data, fsyncs the file and refreshes the index.
+func (w *Writer) sync(now time.Time) error {
+	if w.gz != nil {
+		if err := w.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.info.Bytes = w.counter.n
+	w.lastSync = now
+	w.dirty = false
+	if w.opts.Fsync == FsyncAlways {
+		// Rewriting the index per record would dominate; Tick refreshes it instead.
+		return nil
+	}
+	return w.saveIndex()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,157 @@
+package segment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+var base = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func record(i int) *protocol.ProcessedLogEntry {
+	return &protocol.ProcessedLogEntry{
+		OriginalID:   fmt.Sprintf("id-%d", i),
+		Timestamp:    base.Add(time.Duration(i) * time.Minute),
+		ProcessedAt:  base,
+		Level:        protocol.INFO,
+		CleanMessage: "hello",
+	}
+}
+
+func writeRecords(t *testing.T, w *Writer, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if err := w.Write(record(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func count(t *testing.T, dir string, q Query) int {
+	t.Helper()
+	n := 0
+	if err := Scan(dir, q, func(*protocol.ProcessedLogEntry) bool { n++; return true }); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestWriterRotates(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := Open(Options{Dir: dir, MaxBytes: 1, Compress: compress, Fsync: FsyncNever})
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeRecords(t, w, 0, 3)
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			idx, err := LoadIndex(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(idx.Segments) != 3 {
+				t.Fatalf("%d segments, want one per record", len(idx.Segments))
+			}
+			for i, s := range idx.Segments {
+				if !s.Closed || s.Count != 1 || !s.MinTime.Equal(record(i).Timestamp) || s.Compressed != compress {
+					t.Errorf("segment %d = %+v", i, s)
+				}
+			}
+			if n := count(t, dir, Query{}); n != 3 {
+				t.Errorf("scanned %d records, want 3", n)
+			}
+			if n := count(t, dir, Query{From: base.Add(time.Minute), To: base.Add(time.Minute)}); n != 1 {
+				t.Errorf("scanned %d records in one minute, want 1", n)
+			}
+		})
+	}
+}
+
+func TestInfoOverlaps(t *testing.T) {
+	closed := Info{MinTime: base, MaxTime: base.Add(time.Hour), Count: 2, Closed: true}
+	open := closed
+	open.Closed = false
+
+	cases := []struct {
+		name     string
+		info     Info
+		from, to time.Time
+		want     bool
+	}{
+		{"unbounded", closed, time.Time{}, time.Time{}, true},
+		{"inside", closed, base.Add(time.Minute), base.Add(2 * time.Minute), true},
+		{"touches start", closed, base.Add(-time.Hour), base, true},
+		{"touches end", closed, base.Add(time.Hour), time.Time{}, true},
+		{"before", closed, time.Time{}, base.Add(-time.Second), false},
+		{"after closed", closed, base.Add(2 * time.Hour), time.Time{}, false},
+		{"after open", open, base.Add(2 * time.Hour), time.Time{}, true},
+		{"empty closed", Info{Closed: true}, time.Time{}, time.Time{}, false},
+		{"empty open", Info{}, time.Time{}, time.Time{}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.info.Overlaps(tc.from, tc.to); got != tc.want {
+				t.Errorf("Overlaps = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOpenRecoversCrashedSegment(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(Options{Dir: dir, Fsync: FsyncAlways})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Per-record fsync leaves the index behind the file until Tick
+	writeRecords(t, w, 0, 5)
+	idx, _ := LoadIndex(dir)
+	if idx.Segments[0].Count == 5 {
+		t.Fatal("index already current; the test needs a stale one")
+	}
+	// Crash: no Close. Reopen and query past the stale MaxTime.
+	w2, err := Open(Options{Dir: dir, Fsync: FsyncNever})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w2.Close()
+
+	idx, _ = LoadIndex(dir)
+	s := idx.Segments[0]
+	if !s.Closed || s.Count != 5 || !s.MaxTime.Equal(record(4).Timestamp) {
+		t.Errorf("recovered segment = %+v, want 5 records up to %v", s, record(4).Timestamp)
+	}
+	if n := count(t, dir, Query{From: base.Add(3 * time.Minute)}); n != 2 {
+		t.Errorf("scanned %d records after the crash point, want 2", n)
+	}
+}
+
+func TestOpenKeepsUnindexedSegment(t *testing.T) {
+	dir := t.TempDir()
+	// A segment created just before a crash, never saved in the index
+	orphan := filepath.Join(dir, "segment-000001.jsonl")
+	if err := os.WriteFile(orphan, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if data, _ := os.ReadFile(orphan); string(data) != "{}\n" {
+		t.Errorf("existing segment truncated to %q", data)
+	}
+	if got := w.Position().File; got != "segment-000002.jsonl" {
+		t.Errorf("new segment %s, want segment-000002.jsonl", got)
+	}
+}
@@ -0,0 +1,82 @@
+package source
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+// Offset is the persisted read position of a tailed file.
+// The fingerprint (hash of the first bytes) detects a replaced file across restarts.
+// Generation counts the files seen at the path (rotations, truncations), and
+// FileID names the current one in line IDs.
+type Offset struct {
+	Offset         int64  `json:"offset"`
+	Fingerprint    string `json:"fingerprint"`
+	FingerprintLen int    `json:"fingerprint_len"`
+	Generation     int    `json:"generation,omitempty"`
+	FileID         string `json:"file_id,omitempty"`
+}
+
+// Offsets is a small JSON-backed store shared by all file tails.
+type Offsets struct {
+	mu    sync.Mutex
+	path  string
+	files map[string]Offset
+}
+
+// LoadOffsets reads the store from path; a missing file is an empty store.
+func LoadOffsets(path string) (*Offsets, error) {
+	o := &Offsets{path: path, files: make(map[string]Offset)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &o.files); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Get returns the stored offset of a file.
+func (o *Offsets) Get(file string) (Offset, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	off, ok := o.files[file]
+	return off, ok
+}
+
+// Set records the offset of a file and persists the store atomically
+// (temp file + fsync + rename).
+func (o *Offsets) Set(file string, off Offset) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.files[file] = off
+	data, err := json.MarshalIndent(o.files, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := o.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	// Sync before the rename, or a crash can leave an empty file in its place
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path)
+}
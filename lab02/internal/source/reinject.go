@@ -0,0 +1,57 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+// Reinject binds a PULL socket and feeds every LogEntry pushed to it back into
+// the pipeline. The DLQ consumer uses it to re-inject fixed dead letters.
+// Entries keep their ID but are assigned to the current batch.
+// Malformed messages are logged and skipped, so a stray client cannot stop it.
+type Reinject struct {
+	Endpoint string
+	Logger   *slog.Logger // nil = slog.Default()
+}
+
+func (r *Reinject) Name() string { return "reinject:" + r.Endpoint }
+
+func (r *Reinject) Run(ctx context.Context, out chan<- protocol.LogEntry) error {
+	pull := zmq4.NewPull(ctx)
+	defer pull.Close()
+
+	if err := pull.Listen(r.Endpoint); err != nil {
+		return err
+	}
+
+	logger := r.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	rejected := 0
+	for {
+		msg, err := pull.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		var entry protocol.LogEntry
+		if err := json.Unmarshal(msg.Bytes(), &entry); err != nil {
+			// The DLQ consumer validates before sending, so this is a foreign client
+			rejected++
+			logger.Warn("Malformed re-injected entry, skipping", "source", r.Name(), "bytes", len(msg.Bytes()), "rejected", rejected, "error", err)
+			continue
+		}
+		entry.BatchID = ""
+		if !emit(ctx, out, entry) {
+			return ctx.Err()
+		}
+	}
+}
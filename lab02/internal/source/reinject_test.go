@@ -0,0 +1,46 @@
+package source
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+func TestReinjectSkipsMalformed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const endpoint = "tcp://127.0.0.1:15560"
+	out := make(chan protocol.LogEntry, 4)
+	done := make(chan error, 1)
+	r := &Reinject{Endpoint: endpoint, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	go func() { done <- r.Run(ctx, out) }()
+
+	push := zmq4.NewPush(ctx)
+	defer push.Close()
+	if err := push.Dial(endpoint); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []string{"not json", `{"id":"e1","level":"INFO","message":"ok","batch_id":"old"}`} {
+		if err := push.Send(zmq4.NewMsgString(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case e := <-out:
+		if e.ID != "e1" || e.BatchID != "" {
+			t.Errorf("entry %+v, want e1 without its old batch", e)
+		}
+	case err := <-done:
+		t.Fatalf("source stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no entry from the reinject source")
+	}
+}
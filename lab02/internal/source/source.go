@@ -0,0 +1,85 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+// Source produces raw log entries for the Collector.
+// Run blocks until ctx is cancelled or the source is exhausted.
+// Entries without an ID are numbered by the Collector.
+type Source interface {
+	Name() string
+	Run(ctx context.Context, out chan<- protocol.LogEntry) error
+}
+
+// Options carries settings shared by the sources
+type Options struct {
+	OffsetsFile  string       // where file tails persist their read offsets
+	BatchSize    int          // size of the synthetic batch
+	ReinjectAddr string       // default endpoint of the reinject source
+	Logger       *slog.Logger // for problems a source skips over
+}
+
+// Parse builds sources from a comma separated spec list:
+//
+//	synthetic              generate Options.BatchSize fake entries, then stop
+//	file:<path>            tail a file (rotation aware, offsets persisted)
+//	syslog-udp:<addr>      RFC 3164/5424 syslog over UDP, e.g. syslog-udp::5514
+//	syslog-tcp:<addr>      RFC 3164/5424 syslog over TCP (LF or octet-counted framing)
+//	reinject[:<endpoint>]  PULL socket for entries re-injected from the dead-letter queue
+func Parse(specs string, opts Options) ([]Source, error) {
+	var offsets *Offsets
+	var out []Source
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		kind, arg, _ := strings.Cut(spec, ":")
+		switch kind {
+		case "synthetic":
+			out = append(out, &Synthetic{Count: opts.BatchSize})
+		case "file":
+			if arg == "" {
+				return nil, fmt.Errorf("source %q: missing path", spec)
+			}
+			if offsets == nil {
+				var err error
+				if offsets, err = LoadOffsets(opts.OffsetsFile); err != nil {
+					return nil, err
+				}
+			}
+			out = append(out, &FileTail{Path: arg, Offsets: offsets})
+		case "syslog-udp":
+			out = append(out, &Syslog{Network: "udp", Addr: arg})
+		case "syslog-tcp":
+			out = append(out, &Syslog{Network: "tcp", Addr: arg})
+		case "reinject":
+			if arg == "" {
+				arg = opts.ReinjectAddr
+			}
+			out = append(out, &Reinject{Endpoint: arg, Logger: opts.Logger})
+		default:
+			return nil, fmt.Errorf("unknown source %q", spec)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no sources configured")
+	}
+	return out, nil
+}
+
+// emit sends e unless ctx is done.
+func emit(ctx context.Context, out chan<- protocol.LogEntry, e protocol.LogEntry) bool {
+	select {
+	case out <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
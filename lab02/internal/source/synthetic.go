@@ -0,0 +1,43 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+// Synthetic generates Count fake entries, the original lab workload.
+// IDs are left to the Collector so they stay unique across runs.
+type Synthetic struct {
+	Count int
+}
+
+func (s *Synthetic) Name() string { return "synthetic" }
+
+func (s *Synthetic) Run(ctx context.Context, out chan<- protocol.LogEntry) error {
+	sources := []string{"Firewall-1", "Auth-Server", "Web-Gateway", "DB-Cluster"}
+	levels := []protocol.LogLevel{protocol.INFO, protocol.WARN, protocol.ERROR, protocol.DEBUG}
+
+	for i := 0; i < s.Count; i++ {
+		// Simulate some "IP" in the message to be anonymized later
+		msgContent := fmt.Sprintf("User login attempt from IP: 192.168.1.%d", rand.Intn(255))
+		if rand.Float32() < 0.1 {
+			msgContent = fmt.Sprintf("Malware detected from IP: 10.0.0.%d", rand.Intn(255))
+		}
+
+		entry := protocol.LogEntry{
+			Timestamp: time.Now(),
+			Level:     levels[rand.Intn(len(levels))],
+			Source:    sources[rand.Intn(len(sources))],
+			Message:   msgContent,
+			RawData:   "0xDEADBEEF...", // filler
+		}
+		if !emit(ctx, out, entry) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
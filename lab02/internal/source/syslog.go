@@ -0,0 +1,287 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+// Syslog listens for RFC 3164 and RFC 5424 messages on UDP or TCP.
+// TCP accepts both LF-delimited and octet-counted (RFC 6587) framing.
+type Syslog struct {
+	Network string // "udp" or "tcp"
+	Addr    string
+}
+
+func (s *Syslog) Name() string { return "syslog-" + s.Network + ":" + s.Addr }
+
+func (s *Syslog) Run(ctx context.Context, out chan<- protocol.LogEntry) error {
+	if s.Network == "udp" {
+		return s.runUDP(ctx, out)
+	}
+	return s.runTCP(ctx, out)
+}
+
+func (s *Syslog) runUDP(ctx context.Context, out chan<- protocol.LogEntry) error {
+	conn, err := net.ListenPacket("udp", s.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		entry := ParseSyslog(string(buf[:n]), time.Now())
+		entry.Peer = peer.String()
+		if !emit(ctx, out, entry) {
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Syslog) runTCP(ctx context.Context, out chan<- protocol.LogEntry) error {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := make(map[net.Conn]struct{})
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+		mu.Lock()
+		for c := range conns {
+			c.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			wg.Wait()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		mu.Lock()
+		conns[conn] = struct{}{}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+				conn.Close()
+			}()
+			readFrames(ctx, conn, out)
+		}()
+	}
+}
+
+// readFrames splits a TCP stream into syslog messages.
+func readFrames(ctx context.Context, conn net.Conn, out chan<- protocol.LogEntry) {
+	r := bufio.NewReader(conn)
+	peer := conn.RemoteAddr().String()
+	for {
+		msg, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		if msg == "" {
+			continue
+		}
+		entry := ParseSyslog(msg, time.Now())
+		entry.Peer = peer
+		if !emit(ctx, out, entry) {
+			return
+		}
+	}
+}
+
+// readFrame reads one octet-counted ("LEN SP MSG") or LF-terminated message.
+func readFrame(r *bufio.Reader) (string, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	if first[0] >= '1' && first[0] <= '9' {
+		lenStr, err := r.ReadString(' ')
+		if err != nil {
+			return "", err
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(lenStr))
+		if err != nil || n <= 0 || n > 1<<20 {
+			return "", fmt.Errorf("bad octet count %q", lenStr)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(buf), "\r\n"), nil
+	}
+	line, err := r.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ParseSyslog maps an RFC 5424 or RFC 3164 message to a LogEntry.
+// Anything that does not parse is kept verbatim as an INFO message.
+func ParseSyslog(msg string, received time.Time) protocol.LogEntry {
+	entry := protocol.LogEntry{Timestamp: received, Level: protocol.INFO, Source: "syslog", Message: msg}
+
+	if !strings.HasPrefix(msg, "<") {
+		return entry
+	}
+	end := strings.IndexByte(msg, '>')
+	if end < 2 || end > 4 {
+		return entry
+	}
+	pri, err := strconv.Atoi(msg[1:end])
+	if err != nil || pri > 191 {
+		return entry
+	}
+	entry.Level = severityLevel(pri % 8)
+	rest := msg[end+1:]
+
+	if strings.HasPrefix(rest, "1 ") {
+		parse5424(rest[2:], &entry)
+	} else {
+		parse3164(rest, received, &entry)
+	}
+	return entry
+}
+
+// parse5424 handles "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD [MSG]".
+func parse5424(rest string, e *protocol.LogEntry) {
+	fields := strings.SplitN(rest, " ", 6)
+	if len(fields) < 6 {
+		e.Message = rest
+		return
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		e.Timestamp = ts
+	}
+	e.Source = sourceName(fields[1], fields[2])
+
+	// Skip structured data: "-" or one or more [..] elements
+	body := fields[5]
+	if strings.HasPrefix(body, "-") {
+		body = strings.TrimPrefix(body[1:], " ")
+	} else {
+		for strings.HasPrefix(body, "[") {
+			i := closingBracket(body)
+			if i < 0 {
+				break
+			}
+			body = body[i+1:]
+		}
+		body = strings.TrimPrefix(body, " ")
+	}
+	e.Message = strings.TrimPrefix(body, "\ufeff") // optional BOM
+}
+
+// parse3164 handles "Mmm dd hh:mm:ss HOSTNAME TAG: MSG".
+func parse3164(rest string, received time.Time, e *protocol.LogEntry) {
+	const stamp = "Jan _2 15:04:05"
+	if len(rest) < len(stamp)+1 {
+		e.Message = rest
+		return
+	}
+	ts, err := time.ParseInLocation(stamp, rest[:len(stamp)], time.Local)
+	if err != nil {
+		e.Message = rest
+		return
+	}
+	// RFC 3164 has no year; assume the most recent past occurrence
+	ts = ts.AddDate(received.Year(), 0, 0)
+	if ts.After(received.Add(24 * time.Hour)) {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	e.Timestamp = ts
+
+	host, body, _ := strings.Cut(strings.TrimPrefix(rest[len(stamp):], " "), " ")
+	tag, msg, ok := strings.Cut(body, ": ")
+	if !ok || strings.Contains(tag, " ") {
+		tag, msg = "", body
+	}
+	if i := strings.IndexByte(tag, '['); i > 0 {
+		tag = tag[:i] // strip "[pid]"
+	}
+	e.Source = sourceName(host, tag)
+	e.Message = msg
+}
+
+func closingBracket(s string) int {
+	escaped := false
+	for i := 1; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func sourceName(host, app string) string {
+	if host == "-" {
+		host = ""
+	}
+	if app == "-" {
+		app = ""
+	}
+	switch {
+	case host != "" && app != "":
+		return host + "/" + app
+	case host != "":
+		return host
+	case app != "":
+		return app
+	default:
+		return "syslog"
+	}
+}
+
+// severityLevel maps syslog severities (0 emerg .. 7 debug) onto LogLevel.
+func severityLevel(sev int) protocol.LogLevel {
+	switch {
+	case sev <= 3:
+		return protocol.ERROR
+	case sev == 4:
+		return protocol.WARN
+	case sev == 7:
+		return protocol.DEBUG
+	default:
+		return protocol.INFO
+	}
+}
@@ -0,0 +1,108 @@
+package source
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+func TestParseSyslog(t *testing.T) {
+	received := time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local)
+
+	cases := []struct {
+		name    string
+		msg     string
+		level   protocol.LogLevel
+		source  string
+		message string
+		ts      time.Time
+	}{
+		{
+			name:    "rfc5424",
+			msg:     `<165>1 2025-03-10T11:59:58.5Z web01 nginx 42 ID47 [exampleSDID@32473 iut="3" eventSource="App"] request failed`,
+			level:   protocol.INFO,
+			source:  "web01/nginx",
+			message: "request failed",
+			ts:      time.Date(2025, 3, 10, 11, 59, 58, 5e8, time.UTC),
+		},
+		{
+			name:    "rfc5424 nil values",
+			msg:     "<11>1 2025-03-10T11:00:00Z - - - - - \ufeffdisk full",
+			level:   protocol.ERROR,
+			source:  "syslog",
+			message: "disk full",
+			ts:      time.Date(2025, 3, 10, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "rfc3164",
+			msg:     "<12>Mar  9 08:15:00 db01 postgres[311]: slow query",
+			level:   protocol.WARN,
+			source:  "db01/postgres",
+			message: "slow query",
+			ts:      time.Date(2025, 3, 9, 8, 15, 0, 0, time.Local),
+		},
+		{
+			name:    "rfc3164 without tag",
+			msg:     "<15>Mar 10 11:00:00 db01 checkpoint complete",
+			level:   protocol.DEBUG,
+			source:  "db01",
+			message: "checkpoint complete",
+			ts:      time.Date(2025, 3, 10, 11, 0, 0, 0, time.Local),
+		},
+		{
+			name:    "rfc3164 from last year",
+			msg:     "<14>Dec 31 23:59:59 db01 cron: rotated",
+			level:   protocol.INFO,
+			source:  "db01/cron",
+			message: "rotated",
+			ts:      time.Date(2024, 12, 31, 23, 59, 59, 0, time.Local),
+		},
+		{
+			name:    "no priority",
+			msg:     "plain text",
+			level:   protocol.INFO,
+			source:  "syslog",
+			message: "plain text",
+			ts:      received,
+		},
+		{
+			name:    "priority out of range",
+			msg:     "<999>1 x",
+			level:   protocol.INFO,
+			source:  "syslog",
+			message: "<999>1 x",
+			ts:      received,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := ParseSyslog(tc.msg, received)
+			if e.Level != tc.level || e.Source != tc.source || e.Message != tc.message {
+				t.Errorf("got level %s source %q message %q, want %s %q %q", e.Level, e.Source, e.Message, tc.level, tc.source, tc.message)
+			}
+			if !e.Timestamp.Equal(tc.ts) {
+				t.Errorf("timestamp %v, want %v", e.Timestamp, tc.ts)
+			}
+		})
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("10 <13>1 a\nbc<13>plain line\r\n<13>last"))
+	want := []string{"<13>1 a\nbc", "<13>plain line", "<13>last"}
+	for _, w := range want {
+		got, err := readFrame(r)
+		if err != nil {
+			t.Fatalf("readFrame: %v", err)
+		}
+		if got != w {
+			t.Errorf("readFrame = %q, want %q", got, w)
+		}
+	}
+	if _, err := readFrame(r); err == nil {
+		t.Error("readFrame after the last frame succeeded")
+	}
+}
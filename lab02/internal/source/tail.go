@@ -0,0 +1,245 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+const (
+	tailPoll       = 500 * time.Millisecond
+	fingerprintLen = 256
+)
+
+// FileTail follows a file like `tail -F`: it survives rotation (rename + recreate)
+// and truncation, and resumes from the persisted offset after a restart.
+type FileTail struct {
+	Path    string
+	Offsets *Offsets
+}
+
+func (t *FileTail) Name() string { return "file:" + t.Path }
+
+func (t *FileTail) Run(ctx context.Context, out chan<- protocol.LogEntry) error {
+	var (
+		f       *os.File
+		info    os.FileInfo
+		r       *bufio.Reader
+		state   Offset
+		pending string // partial last line, not yet terminated
+	)
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	// drain emits every line up to EOF and saves the offset. With final
+	// set the file will not grow any more, so the unterminated tail is a
+	// line of its own.
+	drain := func(final bool) error {
+		start := state.Offset
+		for {
+			chunk, err := r.ReadString('\n')
+			if err != nil {
+				// Keep the unterminated tail until the writer finishes the line
+				pending += chunk
+				if !final || pending == "" {
+					break
+				}
+				chunk = ""
+			}
+			line := pending + chunk
+			pending = ""
+			lineStart := start
+			start += int64(len(line))
+
+			line = strings.TrimRight(line, "\r\n")
+			if line != "" && state.FileID == "" {
+				if err := t.identify(f, &state); err != nil {
+					return err
+				}
+			}
+			if line != "" && !emit(ctx, out, t.entry(line, state.FileID, lineStart)) {
+				return ctx.Err()
+			}
+			if err != nil {
+				break
+			}
+		}
+		if start == state.Offset {
+			return nil
+		}
+		state.Offset = start
+		if state.FingerprintLen < fingerprintLen {
+			// The file was short when first seen; widen the fingerprint as it grows
+			if fp, n, err := fingerprint(f); err == nil && fp != "" {
+				state.Fingerprint, state.FingerprintLen = fp, n
+			}
+		}
+		return t.Offsets.Set(t.Path, state)
+	}
+
+	for {
+		if f == nil {
+			var err error
+			f, info, state, err = t.open()
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if f != nil {
+				r = bufio.NewReader(f)
+				pending = ""
+			}
+		}
+
+		if f != nil {
+			if err := drain(false); err != nil {
+				return err
+			}
+
+			// Rotation: the path now points to a different file. The writer
+			// may have appended to the old one since the read above, so read
+			// it to EOF once more before letting go of it.
+			cur, err := os.Stat(t.Path)
+			switch {
+			case err != nil || !os.SameFile(info, cur):
+				if err := drain(true); err != nil {
+					return err
+				}
+				f.Close()
+				f = nil
+				if err == nil {
+					t.Offsets.Set(t.Path, Offset{Generation: state.Generation + 1})
+				}
+			case cur.Size() < state.Offset:
+				// Truncated in place
+				if _, err := f.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				r.Reset(f)
+				pending = ""
+				// New content at the same offsets: a new generation
+				state = Offset{Generation: state.Generation + 1}
+				if err := t.Offsets.Set(t.Path, state); err != nil {
+					return err
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(tailPoll):
+		}
+	}
+}
+
+// open opens the file and positions it at the persisted offset if it is the same file.
+func (t *FileTail) open() (*os.File, os.FileInfo, Offset, error) {
+	f, err := os.Open(t.Path)
+	if err != nil {
+		return nil, nil, Offset{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, Offset{}, err
+	}
+
+	state := Offset{}
+	if saved, ok := t.Offsets.Get(t.Path); ok {
+		// A file that was never fingerprinted had no lines; its generation
+		// is still unused. Any other file there now is the next one.
+		state.Generation = saved.Generation
+		if saved.FingerprintLen > 0 {
+			state.Generation++
+			if saved.Offset <= info.Size() {
+				if fp, err := fingerprintN(f, saved.FingerprintLen); err == nil && fp == saved.Fingerprint {
+					state = saved
+				}
+			}
+		}
+	}
+	if state.Fingerprint == "" {
+		state.Fingerprint, state.FingerprintLen, _ = fingerprint(f)
+	}
+	if _, err := f.Seek(state.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, Offset{}, err
+	}
+	return f, info, state, nil
+}
+
+// identify names the file for line IDs before its first line goes out: the
+// path, the generation and the fingerprint of what was written so far. The
+// name is persisted at once and kept as the fingerprint widens, so lines
+// re-read after a crash keep their IDs.
+func (t *FileTail) identify(f *os.File, state *Offset) error {
+	fp, n, err := fingerprint(f)
+	if err != nil {
+		return err
+	}
+	state.Fingerprint, state.FingerprintLen = fp, n
+	state.FileID = fmt.Sprintf("%s:%d:%s", t.Path, state.Generation, fp)
+	return t.Offsets.Set(t.Path, *state)
+}
+
+func (t *FileTail) entry(line, fileID string, offset int64) protocol.LogEntry {
+	return protocol.LogEntry{
+		// Stable across restarts, unique across files and rotations
+		ID:        fmt.Sprintf("file:%s:%d", fileID, offset),
+		Timestamp: time.Now(),
+		Level:     guessLevel(line),
+		Source:    "file:" + filepath.Base(t.Path),
+		Message:   line,
+	}
+}
+
+// fingerprint hashes up to fingerprintLen leading bytes of f.
+func fingerprint(f *os.File) (string, int, error) {
+	buf := make([]byte, fingerprintLen)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", 0, err
+	}
+	if n == 0 {
+		return "", 0, nil
+	}
+	sum := sha256.Sum256(buf[:n])
+	return hex.EncodeToString(sum[:8]), n, nil
+}
+
+func fingerprintN(f *os.File, n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// guessLevel picks a level from common keywords in a plain text line.
+func guessLevel(line string) protocol.LogLevel {
+	upper := strings.ToUpper(line)
+	switch {
+	case strings.Contains(upper, "ERROR"), strings.Contains(upper, "FATAL"), strings.Contains(upper, "CRIT"):
+		return protocol.ERROR
+	case strings.Contains(upper, "WARN"):
+		return protocol.WARN
+	case strings.Contains(upper, "DEBUG"), strings.Contains(upper, "TRACE"):
+		return protocol.DEBUG
+	default:
+		return protocol.INFO
+	}
+}
@@ -0,0 +1,143 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gemini-zeromq-labs/lab02/internal/protocol"
+)
+
+func next(t *testing.T, out <-chan protocol.LogEntry) string {
+	t.Helper()
+	select {
+	case e := <-out:
+		return e.Message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no line from the tail")
+		return ""
+	}
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileTailRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	offsets, err := LoadOffsets(filepath.Join(dir, "offsets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "one\ntwo")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan protocol.LogEntry, 16)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		(&FileTail{Path: path, Offsets: offsets}).Run(ctx, out)
+	}()
+	// The tail saves offsets; let it stop before the directory is removed
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	if got := next(t, out); got != "one" {
+		t.Fatalf("first line %q, want one", got)
+	}
+
+	// Rotate while "two" is unterminated, and write to the old file once
+	// more, as a logger still holding it would.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path+".1", "-more\nlast")
+	appendFile(t, path, "new\n")
+
+	for _, want := range []string{"two-more", "last", "new"} {
+		if got := next(t, out); got != want {
+			t.Errorf("line %q, want %q", got, want)
+		}
+	}
+}
+
+func nextEntry(t *testing.T, out <-chan protocol.LogEntry) protocol.LogEntry {
+	t.Helper()
+	select {
+	case e := <-out:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no line from the tail")
+		return protocol.LogEntry{}
+	}
+}
+
+func TestFileTailIDs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	offsetsFile := filepath.Join(dir, "offsets.json")
+	offsets, err := LoadOffsets(offsetsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Empty at open, as right after a rotation
+	appendFile(t, path, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan protocol.LogEntry, 16)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		(&FileTail{Path: path, Offsets: offsets}).Run(ctx, out)
+	}()
+
+	appendFile(t, path, "same\n")
+	first := nextEntry(t, out)
+
+	// The next file starts with the same line at the same offset
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "")
+	time.Sleep(2 * tailPoll)
+	appendFile(t, path, "same\n")
+	second := nextEntry(t, out)
+	if first.ID == second.ID {
+		t.Fatalf("rotated file reused ID %s", first.ID)
+	}
+
+	appendFile(t, path, "more\n")
+	third := nextEntry(t, out)
+	cancel()
+	<-done
+
+	// After a restart a re-read line keeps its ID
+	saved, _ := offsets.Get(path)
+	saved.Offset = 0
+	offsets.Set(path, saved)
+	offsets, err = LoadOffsets(offsetsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go (&FileTail{Path: path, Offsets: offsets}).Run(ctx, out)
+	for _, want := range []string{second.ID, third.ID} {
+		if got := nextEntry(t, out).ID; got != want {
+			t.Errorf("re-read ID %s, want %s", got, want)
+		}
+	}
+}
@@ -5,12 +5,24 @@ Write-Host "Initializing Lab 02..."
 go mod tidy
 ./build.ps1
 
+# The redaction hash key must stay secret; keep a random one outside git
+if (-not (Test-Path hash_key.txt)) {
+    Write-Host "Creating hash_key.txt with a random key..."
+    $bytes = New-Object byte[] 32
+    [System.Security.Cryptography.RandomNumberGenerator]::Create().GetBytes($bytes)
+    -join ($bytes | ForEach-Object { $_.ToString("x2") }) | Set-Content hash_key.txt -NoNewline
+}
+$env:LAB02_HASH_KEY = Get-Content hash_key.txt -Raw
+
 Write-Host "Starting Storage Writer..."
 Start-Process ".\storage_writer.exe" -NoNewWindow
 
+Write-Host "Starting DLQ Consumer..."
+Start-Process ".\dlq_consumer.exe" -NoNewWindow
+
 Write-Host "Starting Log Parsers (2 workers)..."
-Start-Process ".\log_parser.exe" -NoNewWindow
-Start-Process ".\log_parser.exe" -NoNewWindow
+Start-Process ".\log_parser.exe" -ArgumentList "-redaction-rules", "redaction_rules.json", "-geoip-db", "geoip.csv", "-severity-rules", "severity_rules.json" -NoNewWindow
+Start-Process ".\log_parser.exe" -ArgumentList "-redaction-rules", "redaction_rules.json", "-geoip-db", "geoip.csv", "-severity-rules", "severity_rules.json" -NoNewWindow
 
 Start-Sleep -Seconds 1
 Write-Host "Starting Log Collector..."
@@ -20,7 +32,7 @@ Write-Host "Lab 02 running. Press Ctrl+C to stop."
 
 trap {
     Write-Host "Stopping processes..."
-    Stop-Process -Name storage_writer, log_parser, log_collector -ErrorAction SilentlyContinue
+    Stop-Process -Name storage_writer, dlq_consumer, log_parser, log_collector -ErrorAction SilentlyContinue
     exit
 }
 
@@ -11,4 +11,7 @@ if ($LASTEXITCODE -ne 0) { Write-Error "Build node_agent failed"; exit 1 }
 go build -o admin_cli.exe ./cmd/admin_cli
 if ($LASTEXITCODE -ne 0) { Write-Error "Build admin_cli failed"; exit 1 }
 
+go build -o directory.exe ./cmd/directory
+if ($LASTEXITCODE -ne 0) { Write-Error "Build directory failed"; exit 1 }
+
 Write-Host "Build complete." -ForegroundColor Green
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+// commandNames lists the subcommands in usage order
+var commandNames = []string{"CPU", "MEM", "HOST", "DISK", "NET", "PROCS"}
+
+// parseCommand builds the request from the command and its own flags,
+// e.g. ["procs", "--top", "10", "--sort", "mem"].
+func parseCommand(args []string) (protocol.Request, error) {
+	name := strings.ToUpper(args[0])
+	fs := flag.NewFlagSet(strings.ToLower(name), flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	req := protocol.NewRequest(protocol.CommandType(name))
+	switch req.Command {
+	case protocol.CMD_CPU, protocol.CMD_MEM, protocol.CMD_HOST:
+		// no arguments
+	case protocol.CMD_DISK:
+		req.Disk = &protocol.DiskArgs{}
+		fs.BoolVar(&req.Disk.All, "all", false, "Include pseudo file systems")
+	case protocol.CMD_NET:
+		req.Net = &protocol.NetArgs{}
+		fs.StringVar(&req.Net.Interface, "iface", "", "Only interfaces matching this glob")
+		fs.BoolVar(&req.Net.Connections, "conns", false, "List the open connections")
+		fs.StringVar(&req.Net.Kind, "kind", "inet", "Connection kind: tcp, udp, inet, inet4, inet6, ...")
+		fs.StringVar(&req.Net.State, "state", "", "Only connections in this state, e.g. LISTEN (implies -conns)")
+	case protocol.CMD_PROCS:
+		req.Procs = &protocol.ProcsArgs{}
+		fs.IntVar(&req.Procs.Top, "top", 10, "Number of processes per node")
+		fs.StringVar(&req.Procs.Sort, "sort", protocol.SortCPU, "Sort by cpu, mem, pid or name")
+		fs.StringVar(&req.Procs.Name, "name", "", "Only processes whose name contains this")
+		fs.StringVar(&req.Procs.User, "user", "", "Only processes of this user")
+	default:
+		return req, fmt.Errorf("unknown command: %s. Available: %s", name, strings.Join(commandNames, ", "))
+	}
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return req, fmt.Errorf("%s: %w", name, err)
+	}
+	if fs.NArg() > 0 {
+		return req, fmt.Errorf("%s: unexpected arguments %q", name, fs.Args())
+	}
+
+	switch {
+	case req.Net != nil && req.Net.State != "":
+		req.Net.Connections = true
+	case req.Procs != nil:
+		if req.Procs.Top < 1 {
+			return req, fmt.Errorf("%s: -top must be at least 1", name)
+		}
+		switch req.Procs.Sort {
+		case protocol.SortCPU, protocol.SortMem, protocol.SortPID, protocol.SortName:
+		default:
+			return req, fmt.Errorf("%s: unknown sort order %q (cpu, mem, pid, name)", name, req.Procs.Sort)
+		}
+	}
+	return req, nil
+}
@@ -2,104 +2,167 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"strings"
-	"time"
 
+	"gemini-zeromq-labs/lab03/internal/auth"
+	"gemini-zeromq-labs/lab03/internal/client"
 	"gemini-zeromq-labs/lab03/internal/config"
 	"gemini-zeromq-labs/lab03/internal/protocol"
-
-	"github.com/go-zeromq/zmq4"
 )
 
 func main() {
 	cfg := config.LoadConfig()
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
 
-	// Get command from args
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: admin_cli <COMMAND>")
-		fmt.Println("Commands: CPU, MEM, HOST")
+	// Get command from args (flags come first)
+	if flag.NArg() < 1 {
+		usage()
 		os.Exit(1)
 	}
 
-	cmdStr := strings.ToUpper(os.Args[len(os.Args)-1]) // Last arg is command
-	var cmdType protocol.CommandType
-
-	switch cmdStr {
-	case "CPU":
-		cmdType = protocol.CMD_CPU
-	case "MEM":
-		cmdType = protocol.CMD_MEM
-	case "HOST":
-		cmdType = protocol.CMD_HOST
+	// The command may carry its own flags, e.g. "procs --top 10 --sort mem"
+	var req protocol.Request
+	var watch *watchOptions
+	var err error
+	listAgents := false
+	switch strings.ToUpper(flag.Arg(0)) {
+	case "WATCH":
+		var o watchOptions
+		o, err = parseWatch(flag.Args()[1:])
+		watch = &o
+	case string(protocol.CMD_AGENTS):
+		listAgents = true
+		if flag.NArg() > 1 {
+			err = fmt.Errorf("AGENTS: unexpected arguments %q", flag.Args()[1:])
+		} else if cfg.Directory == "" {
+			err = fmt.Errorf("AGENTS needs -directory")
+		}
 	default:
-		fmt.Printf("Unknown command: %s. Available: CPU, MEM, HOST\n", cmdStr)
+		req, err = parseCommand(flag.Args())
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
 
-	// Setup context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Create REQ socket
-	socket := zmq4.NewReq(ctx)
-	defer socket.Close()
-
-	connectAddr := cfg.ConnectAddr()
-	logger.Info("Connecting to node agent", "endpoint", connectAddr)
-	if err := socket.Dial(connectAddr); err != nil {
-		logger.Error("Failed to connect", "error", err)
+	format, err := parseFormat(cfg.Output)
+	if err != nil {
+		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
 
-	// Prepare Request
-	req := protocol.Request{Command: cmdType}
-	reqBytes, _ := req.ToBytes()
+	// Sign requests when a key is configured
+	var signer *auth.Signer
+	if cfg.KeysFile != "" {
+		if signer, err = auth.NewSigner(cfg.KeysFile, cfg.ClientID); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+	}
 
-	// Send Request
-	logger.Info("Sending request", "command", cmdType)
-	if err := socket.Send(zmq4.NewMsg(reqBytes)); err != nil {
-		logger.Error("Failed to send request", "error", err)
+	// Ask the directory for the agents matching -l
+	var registered []protocol.AgentInfo
+	if cfg.Directory != "" {
+		dir, err := client.ParseTarget(cfg.Directory, cfg.DirectoryPort)
+		if err == nil {
+			registered, err = client.Agents(context.Background(), dir, cfg.Selector, cfg.Timeout, signer)
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+	} else if cfg.Selector != "" {
+		fmt.Println("Error: -l needs -directory")
 		os.Exit(1)
 	}
+	if listAgents {
+		if err := renderAgents(os.Stdout, format, registered); err != nil {
+			logger.Error("Failed to write output", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Wait for Reply
-	// Since REQ-REP is synchronous, Recv() will block until reply or context timeout
-	msg, err := socket.Recv()
+	// Resolve the targets: directory matches plus -targets/-targets-file;
+	// without any of these, query the single -host/-port agent as before
+	targets, err := client.LoadTargets(cfg.Targets, cfg.TargetsFile, cfg.Port)
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			fmt.Println("Error: Timeout waiting for response from Node Agent.")
-		} else {
-			logger.Error("Error receiving reply", "error", err)
-		}
+		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
+	targets = client.Merge(client.AgentTargets(registered), targets)
+	if len(targets) == 0 && cfg.Directory == "" {
+		targets = []client.Target{{Name: cfg.Host, Endpoint: cfg.ConnectAddr()}}
+	}
+	if targets, err = client.Select(targets, cfg.Select); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if len(targets) == 0 {
+		fmt.Println("Error: no targets match", describeSelection(cfg))
+		os.Exit(1)
+	}
+
+	if watch != nil {
+		logger.Info("Watching", "commands", watch.args.Commands, "targets", len(targets), "interval", watch.interval.String())
+		if err := runWatch(targets, *watch, format, cfg.Timeout, signer, logger); err != nil {
+			logger.Error("Failed to write output", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Query every target concurrently, each with its own timeout
+	logger.Info("Sending request", "command", req.Command, "targets", len(targets), "timeout", cfg.Timeout.String())
+	results := client.FanOut(context.Background(), targets, req, cfg.Timeout, cfg.Parallel, signer)
 
-	// Parse Response
-	var resp protocol.Response
-	if err := json.Unmarshal(msg.Bytes(), &resp); err != nil {
-		logger.Error("Invalid response format", "error", err)
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			logger.Warn("Target failed", "target", r.Target.Name, "endpoint", r.Target.Endpoint, "error", r.Err)
+		}
+	}
+
+	if err := render(os.Stdout, format, results); err != nil {
+		logger.Error("Failed to write output", "error", err)
 		os.Exit(1)
 	}
 
-	if resp.Status == "ERROR" {
-		fmt.Printf("Remote Error: %s\n", resp.Error)
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d targets failed\n", failed, len(results))
 		os.Exit(1)
 	}
+}
 
-	// Pretty print data
-	printResult(cmdType, resp.Data)
+func describeSelection(cfg *config.Config) string {
+	var parts []string
+	if cfg.Selector != "" {
+		parts = append(parts, fmt.Sprintf("labels %q", cfg.Selector))
+	}
+	if cfg.Select != "" {
+		parts = append(parts, fmt.Sprintf("names %q", cfg.Select))
+	}
+	if len(parts) == 0 {
+		return "(the directory has no agents)"
+	}
+	return strings.Join(parts, " and ")
 }
 
-func printResult(cmd protocol.CommandType, data interface{}) {
-	fmt.Println("\n--- Remote Node Status ---")
-	
-	// Re-marshal to pretty print generic interface{} map
-	b, _ := json.MarshalIndent(data, "", "  ")
-	fmt.Println(string(b))
-	fmt.Println("--------------------------")
+func usage() {
+	fmt.Println("Usage: admin_cli [flags] <COMMAND> [command flags]")
+	fmt.Println("Commands: " + strings.Join(commandNames, ", "))
+	fmt.Println("  DISK  [-all]")
+	fmt.Println("  NET   [-iface 'eth*'] [-conns] [-kind tcp|udp|inet] [-state LISTEN]")
+	fmt.Println("  PROCS [-top 10] [-sort cpu|mem|pid|name] [-name nginx] [-user root]")
+	fmt.Println("  WATCH <COMMAND>... [-interval 2s] [-lease 10s] [-count N]   live view, e.g. watch CPU MEM")
+	fmt.Println("  AGENTS                                    list the agents in -directory")
+	fmt.Println("Targets:  -targets a,b:5559  -targets-file 'nodes/*.txt'  -select 'db-*'")
+	fmt.Println("          -directory dir-host[:5561] [-l env=prod,role=db]")
+	fmt.Println("Output:   -o table|json|csv  -timeout 5s")
+	fmt.Println("Auth:     -keys clients.json -client-id ops")
 }
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/client"
+	"gemini-zeromq-labs/lab03/internal/directory"
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+type outputFormat string
+
+const (
+	formatTable outputFormat = "table"
+	formatJSON  outputFormat = "json"
+	formatCSV   outputFormat = "csv"
+)
+
+func parseFormat(s string) (outputFormat, error) {
+	switch f := outputFormat(s); f {
+	case formatTable, formatJSON, formatCSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (table, json, csv)", s)
+}
+
+func render(w io.Writer, format outputFormat, results []client.Result) error {
+	switch format {
+	case formatJSON:
+		return renderJSON(w, results)
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(matrix(results)); err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, row := range matrix(results) {
+			for i, cell := range row {
+				if i > 0 {
+					fmt.Fprint(tw, "\t")
+				}
+				fmt.Fprint(tw, cell)
+			}
+			fmt.Fprintln(tw)
+		}
+		return tw.Flush()
+	}
+}
+
+// matrix lays the results out with one row per field and one column per node.
+// The first rows are the status and the error, so unreachable agents stand out.
+// Fields keep the order of the payload struct.
+func matrix(results []client.Result) [][]string {
+	header := []string{"FIELD"}
+	status := []string{"status"}
+	errRow := []string{"error"}
+	hasErr := false
+
+	values := make([]*record, len(results))
+	var fields []string
+	for i, r := range results {
+		header = append(header, r.Target.Name)
+		if r.Err != nil {
+			hasErr = true
+			kind, msg := describe(r.Err)
+			status = append(status, kind)
+			errRow = append(errRow, msg)
+		} else {
+			status = append(status, "OK")
+			errRow = append(errRow, "")
+		}
+
+		values[i] = &record{values: make(map[string]string)}
+		if r.Payload != nil {
+			flatten("", reflect.ValueOf(r.Payload), values[i])
+		}
+		fields = merge(fields, values[i].keys)
+	}
+
+	rows := [][]string{header, status}
+	if hasErr {
+		rows = append(rows, errRow)
+	}
+	for _, f := range fields {
+		row := []string{f}
+		for i := range results {
+			v, ok := values[i].values[f]
+			if !ok {
+				v = "-"
+			}
+			row = append(row, v)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// record is one flattened payload, keys in payload order
+type record struct {
+	keys   []string
+	values map[string]string
+}
+
+func (r *record) set(k, v string) {
+	if _, ok := r.values[k]; !ok {
+		r.keys = append(r.keys, k)
+	}
+	r.values[k] = v
+}
+
+// merge adds the keys missing from fields, each right after its predecessor in keys,
+// so a node with more list entries than the others extends the list in place.
+func merge(fields, keys []string) []string {
+	pos := make(map[string]int, len(fields))
+	for i, f := range fields {
+		pos[f] = i
+	}
+	for i, k := range keys {
+		if _, ok := pos[k]; ok {
+			continue
+		}
+		at := len(fields)
+		if i > 0 {
+			at = pos[keys[i-1]] + 1
+		}
+		fields = slices.Insert(fields, at, k)
+		for j := at; j < len(fields); j++ {
+			pos[fields[j]] = j
+		}
+	}
+	return fields
+}
+
+// describe splits a target error into its kind and message.
+func describe(err error) (string, string) {
+	var te *client.TargetError
+	if errors.As(err, &te) {
+		return string(te.Kind), te.Err.Error()
+	}
+	return "ERROR", err.Error()
+}
+
+// flatten turns a payload into dotted keys named after the JSON tags:
+// ProcsData{Processes: [{PID: 1}]} -> processes.0.pid
+func flatten(prefix string, v reflect.Value, out *record) {
+	join := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+	// Values with their own text form, like time.Time, stay one field
+	if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface && v.CanInterface() {
+		if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+			text, err := tm.MarshalText()
+			if err == nil {
+				out.set(prefix, string(text))
+				return
+			}
+		}
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			if prefix != "" {
+				out.set(prefix, "")
+			}
+			return
+		}
+		flatten(prefix, v.Elem(), out)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			flatten(join(name), v.Field(i), out)
+		}
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		byName := make(map[string]reflect.Value, v.Len())
+		for _, k := range v.MapKeys() {
+			name := fmt.Sprint(k.Interface())
+			keys = append(keys, name)
+			byName[name] = v.MapIndex(k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			flatten(join(k), byName[k], out)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			flatten(join(strconv.Itoa(i)), v.Index(i), out)
+		}
+	case reflect.Float32:
+		out.set(prefix, strconv.FormatFloat(v.Float(), 'f', -1, 32))
+	case reflect.Float64:
+		out.set(prefix, strconv.FormatFloat(v.Float(), 'f', -1, 64))
+	default:
+		out.set(prefix, fmt.Sprint(v.Interface()))
+	}
+}
+
+type jsonResult struct {
+	Target    string      `json:"target"`
+	Endpoint  string      `json:"endpoint"`
+	Status    string      `json:"status"`         // OK or the error kind
+	Code      string      `json:"code,omitempty"` // error code of a REMOTE error
+	Error     string      `json:"error,omitempty"`
+	ElapsedMS int64       `json:"elapsed_ms"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+func renderJSON(w io.Writer, results []client.Result) error {
+	out := make([]jsonResult, 0, len(results))
+	for _, r := range results {
+		jr := jsonResult{
+			Target:    r.Target.Name,
+			Endpoint:  r.Target.Endpoint,
+			Status:    "OK",
+			ElapsedMS: r.Elapsed.Milliseconds(),
+		}
+		jr.Data = r.Payload
+		if r.Err != nil {
+			jr.Status, jr.Error = describe(r.Err)
+			var pe *protocol.Error
+			if errors.As(r.Err, &pe) {
+				jr.Code = string(pe.Code)
+			}
+		}
+		out = append(out, jr)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// renderAgents lists directory entries, one row per agent.
+func renderAgents(w io.Writer, format outputFormat, agents []protocol.AgentInfo) error {
+	if format == formatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(agents)
+	}
+
+	now := time.Now()
+	rows := [][]string{{"NAME", "ENDPOINT", "LABELS", "HOSTNAME", "PLATFORM", "LAST_SEEN", "EXPIRES_IN"}}
+	for _, a := range agents {
+		rows = append(rows, []string{
+			a.Name,
+			a.Endpoint,
+			directory.FormatLabels(a.Labels),
+			a.Host.Hostname,
+			a.Host.Platform,
+			now.Sub(a.LastSeen).Round(time.Second).String() + " ago",
+			a.Expires.Sub(now).Round(time.Second).String(),
+		})
+	}
+
+	if format == formatCSV {
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
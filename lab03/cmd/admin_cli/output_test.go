@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"gemini-zeromq-labs/lab03/internal/client"
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+func cpuResult(name string, usage ...float64) client.Result {
+	return client.Result{
+		Target:  client.Target{Name: name, Endpoint: "tcp://" + name + ":5555"},
+		Payload: &protocol.CPUData{Model: "x", Cores: len(usage), UsagePercent: usage},
+	}
+}
+
+func failed(name string, kind client.ErrorKind, msg string) client.Result {
+	return client.Result{
+		Target: client.Target{Name: name, Endpoint: "tcp://" + name + ":5555"},
+		Err:    &client.TargetError{Kind: kind, Err: errors.New(msg)},
+	}
+}
+
+// rowsByField indexes matrix rows by their first cell
+func rowsByField(rows [][]string) map[string][]string {
+	out := make(map[string][]string, len(rows))
+	for _, r := range rows {
+		out[r[0]] = r[1:]
+	}
+	return out
+}
+
+func TestMatrixErrorRow(t *testing.T) {
+	rows := matrix([]client.Result{cpuResult("a", 10), failed("b", client.Timeout, "no reply within 2s")})
+	byField := rowsByField(rows)
+
+	cases := []struct {
+		field string
+		want  []string
+	}{
+		{"FIELD", []string{"a", "b"}},
+		{"status", []string{"OK", "TIMEOUT"}},
+		{"error", []string{"", "no reply within 2s"}},
+		{"cores", []string{"1", "-"}},
+		{"usage_percent.0", []string{"10", "-"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.field, func(t *testing.T) {
+			if got := byField[tc.field]; !slices.Equal(got, tc.want) {
+				t.Errorf("row %s = %v, want %v", tc.field, got, tc.want)
+			}
+		})
+	}
+	if !slices.Equal(rows[0][:1], []string{"FIELD"}) || rows[1][0] != "status" || rows[2][0] != "error" {
+		t.Errorf("first rows %v, want header, status and error", rows[:3])
+	}
+
+	// Without failures there is no error row
+	if _, ok := rowsByField(matrix([]client.Result{cpuResult("a", 1)}))["error"]; ok {
+		t.Error("error row without any failed target")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	cases := []struct {
+		name   string
+		fields []string
+		keys   []string
+		want   []string
+	}{
+		{"first node", nil, []string{"cores", "usage.0"}, []string{"cores", "usage.0"}},
+		{"longer list extends in place", []string{"cores", "usage.0", "model"}, []string{"cores", "usage.0", "usage.1", "usage.2", "model"},
+			[]string{"cores", "usage.0", "usage.1", "usage.2", "model"}},
+		{"shorter list adds nothing", []string{"cores", "usage.0", "usage.1", "model"}, []string{"cores", "usage.0", "model"},
+			[]string{"cores", "usage.0", "usage.1", "model"}},
+		{"new leading key", []string{"b"}, []string{"a", "b"}, []string{"b", "a"}},
+		{"new trailing key", []string{"a"}, []string{"a", "z"}, []string{"a", "z"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := merge(slices.Clone(tc.fields), tc.keys); !slices.Equal(got, tc.want) {
+				t.Errorf("merge = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatrixUnevenLists(t *testing.T) {
+	rows := matrix([]client.Result{cpuResult("a", 1), cpuResult("b", 1, 2, 3)})
+	var fields []string
+	for _, r := range rows {
+		fields = append(fields, r[0])
+	}
+	want := []string{"FIELD", "status", "model", "cores", "usage_percent.0", "usage_percent.1", "usage_percent.2", "sampled_at"}
+	if !slices.Equal(fields, want) {
+		t.Errorf("fields %v, want %v", fields, want)
+	}
+	if got := rowsByField(rows)["usage_percent.2"]; !slices.Equal(got, []string{"-", "3"}) {
+		t.Errorf("usage_percent.2 = %v, want [- 3]", got)
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	var buf bytes.Buffer
+	results := []client.Result{cpuResult("a", 12.5), failed("b", client.Unreachable, "refused, retry")}
+	if err := render(&buf, formatCSV, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"FIELD,a,b",
+		"status,OK,UNREACHABLE",
+		`error,,"refused, retry"`,
+		"model,x,-",
+		"cores,1,-",
+		"usage_percent.0,12.5,-",
+	}
+	if len(lines) < len(want) || !slices.Equal(lines[:len(want)], want) {
+		t.Errorf("CSV =\n%s\nwant it to start with\n%s", buf.String(), strings.Join(want, "\n"))
+	}
+}
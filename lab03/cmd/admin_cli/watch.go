@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/auth"
+	"gemini-zeromq-labs/lab03/internal/client"
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+// watchOptions is a parsed "watch" command
+type watchOptions struct {
+	args     protocol.WatchArgs
+	interval time.Duration
+	count    int // refreshes before exiting, 0 = until interrupted
+}
+
+// parseWatch parses the arguments after "watch": commands and flags in any
+// order, e.g. ["CPU", "--interval", "2s", "MEM"].
+func parseWatch(args []string) (watchOptions, error) {
+	var o watchOptions
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.DurationVar(&o.interval, "interval", 2*time.Second, "Time between samples")
+	lease := fs.Duration("lease", 10*time.Second, "Stream lease, renewed every third of it")
+	fs.IntVar(&o.count, "count", 0, "Exit after this many refreshes (0 = until Ctrl+C)")
+
+	for rest := args; ; {
+		if err := fs.Parse(rest); err != nil {
+			return o, fmt.Errorf("WATCH: %w", err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		cmd := protocol.CommandType(strings.ToUpper(fs.Arg(0)))
+		if !protocol.Watchable(cmd) {
+			return o, fmt.Errorf("WATCH: cannot watch %q", fs.Arg(0))
+		}
+		o.args.Commands = append(o.args.Commands, cmd)
+		rest = fs.Args()[1:]
+	}
+	if len(o.args.Commands) == 0 {
+		return o, errors.New("WATCH: name the commands to watch, e.g. watch CPU MEM --interval 2s")
+	}
+	if o.interval <= 0 || *lease <= 0 {
+		return o, errors.New("WATCH: -interval and -lease must be positive")
+	}
+	o.args.IntervalMS = o.interval.Milliseconds()
+	o.args.LeaseMS = lease.Milliseconds()
+	return o, nil
+}
+
+// runWatch shows the latest sample of every target, refreshed every interval,
+// until interrupted or count refreshes are done. Interrupting stops the streams;
+// if the CLI is killed instead, their leases run out.
+func runWatch(targets []client.Target, o watchOptions, format outputFormat, timeout time.Duration, signer *auth.Signer, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// latest[command][i] is the last result of targets[i]
+	index := make(map[string]int, len(targets))
+	latest := make(map[protocol.CommandType][]client.Result)
+	for _, c := range o.args.Commands {
+		rs := make([]client.Result, len(targets))
+		for i, t := range targets {
+			index[t.Endpoint] = i
+			rs[i] = client.Result{Target: t, Err: &client.TargetError{Kind: client.Waiting, Err: errors.New("no sample yet")}}
+		}
+		latest[c] = rs
+	}
+
+	events := make(chan client.Event, 64)
+	done := make(chan struct{})
+	go func() {
+		client.Watch(ctx, targets, o.args, timeout, signer, events)
+		close(done)
+	}()
+
+	clearScreen := format == formatTable && isTerminal(os.Stdout)
+	refresh := time.NewTicker(o.interval)
+	defer refresh.Stop()
+	refreshes, drawn := 0, false
+	draw := func() error {
+		if clearScreen {
+			fmt.Print("\033[H\033[2J")
+		}
+		if format == formatTable {
+			fmt.Printf("Every %s on %d agent(s), %s (Ctrl+C to stop)\n", o.interval, len(targets), time.Now().Format("15:04:05"))
+		}
+		for _, c := range o.args.Commands {
+			if format == formatTable {
+				fmt.Printf("\n== %s ==\n", c)
+			}
+			if err := render(os.Stdout, format, latest[c]); err != nil {
+				return err
+			}
+		}
+		drawn = true
+		refreshes++
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			<-done
+			return nil
+
+		case e := <-events:
+			i := index[e.Target.Endpoint]
+			if e.Err != nil {
+				logger.Warn("Watch failed", "target", e.Target.Name, "error", e.Err)
+				for _, c := range o.args.Commands {
+					latest[c][i] = client.Result{Target: e.Target, Err: e.Err}
+				}
+				continue
+			}
+			s := e.Sample
+			r := client.Result{Target: e.Target, Response: &s.Response}
+			if err := s.Response.Err(); err != nil {
+				r.Err = &client.TargetError{Kind: client.Remote, Err: err}
+			} else if r.Payload, err = s.Response.Decode(s.Command); err != nil {
+				r.Err = &client.TargetError{Kind: client.Invalid, Err: err}
+			}
+			if rs, ok := latest[s.Command]; ok {
+				rs[i] = r
+			}
+			// Show the first samples once every target reported, without waiting for the next refresh
+			if !drawn && !waiting(latest) {
+				if err := draw(); err != nil {
+					return err
+				}
+			}
+
+		case <-refresh.C:
+			if err := draw(); err != nil {
+				return err
+			}
+			if o.count > 0 && refreshes >= o.count {
+				stop()
+				<-done
+				return nil
+			}
+		}
+	}
+}
+
+// waiting reports whether some target has not sent a sample of some command yet.
+func waiting(latest map[protocol.CommandType][]client.Result) bool {
+	for _, rs := range latest {
+		for _, r := range rs {
+			var te *client.TargetError
+			if errors.As(r.Err, &te) && te.Kind == client.Waiting {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
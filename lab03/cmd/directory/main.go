@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/auth"
+	"gemini-zeromq-labs/lab03/internal/config"
+	"gemini-zeromq-labs/lab03/internal/directory"
+	"gemini-zeromq-labs/lab03/internal/protocol"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+func main() {
+	cfg := config.LoadConfig()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Signal handling
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		logger.Info("Shutdown signal received")
+		cancel()
+	}()
+
+	// With a keys file registrations must be signed, and a name stays with
+	// the client that registered it. Lookups stay open.
+	var verifier *auth.Verifier
+	if cfg.KeysFile != "" {
+		keys, err := auth.LoadKeys(cfg.KeysFile)
+		if err != nil {
+			logger.Error("Failed to load keys", "error", err)
+			os.Exit(1)
+		}
+		policy, err := auth.LoadPolicy(cfg.PolicyFile)
+		if err != nil {
+			logger.Error("Failed to load policy", "error", err)
+			os.Exit(1)
+		}
+		verifier = auth.NewVerifier(keys, policy, cfg.MaxSkew)
+		logger.Info("Registration authentication enabled", "keys", cfg.KeysFile, "policy", cfg.PolicyFile, "max_skew", cfg.MaxSkew.String())
+	} else {
+		logger.Warn("Registration authentication disabled; anyone who can connect may register any name")
+	}
+
+	// Agents register and clients look them up over the same ROUTER;
+	// every request is quick, so one loop serves them all
+	socket := zmq4.NewRouter(ctx)
+	defer socket.Close()
+
+	bindAddr := cfg.DirectoryBindAddr()
+	logger.Info("Directory listening", "endpoint", bindAddr)
+	if err := socket.Listen(bindAddr); err != nil {
+		logger.Error("Failed to listen", "error", err)
+		os.Exit(1)
+	}
+
+	msgChan := make(chan zmq4.Msg, 64)
+	go func() {
+		for {
+			msg, err := socket.Recv()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			msgChan <- msg
+		}
+	}()
+
+	registry := directory.NewRegistry()
+	expiry := time.NewTicker(time.Second)
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case now := <-expiry.C:
+			for _, a := range registry.Expire(now) {
+				logger.Info("Agent expired, no heartbeat within TTL", "name", a.Name, "endpoint", a.Endpoint, "last_seen", a.LastSeen)
+			}
+
+		case msg := <-msgChan:
+			// [identity, (empty delimiter from REQ,) request]
+			if len(msg.Frames) < 2 {
+				continue
+			}
+			envelope, body := msg.Frames[:len(msg.Frames)-1], msg.Frames[len(msg.Frames)-1]
+
+			var resp protocol.Response
+			if req, err := protocol.FromBytes(body); err != nil {
+				resp = protocol.Fail(nil, protocol.ErrBadRequest, "Invalid JSON format")
+			} else {
+				resp = handle(registry, verifier, body, req, logger)
+			}
+
+			bytes, _ := resp.ToBytes()
+			frames := append(append([][]byte(nil), envelope...), bytes)
+			if err := socket.Send(zmq4.NewMsgFrom(frames...)); err != nil {
+				logger.Error("Error sending response", "error", err)
+			}
+		}
+	}
+}
+
+// handle serves one request decoded from raw. A nil verifier accepts
+// unsigned registrations, all owned by the anonymous client "".
+func handle(registry *directory.Registry, verifier *auth.Verifier, raw []byte, req *protocol.Request, logger *slog.Logger) protocol.Response {
+	now := time.Now()
+	var data interface{}
+
+	switch req.Command {
+	case protocol.CMD_REGISTER:
+		var owner string
+		if verifier != nil {
+			d := verifier.Check(raw, req, now)
+			if !d.Allowed {
+				logger.Warn("Registration denied", "client", d.Client, "id", req.ID, "reason", d.Reason)
+				msg := "authentication failed"
+				if d.Authenticated {
+					msg = d.Reason
+				}
+				return protocol.Fail(req, protocol.ErrUnauthorized, msg)
+			}
+			owner = d.Client
+		}
+
+		args := req.Register
+		if args == nil || args.Agent.Name == "" || args.Agent.Endpoint == "" {
+			return protocol.Fail(req, protocol.ErrBadRequest, "registration needs a name and an endpoint")
+		}
+		taken := func() protocol.Response {
+			logger.Warn("Registration denied, name taken", "name", args.Agent.Name, "client", owner)
+			return protocol.Fail(req, protocol.ErrUnauthorized, fmt.Sprintf("name %q is registered by another client", args.Agent.Name))
+		}
+		if args.Leave {
+			left, err := registry.Leave(args.Agent.Name, owner)
+			if err != nil {
+				return taken()
+			}
+			if left {
+				logger.Info("Agent left", "name", args.Agent.Name)
+			}
+			data = protocol.RegisterData{}
+			break
+		}
+		ttl, added, err := registry.Register(args.Agent, owner, time.Duration(args.TTLMS)*time.Millisecond, now)
+		if err != nil {
+			return taken()
+		}
+		if added {
+			logger.Info("Agent registered", "name", args.Agent.Name, "client", owner, "endpoint", args.Agent.Endpoint,
+				"labels", directory.FormatLabels(args.Agent.Labels), "ttl", ttl.String(), "agents", registry.Len())
+		}
+		data = protocol.RegisterData{TTLMS: ttl.Milliseconds(), Expires: now.Add(ttl)}
+
+	case protocol.CMD_AGENTS:
+		var selector string
+		if req.Agents != nil {
+			selector = req.Agents.Selector
+		}
+		sel, err := directory.ParseSelector(selector)
+		if err != nil {
+			return protocol.Fail(req, protocol.ErrBadRequest, err.Error())
+		}
+		data = protocol.AgentsData{Agents: registry.List(sel, now)}
+
+	default:
+		return protocol.Fail(req, protocol.ErrUnknownCommand, fmt.Sprintf("unknown command %q", req.Command))
+	}
+
+	resp, err := protocol.OK(req, data)
+	if err != nil {
+		return protocol.Fail(req, protocol.ErrCollectionFailed, err.Error())
+	}
+	return resp
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/auth"
+	"gemini-zeromq-labs/lab03/internal/config"
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+// authorizer checks signed requests and writes every decision to the audit log
+type authorizer struct {
+	verifier *auth.Verifier
+	audit    *slog.Logger
+	file     *os.File
+}
+
+func newAuthorizer(cfg *config.Config) (*authorizer, error) {
+	keys, err := auth.LoadKeys(cfg.KeysFile)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := auth.LoadPolicy(cfg.PolicyFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(cfg.AuditLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &authorizer{
+		verifier: auth.NewVerifier(keys, policy, cfg.MaxSkew),
+		audit:    slog.New(slog.NewJSONHandler(f, nil)),
+		file:     f,
+	}, nil
+}
+
+// check returns nil if req may run, otherwise the UNAUTHORIZED reply.
+// Clients only learn whether the policy or the authentication failed; the
+// audit log has the details.
+func (a *authorizer) check(raw []byte, req *protocol.Request) *protocol.Response {
+	d := a.verifier.Check(raw, req, time.Now())
+
+	attrs := []any{"client", d.Client, "command", req.Command, "id", req.ID, "allowed", d.Allowed}
+	if d.Allowed {
+		a.audit.Info("Request authorized", append(attrs, "role", d.Role)...)
+		return nil
+	}
+	a.audit.Warn("Request denied", append(attrs, "reason", d.Reason)...)
+
+	msg := "authentication failed"
+	if d.Authenticated {
+		msg = d.Reason
+	}
+	resp := protocol.Fail(req, protocol.ErrUnauthorized, msg)
+	return &resp
+}
+
+func (a *authorizer) Close() error {
+	return a.file.Close()
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"gemini-zeromq-labs/lab03/internal/protocol"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	psnet "github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const defaultTop = 10
+
+// connKinds are the connection kinds gopsutil understands.
+var connKinds = map[string]bool{
+	"all": true, "inet": true, "inet4": true, "inet6": true,
+	"tcp": true, "tcp4": true, "tcp6": true,
+	"udp": true, "udp4": true, "udp6": true, "unix": true,
+}
+
+// badRequest reports invalid arguments, which are checked before anything is
+// collected, as BAD_REQUEST rather than COLLECTION_FAILED.
+func badRequest(format string, args ...interface{}) error {
+	return &protocol.Error{Code: protocol.ErrBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+func getDiskInfo(args *protocol.DiskArgs) (protocol.DiskData, error) {
+	if args == nil {
+		args = &protocol.DiskArgs{}
+	}
+	parts, err := disk.Partitions(args.All)
+	if err != nil {
+		return protocol.DiskData{}, err
+	}
+
+	data := protocol.DiskData{Partitions: []protocol.DiskUsage{}}
+	for _, p := range parts {
+		u, err := disk.Usage(p.Mountpoint)
+		if err != nil {
+			continue // unmounted in the meantime or not accessible
+		}
+		data.Partitions = append(data.Partitions, protocol.DiskUsage{
+			Mountpoint:  p.Mountpoint,
+			Device:      p.Device,
+			Fstype:      p.Fstype,
+			Total:       u.Total,
+			Used:        u.Used,
+			Free:        u.Free,
+			UsedPercent: u.UsedPercent,
+		})
+	}
+	sort.Slice(data.Partitions, func(i, j int) bool {
+		return data.Partitions[i].Mountpoint < data.Partitions[j].Mountpoint
+	})
+	return data, nil
+}
+
+func getNetInfo(args *protocol.NetArgs) (protocol.NetData, error) {
+	if args == nil {
+		args = &protocol.NetArgs{}
+	}
+	if args.Interface != "" {
+		if _, err := path.Match(args.Interface, ""); err != nil {
+			return protocol.NetData{}, badRequest("interface pattern %q: %v", args.Interface, err)
+		}
+	}
+	kind := args.Kind
+	if kind == "" {
+		kind = "inet"
+	}
+	if !connKinds[kind] {
+		return protocol.NetData{}, badRequest("unknown connection kind %q", kind)
+	}
+
+	counters, err := psnet.IOCounters(true)
+	if err != nil {
+		return protocol.NetData{}, err
+	}
+	addrs := make(map[string][]string)
+	if ifaces, err := psnet.Interfaces(); err == nil {
+		for _, i := range ifaces {
+			for _, a := range i.Addrs {
+				addrs[i.Name] = append(addrs[i.Name], a.Addr)
+			}
+		}
+	}
+
+	data := protocol.NetData{
+		Interfaces: []protocol.NetInterface{},
+		ConnStates: make(map[string]int),
+	}
+	for _, c := range counters {
+		if args.Interface != "" {
+			if ok, _ := path.Match(args.Interface, c.Name); !ok {
+				continue
+			}
+		}
+		data.Interfaces = append(data.Interfaces, protocol.NetInterface{
+			Name:        c.Name,
+			Addrs:       addrs[c.Name],
+			BytesSent:   c.BytesSent,
+			BytesRecv:   c.BytesRecv,
+			PacketsSent: c.PacketsSent,
+			PacketsRecv: c.PacketsRecv,
+			Errin:       c.Errin,
+			Errout:      c.Errout,
+			Dropin:      c.Dropin,
+			Dropout:     c.Dropout,
+		})
+	}
+	sort.Slice(data.Interfaces, func(i, j int) bool {
+		return data.Interfaces[i].Name < data.Interfaces[j].Name
+	})
+
+	conns, err := psnet.Connections(kind)
+	if err != nil {
+		return protocol.NetData{}, fmt.Errorf("connections (%s): %w", kind, err)
+	}
+	state := strings.ToUpper(args.State)
+	for _, c := range conns {
+		s := c.Status
+		if s == "" {
+			s = "NONE" // udp has no state
+		}
+		data.ConnStates[s]++
+		if !args.Connections || (state != "" && s != state) {
+			continue
+		}
+		conn := protocol.NetConn{
+			Proto: connProto(c),
+			Local: joinAddr(c.Laddr),
+			State: c.Status,
+			PID:   c.Pid,
+		}
+		if c.Raddr.Port != 0 {
+			conn.Remote = joinAddr(c.Raddr)
+		}
+		data.Connections = append(data.Connections, conn)
+	}
+	sort.SliceStable(data.Connections, func(i, j int) bool {
+		a, b := data.Connections[i], data.Connections[j]
+		if a.Proto != b.Proto {
+			return a.Proto < b.Proto
+		}
+		return a.Local < b.Local
+	})
+	return data, nil
+}
+
+func connProto(c psnet.ConnectionStat) string {
+	proto := "tcp"
+	if c.Type == syscall.SOCK_DGRAM {
+		proto = "udp"
+	}
+	if c.Family == syscall.AF_INET6 {
+		proto += "6"
+	}
+	return proto
+}
+
+func joinAddr(a psnet.Addr) string {
+	return net.JoinHostPort(a.IP, strconv.FormatUint(uint64(a.Port), 10))
+}
+
+func getProcsInfo(args *protocol.ProcsArgs) (protocol.ProcsData, error) {
+	if args == nil {
+		args = &protocol.ProcsArgs{}
+	}
+	top := args.Top
+	if top <= 0 {
+		top = defaultTop
+	}
+	order := args.Sort
+	if order == "" {
+		order = protocol.SortCPU
+	}
+	less, err := processOrder(order)
+	if err != nil {
+		return protocol.ProcsData{}, err
+	}
+
+	procs, err := process.Processes()
+	if err != nil {
+		return protocol.ProcsData{}, err
+	}
+
+	name := strings.ToLower(args.Name)
+	var infos []protocol.ProcessInfo
+	for _, p := range procs {
+		// Processes can exit while we look at them; skip those
+		n, err := p.Name()
+		if err != nil {
+			continue
+		}
+		if name != "" && !strings.Contains(strings.ToLower(n), name) {
+			continue
+		}
+		user, _ := p.Username()
+		if args.User != "" && user != args.User {
+			continue
+		}
+		info := protocol.ProcessInfo{PID: p.Pid, Name: n, User: user}
+		info.CPUPercent, _ = p.CPUPercent()
+		info.MemPercent, _ = p.MemoryPercent()
+		if m, err := p.MemoryInfo(); err == nil {
+			info.RSS = m.RSS
+		}
+		info.Threads, _ = p.NumThreads()
+		infos = append(infos, info)
+	}
+
+	sort.SliceStable(infos, func(i, j int) bool { return less(infos[i], infos[j]) })
+	data := protocol.ProcsData{Total: len(infos), Sort: order, Processes: []protocol.ProcessInfo{}}
+	if len(infos) > top {
+		infos = infos[:top]
+	}
+	data.Processes = append(data.Processes, infos...)
+	return data, nil
+}
+
+// processOrder returns the comparison for a sort order; the biggest consumers come first.
+func processOrder(order string) (func(a, b protocol.ProcessInfo) bool, error) {
+	switch order {
+	case protocol.SortCPU:
+		return func(a, b protocol.ProcessInfo) bool {
+			if a.CPUPercent != b.CPUPercent {
+				return a.CPUPercent > b.CPUPercent
+			}
+			return a.PID < b.PID
+		}, nil
+	case protocol.SortMem:
+		return func(a, b protocol.ProcessInfo) bool {
+			if a.RSS != b.RSS {
+				return a.RSS > b.RSS
+			}
+			return a.PID < b.PID
+		}, nil
+	case protocol.SortPID:
+		return func(a, b protocol.ProcessInfo) bool { return a.PID < b.PID }, nil
+	case protocol.SortName:
+		return func(a, b protocol.ProcessInfo) bool {
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.PID < b.PID
+		}, nil
+	}
+	return nil, badRequest("unknown sort order %q (cpu, mem, pid, name)", order)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+func TestInspectBadArgs(t *testing.T) {
+	cases := map[string]func() error{
+		"sort order": func() error {
+			_, err := getProcsInfo(&protocol.ProcsArgs{Sort: "size"})
+			return err
+		},
+		"interface glob": func() error {
+			_, err := getNetInfo(&protocol.NetArgs{Interface: "eth["})
+			return err
+		},
+		"connection kind": func() error {
+			_, err := getNetInfo(&protocol.NetArgs{Kind: "sctp"})
+			return err
+		},
+	}
+	for name, call := range cases {
+		t.Run(name, func(t *testing.T) {
+			var pe *protocol.Error
+			if err := call(); !errors.As(err, &pe) || pe.Code != protocol.ErrBadRequest {
+				t.Errorf("error %v, want %s", err, protocol.ErrBadRequest)
+			}
+		})
+	}
+}
@@ -2,17 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"gemini-zeromq-labs/lab03/internal/auth"
+	"gemini-zeromq-labs/lab03/internal/client"
 	"gemini-zeromq-labs/lab03/internal/config"
+	"gemini-zeromq-labs/lab03/internal/directory"
 	"gemini-zeromq-labs/lab03/internal/protocol"
 
 	"github.com/go-zeromq/zmq4"
-	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/host"
 	"github.com/shirou/gopsutil/v3/mem"
 )
@@ -33,105 +37,212 @@ func main() {
 		cancel()
 	}()
 
-	// Create REP socket
-	socket := zmq4.NewRep(ctx)
+	// Without a keys file every request is served, as before
+	var authz *authorizer
+	if cfg.KeysFile != "" {
+		var err error
+		if authz, err = newAuthorizer(cfg); err != nil {
+			logger.Error("Failed to load authentication", "error", err)
+			os.Exit(1)
+		}
+		defer authz.Close()
+		logger.Info("Authentication enabled", "keys", cfg.KeysFile, "policy", cfg.PolicyFile, "audit_log", cfg.AuditLog, "max_skew", cfg.MaxSkew.String())
+	} else {
+		logger.Warn("Authentication disabled; anyone who can connect may run any command")
+	}
+
+	// CPU samples are taken in the background, requests read the latest one
+	smp := newSampler(cfg.SampleInterval)
+	go smp.run(ctx, logger)
+
+	// Create ROUTER socket; REQ clients keep working, and replies may go out
+	// in any order, so one slow request no longer holds up the others
+	socket := zmq4.NewRouter(ctx)
 	defer socket.Close()
 
 	bindAddr := cfg.BindAddr()
-	logger.Info("Node Agent listening", "endpoint", bindAddr)
+	logger.Info("Node Agent listening", "endpoint", bindAddr, "workers", cfg.Workers, "queue", cfg.Queue)
 	if err := socket.Listen(bindAddr); err != nil {
 		logger.Error("Failed to listen", "error", err)
 		os.Exit(1)
 	}
 
-	for {
-		// Receive Request
-		msg, err := socket.Recv()
+	// Watch streams publish their samples on a PUB socket; only this goroutine sends on it
+	pub := zmq4.NewPub(ctx)
+	defer pub.Close()
+	if err := pub.Listen(cfg.StreamBindAddr()); err != nil {
+		logger.Error("Failed to listen", "endpoint", cfg.StreamBindAddr(), "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Publishing watch streams", "endpoint", cfg.StreamBindAddr(), "max_streams", cfg.MaxStreams)
+	samples := make(chan zmq4.Msg, cfg.Queue)
+	go func() {
+		for m := range samples {
+			if err := pub.Send(m); err != nil && ctx.Err() == nil {
+				logger.Error("Error publishing sample", "error", err)
+			}
+		}
+	}()
+	watches := newWatchHub(ctx, cfg.StreamPort, cfg.MaxStreams, samples, logger)
+
+	// Announce the agent in the directory, if there is one; on shutdown
+	// wait until it has been taken out again
+	registered := make(chan struct{})
+	if cfg.Directory == "" {
+		close(registered)
+	} else {
+		dir, err := client.ParseTarget(cfg.Directory, cfg.DirectoryPort)
 		if err != nil {
-			if ctx.Err() != nil {
-				break
+			logger.Error("Invalid directory", "error", err)
+			os.Exit(1)
+		}
+		labels, err := directory.ParseLabels(cfg.Labels)
+		if err != nil {
+			logger.Error("Invalid labels", "error", err)
+			os.Exit(1)
+		}
+		var signer *auth.Signer
+		if cfg.KeysFile != "" && cfg.ClientID != "" {
+			if signer, err = auth.NewSigner(cfg.KeysFile, cfg.ClientID); err != nil {
+				logger.Error("Failed to load registration key", "error", err)
+				os.Exit(1)
 			}
-			logger.Error("Error receiving message", "error", err)
-			continue
 		}
+		go func() {
+			register(ctx, cfg, dir, labels, signer, logger)
+			close(registered)
+		}()
+	}
 
-		logger.Info("Received request", "bytes", len(msg.Bytes()))
+	replies := make(chan reply, cfg.Queue)
+	handle := func(req *protocol.Request) protocol.Response {
+		return processCommand(ctx, req, smp, watches, logger)
+	}
+	watches.collect = func(ctx context.Context, req *protocol.Request) protocol.Response {
+		return processCommand(ctx, req, smp, nil, logger)
+	}
+	fast := newLane("fast", cfg.Workers, cfg.Queue, handle, replies, logger)
+	slow := newLane("slow", cfg.Workers, cfg.Queue, handle, replies, logger)
 
-		// Parse Request
-		req, err := protocol.FromBytes(msg.Bytes())
-		if err != nil {
-			sendError(socket, "Invalid JSON format")
-			continue
+	// Receive in the background, the main loop also sends the replies
+	msgChan := make(chan zmq4.Msg, cfg.Queue)
+	go func() {
+		for {
+			msg, err := socket.Recv()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			msgChan <- msg
 		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			<-registered
+			return
 
-		// Process Command
-		response := processCommand(req, logger)
+		case r := <-replies:
+			sendResponse(socket, r.envelope, r.resp, logger)
 
-		// Send Response
-		respBytes, _ := response.ToBytes()
-		if err := socket.Send(zmq4.NewMsg(respBytes)); err != nil {
-			logger.Error("Error sending response", "error", err)
+		case msg := <-msgChan:
+			// [identity, (empty delimiter from REQ,) request]
+			if len(msg.Frames) < 2 {
+				continue
+			}
+			envelope, body := msg.Frames[:len(msg.Frames)-1], msg.Frames[len(msg.Frames)-1]
+			logger.Info("Received request", "bytes", len(body))
+
+			// Parse Request
+			req, err := protocol.FromBytes(body)
+			if err != nil {
+				sendResponse(socket, envelope, protocol.Fail(nil, protocol.ErrBadRequest, "Invalid JSON format"), logger)
+				continue
+			}
+
+			if authz != nil {
+				if denied := authz.check(body, req); denied != nil {
+					sendResponse(socket, envelope, *denied, logger)
+					continue
+				}
+			}
+
+			// Queue Command
+			l := fast
+			if slowCommands[req.Command] {
+				l = slow
+			}
+			if !l.submit(job{envelope: envelope, req: req, received: time.Now()}) {
+				logger.Warn("Lane full, rejecting request", "lane", l.name, "command", req.Command, "id", req.ID)
+				sendResponse(socket, envelope, protocol.Fail(req, protocol.ErrBusy, l.name+" lane is full, retry later"), logger)
+			}
 		}
 	}
 }
 
-func processCommand(req *protocol.Request, logger *slog.Logger) protocol.Response {
+// processCommand runs one command. watches is nil for the samples of a stream.
+func processCommand(ctx context.Context, req *protocol.Request, smp *sampler, watches *watchHub, logger *slog.Logger) protocol.Response {
 	var data interface{}
 	var err error
 
-	logger.Info("Processing command", "command", req.Command)
+	logger.Info("Processing command", "command", req.Command, "id", req.ID, "version", req.Version)
 
 	switch req.Command {
 	case protocol.CMD_CPU:
-		data, err = getCPUInfo()
+		data, err = smp.cpu(ctx)
 	case protocol.CMD_MEM:
 		data, err = getMemInfo()
 	case protocol.CMD_HOST:
 		data, err = getHostInfo()
+	case protocol.CMD_DISK:
+		data, err = getDiskInfo(req.Disk)
+	case protocol.CMD_NET:
+		data, err = getNetInfo(req.Net)
+	case protocol.CMD_PROCS:
+		data, err = getProcsInfo(req.Procs)
+	case protocol.CMD_WATCH:
+		if watches == nil {
+			return protocol.Fail(req, protocol.ErrBadRequest, "streams cannot watch streams")
+		}
+		data, err = watches.handle(req)
 	default:
-		return protocol.Response{Status: "ERROR", Error: "Unknown command"}
+		if req.Version == 0 {
+			// Version 1 clients know this exact message
+			return protocol.Fail(req, protocol.ErrUnknownCommand, "Unknown command")
+		}
+		return protocol.Fail(req, protocol.ErrUnknownCommand, fmt.Sprintf("unknown command %q", req.Command))
 	}
 
 	if err != nil {
+		var pe *protocol.Error
+		if errors.As(err, &pe) {
+			return protocol.Fail(req, pe.Code, pe.Message)
+		}
 		logger.Error("Error collecting metrics", "command", req.Command, "error", err)
-		return protocol.Response{Status: "ERROR", Error: err.Error()}
+		return protocol.Fail(req, protocol.ErrCollectionFailed, err.Error())
 	}
 
-	return protocol.Response{Status: "OK", Data: data}
+	response, err := protocol.OK(req, data)
+	if err != nil {
+		return protocol.Fail(req, protocol.ErrCollectionFailed, err.Error())
+	}
+	return response
 }
 
-func sendError(socket zmq4.Socket, msg string) {
-	resp := protocol.Response{Status: "ERROR", Error: msg}
+func sendResponse(socket zmq4.Socket, envelope [][]byte, resp protocol.Response, logger *slog.Logger) {
 	bytes, _ := resp.ToBytes()
-	socket.Send(zmq4.NewMsg(bytes))
+	frames := append(append([][]byte(nil), envelope...), bytes)
+	if err := socket.Send(zmq4.NewMsgFrom(frames...)); err != nil {
+		logger.Error("Error sending response", "error", err)
+	}
 }
 
 // System Metric Helpers
 
-func getCPUInfo() (protocol.CPUData, error) {
-	info, err := cpu.Info()
-	if err != nil {
-		return protocol.CPUData{}, err
-	}
-	percent, err := cpu.Percent(time.Second, false) // 1 second sample
-	if err != nil {
-		return protocol.CPUData{}, err
-	}
-
-	model := "Unknown"
-	if len(info) > 0 {
-		model = info[0].ModelName
-	}
-	
-	cores, _ := cpu.Counts(true)
-
-	return protocol.CPUData{
-		Model:        model,
-		Cores:        cores,
-		UsagePercent: percent,
-	}, nil
-}
-
 func getMemInfo() (protocol.MemData, error) {
 	v, err := mem.VirtualMemory()
 	if err != nil {
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+// job is one request waiting for a worker, with the ROUTER envelope to reply to
+type job struct {
+	envelope [][]byte
+	req      *protocol.Request
+	received time.Time
+}
+
+// reply is a finished job
+type reply struct {
+	envelope [][]byte
+	resp     protocol.Response
+}
+
+// lane is a bounded queue with its own workers. Slow commands get their own
+// lane, so a burst of them cannot hold up the fast ones.
+type lane struct {
+	name string
+	jobs chan job
+}
+
+// slowCommands walk the process table or the socket table
+var slowCommands = map[protocol.CommandType]bool{
+	protocol.CMD_DISK:  true,
+	protocol.CMD_NET:   true,
+	protocol.CMD_PROCS: true,
+}
+
+func newLane(name string, workers, queue int, handle func(*protocol.Request) protocol.Response, replies chan<- reply, logger *slog.Logger) *lane {
+	l := &lane{name: name, jobs: make(chan job, queue)}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range l.jobs {
+				resp := handle(j.req)
+				logger.Info("Request done", "lane", name, "command", j.req.Command, "id", j.req.ID,
+					"status", resp.Status, "elapsed_ms", time.Since(j.received).Milliseconds())
+				replies <- reply{envelope: j.envelope, resp: resp}
+			}
+		}()
+	}
+	return l
+}
+
+// submit queues j, or reports false if the lane is full.
+func (l *lane) submit(j job) bool {
+	select {
+	case l.jobs <- j:
+		return true
+	default:
+		return false
+	}
+}
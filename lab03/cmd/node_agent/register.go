@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/auth"
+	"gemini-zeromq-labs/lab03/internal/client"
+	"gemini-zeromq-labs/lab03/internal/config"
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+// register keeps the agent in the directory until ctx is done, then takes it out.
+// Every heartbeat sends the full registration with a TTL of three heartbeats,
+// so a missed beat is harmless and a restarted directory relearns the agent.
+// With a signer every registration is signed, which a directory with keys requires.
+func register(ctx context.Context, cfg *config.Config, dir client.Target, labels map[string]string, signer *auth.Signer, logger *slog.Logger) {
+	info := protocol.AgentInfo{
+		Name:           cfg.Name,
+		Endpoint:       cfg.AdvertiseAddr(),
+		StreamEndpoint: cfg.AdvertiseStreamAddr(),
+		Labels:         labels,
+	}
+	ttl := 3 * cfg.Heartbeat
+
+	send := func(ctx context.Context, leave bool) error {
+		if h, err := getHostInfo(); err == nil {
+			info.Host = h // uptime changes
+		}
+		req := protocol.NewRequest(protocol.CMD_REGISTER)
+		req.Register = &protocol.RegisterArgs{Agent: info, TTLMS: ttl.Milliseconds(), Leave: leave}
+		return client.Query(ctx, dir, req, cfg.Timeout, signer).Err
+	}
+
+	ticker := time.NewTicker(cfg.Heartbeat)
+	defer ticker.Stop()
+
+	registered := false
+	for {
+		err := send(ctx, false)
+		switch {
+		case ctx.Err() != nil:
+		case err != nil:
+			logger.Warn("Directory registration failed", "directory", dir.Endpoint, "error", err)
+			registered = false
+		case !registered:
+			logger.Info("Registered in directory", "directory", dir.Endpoint, "name", info.Name,
+				"endpoint", info.Endpoint, "labels", cfg.Labels, "ttl", ttl.String())
+			registered = true
+		}
+
+		select {
+		case <-ctx.Done():
+			if registered {
+				if err := send(context.Background(), true); err != nil {
+					logger.Warn("Directory deregistration failed", "error", err)
+				}
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/protocol"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// sampler measures the CPU usage continuously in the background, so a CPU
+// request returns the latest sample at once instead of measuring for a second.
+type sampler struct {
+	interval time.Duration
+	model    string
+	cores    int
+
+	ready chan struct{} // closed after the first sample
+	once  sync.Once
+
+	mu    sync.RWMutex
+	usage []float64
+	at    time.Time
+	err   error
+}
+
+func newSampler(interval time.Duration) *sampler {
+	s := &sampler{interval: interval, model: "Unknown", ready: make(chan struct{})}
+	if info, err := cpu.Info(); err == nil && len(info) > 0 {
+		s.model = info[0].ModelName
+	}
+	s.cores, _ = cpu.Counts(true)
+	return s
+}
+
+// run samples until ctx is cancelled; each sample covers one interval.
+func (s *sampler) run(ctx context.Context, logger *slog.Logger) {
+	for ctx.Err() == nil {
+		usage, err := cpu.PercentWithContext(ctx, s.interval, false)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Error("CPU sample failed", "error", err)
+			time.Sleep(s.interval) // Percent returns at once on error
+		}
+
+		s.mu.Lock()
+		if err == nil {
+			s.usage, s.at = usage, time.Now()
+		}
+		s.err = err
+		s.mu.Unlock()
+		s.once.Do(func() { close(s.ready) })
+	}
+}
+
+// cpu returns the latest sample, waiting for the first one if necessary.
+func (s *sampler) cpu(ctx context.Context) (protocol.CPUData, error) {
+	select {
+	case <-s.ready:
+	case <-ctx.Done():
+		return protocol.CPUData{}, ctx.Err()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.usage == nil {
+		if s.err != nil {
+			return protocol.CPUData{}, s.err
+		}
+		return protocol.CPUData{}, errors.New("no CPU sample")
+	}
+	return protocol.CPUData{
+		Model:        s.model,
+		Cores:        s.cores,
+		UsagePercent: append([]float64(nil), s.usage...),
+		SampledAt:    s.at,
+	}, nil
+}
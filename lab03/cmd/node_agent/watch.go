@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/protocol"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+// Limits of watch streams
+const (
+	defaultWatchInterval = 2 * time.Second
+	minWatchInterval     = 100 * time.Millisecond
+	defaultWatchLease    = 10 * time.Second
+	minWatchLease        = time.Second
+	maxWatchLease        = time.Minute
+	firstSampleDelay     = 500 * time.Millisecond
+)
+
+// watchHub runs the sample streams opened by CMD_WATCH. Every stream has a
+// lease; when its watcher stops renewing, the stream ends by itself.
+type watchHub struct {
+	ctx     context.Context
+	port    int
+	limit   int // open streams
+	collect func(context.Context, *protocol.Request) protocol.Response
+	out     chan<- zmq4.Msg // to the PUB socket
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	streams map[string]*stream
+}
+
+type stream struct {
+	id       string
+	owner    string // authenticated client, "" without authentication
+	commands []protocol.CommandType
+	interval time.Duration
+	lease    time.Duration
+	expires  time.Time // guarded by watchHub.mu
+	cancel   context.CancelFunc
+}
+
+func newWatchHub(ctx context.Context, port, limit int, out chan<- zmq4.Msg, logger *slog.Logger) *watchHub {
+	return &watchHub{
+		ctx:     ctx,
+		port:    port,
+		limit:   limit,
+		out:     out,
+		logger:  logger,
+		streams: make(map[string]*stream),
+	}
+}
+
+// handle serves CMD_WATCH. Errors are *protocol.Error.
+func (h *watchHub) handle(req *protocol.Request) (protocol.WatchData, error) {
+	args := req.Watch
+	if args == nil {
+		args = &protocol.WatchArgs{}
+	}
+	owner := ""
+	if req.Auth != nil {
+		owner = req.Auth.Client
+	}
+	if args.Stream == "" {
+		return h.open(args, owner)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.streams[args.Stream]
+	if !ok || st.owner != owner {
+		return protocol.WatchData{}, &protocol.Error{Code: protocol.ErrNoStream, Message: fmt.Sprintf("no stream %s", args.Stream)}
+	}
+	if args.Stop {
+		delete(h.streams, st.id)
+		st.cancel()
+		h.logger.Info("Watch stream stopped", "stream", st.id)
+	} else {
+		st.expires = time.Now().Add(st.lease)
+	}
+	return h.data(st), nil
+}
+
+func (h *watchHub) open(args *protocol.WatchArgs, owner string) (protocol.WatchData, error) {
+	if len(args.Commands) == 0 {
+		return protocol.WatchData{}, &protocol.Error{Code: protocol.ErrBadRequest, Message: "no commands to watch"}
+	}
+	for _, c := range args.Commands {
+		if !protocol.Watchable(c) {
+			return protocol.WatchData{}, &protocol.Error{Code: protocol.ErrUnknownCommand, Message: fmt.Sprintf("cannot watch %q", c)}
+		}
+	}
+
+	st := &stream{
+		id:       protocol.NewID(),
+		owner:    owner,
+		commands: args.Commands,
+		interval: clamp(time.Duration(args.IntervalMS)*time.Millisecond, defaultWatchInterval, minWatchInterval, 0),
+		lease:    clamp(time.Duration(args.LeaseMS)*time.Millisecond, defaultWatchLease, minWatchLease, maxWatchLease),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.streams) >= h.limit {
+		return protocol.WatchData{}, &protocol.Error{Code: protocol.ErrBusy, Message: fmt.Sprintf("%d streams open", len(h.streams))}
+	}
+	var ctx context.Context
+	ctx, st.cancel = context.WithCancel(h.ctx)
+	st.expires = time.Now().Add(st.lease)
+	h.streams[st.id] = st
+	go h.run(ctx, st)
+
+	h.logger.Info("Watch stream opened", "stream", st.id, "client", owner, "commands", st.commands,
+		"interval", st.interval.String(), "lease", st.lease.String())
+	return h.data(st), nil
+}
+
+// clamp applies a default to zero and bounds to d; hi 0 means unbounded.
+func clamp(d, def, lo, hi time.Duration) time.Duration {
+	if d == 0 {
+		d = def
+	}
+	if d < lo {
+		d = lo
+	}
+	if hi > 0 && d > hi {
+		d = hi
+	}
+	return d
+}
+
+func (h *watchHub) data(st *stream) protocol.WatchData {
+	return protocol.WatchData{
+		Stream:     st.id,
+		Port:       h.port,
+		Commands:   st.commands,
+		IntervalMS: st.interval.Milliseconds(),
+		LeaseMS:    st.lease.Milliseconds(),
+		Expires:    st.expires,
+	}
+}
+
+// run publishes a sample of every command every interval, until the stream
+// is stopped or its lease runs out. The first sample comes sooner, but not at
+// once: the watcher only subscribes after it has the reply.
+func (h *watchHub) run(ctx context.Context, st *stream) {
+	first := time.NewTimer(min(st.interval, firstSampleDelay))
+	defer first.Stop()
+	ticker := time.NewTicker(st.interval)
+	defer ticker.Stop()
+	lease := time.NewTimer(st.lease)
+	defer lease.Stop()
+
+	var seq uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-first.C:
+			h.publish(ctx, st, seq)
+			seq++
+		case <-ticker.C:
+			h.publish(ctx, st, seq)
+			seq++
+		case <-lease.C:
+			h.mu.Lock()
+			left := time.Until(st.expires)
+			if left <= 0 {
+				delete(h.streams, st.id)
+			}
+			h.mu.Unlock()
+			if left <= 0 {
+				h.logger.Info("Watch stream expired, no heartbeat within lease", "stream", st.id, "lease", st.lease.String())
+				st.cancel()
+				return
+			}
+			lease.Reset(left)
+		}
+	}
+}
+
+func (h *watchHub) publish(ctx context.Context, st *stream, seq uint64) {
+	for _, c := range st.commands {
+		req := &protocol.Request{Version: protocol.Version, ID: st.id, Command: c}
+		sample := protocol.Sample{
+			Stream:   st.id,
+			Seq:      seq,
+			Command:  c,
+			Response: h.collect(ctx, req),
+			Time:     time.Now(),
+		}
+		body, err := json.Marshal(sample)
+		if err != nil {
+			h.logger.Error("Failed to encode sample", "stream", st.id, "error", err)
+			continue
+		}
+		select {
+		case h.out <- zmq4.NewMsgFrom([]byte(st.id), body):
+		default:
+			h.logger.Warn("Publisher backlog full, dropping sample", "stream", st.id, "command", c)
+		}
+	}
+}
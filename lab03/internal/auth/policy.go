@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+// minKeyBytes is the shortest accepted HMAC key
+const minKeyBytes = 16
+
+// LoadKeys reads a keys file mapping client IDs to hex encoded secrets:
+//
+//	{"ops": "3f9a...", "monitor": "77c0..."}
+//
+// Generate a key with e.g. `openssl rand -hex 32`.
+func LoadKeys(path string) (map[string][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	keys := make(map[string][]byte, len(raw))
+	for client, s := range raw {
+		key, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key of %q: %w", path, client, err)
+		}
+		if len(key) < minKeyBytes {
+			return nil, fmt.Errorf("%s: key of %q is shorter than %d bytes", path, client, minKeyBytes)
+		}
+		keys[client] = key
+	}
+	return keys, nil
+}
+
+// PolicyConfig is the layout of the policy file. "*" allows every command.
+//
+//	{"roles": {"admin": ["*"], "viewer": ["CPU", "MEM", "HOST"]},
+//	 "clients": {"ops": ["admin"], "monitor": ["viewer"]}}
+type PolicyConfig struct {
+	Roles   map[string][]string `json:"roles"`   // role -> commands
+	Clients map[string][]string `json:"clients"` // client -> roles
+}
+
+// Policy decides which commands a client may run.
+type Policy struct {
+	roles   map[string]map[protocol.CommandType]bool
+	clients map[string][]string
+}
+
+// LoadPolicy reads and checks a policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg PolicyConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return CompilePolicy(cfg)
+}
+
+// CompilePolicy checks that every client role is defined and builds a Policy.
+func CompilePolicy(cfg PolicyConfig) (*Policy, error) {
+	p := &Policy{
+		roles:   make(map[string]map[protocol.CommandType]bool),
+		clients: make(map[string][]string),
+	}
+	for role, cmds := range cfg.Roles {
+		set := make(map[protocol.CommandType]bool)
+		for _, c := range cmds {
+			set[protocol.CommandType(strings.ToUpper(c))] = true
+		}
+		p.roles[role] = set
+	}
+	for client, roles := range cfg.Clients {
+		for _, r := range roles {
+			if _, ok := p.roles[r]; !ok {
+				return nil, fmt.Errorf("client %q: undefined role %q", client, r)
+			}
+		}
+		p.clients[client] = append([]string(nil), roles...)
+		sort.Strings(p.clients[client])
+	}
+	return p, nil
+}
+
+// Allowed reports whether client may run cmd, and the role that allows it.
+func (p *Policy) Allowed(client string, cmd protocol.CommandType) (string, bool) {
+	for _, r := range p.clients[client] {
+		if p.roles[r]["*"] || p.roles[r][cmd] {
+			return r, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+const maxSkew = 30 * time.Second
+
+var testKeys = map[string][]byte{
+	"ops":     bytes.Repeat([]byte{1}, 32),
+	"monitor": bytes.Repeat([]byte{2}, 32),
+}
+
+func newTestVerifier(t *testing.T) *Verifier {
+	t.Helper()
+	policy, err := CompilePolicy(PolicyConfig{
+		Roles:   map[string][]string{"admin": {"*"}, "viewer": {"cpu", "MEM"}},
+		Clients: map[string][]string{"ops": {"admin"}, "monitor": {"viewer"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewVerifier(testKeys, policy, maxSkew)
+}
+
+// signed returns req signed by client at ts, and its wire form.
+func signed(t *testing.T, client string, req protocol.Request, ts time.Time) (*protocol.Request, []byte) {
+	t.Helper()
+	s := &Signer{client: client, key: testKeys[client]}
+	if err := s.Sign(&req, ts); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &req, raw
+}
+
+func TestVerifierCheck(t *testing.T) {
+	now := time.Now()
+	cpu := protocol.Request{ID: "r1", Command: protocol.CMD_CPU}
+	procs := protocol.Request{ID: "r2", Command: protocol.CMD_PROCS}
+
+	cases := []struct {
+		name   string
+		build  func() (*protocol.Request, []byte)
+		allow  bool
+		authed bool
+		reason string
+	}{
+		{"allowed", func() (*protocol.Request, []byte) { return signed(t, "ops", procs, now) }, true, true, ""},
+		{"viewer allowed", func() (*protocol.Request, []byte) { return signed(t, "monitor", cpu, now) }, true, true, ""},
+		{"unsigned", func() (*protocol.Request, []byte) {
+			raw, _ := json.Marshal(cpu)
+			return &cpu, raw
+		}, false, false, "unsigned request"},
+		{"unknown client", func() (*protocol.Request, []byte) {
+			req, raw := signed(t, "ops", cpu, now)
+			req.Auth.Client = "mallory"
+			return req, raw
+		}, false, false, "unknown client"},
+		{"tampered", func() (*protocol.Request, []byte) {
+			req, raw := signed(t, "monitor", cpu, now)
+			raw = bytes.Replace(raw, []byte(`"CPU"`), []byte(`"PROCS"`), 1)
+			req.Command = protocol.CMD_PROCS
+			return req, raw
+		}, false, false, "bad signature"},
+		{"unknown field covered", func() (*protocol.Request, []byte) {
+			req, raw := signed(t, "ops", cpu, now)
+			raw = bytes.Replace(raw, []byte(`{`), []byte(`{"future":1,`), 1)
+			return req, raw
+		}, false, false, "bad signature"},
+		{"policy denial", func() (*protocol.Request, []byte) { return signed(t, "monitor", procs, now) }, false, true, "command PROCS not permitted"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, raw := tc.build()
+			d := newTestVerifier(t).Check(raw, req, now)
+			if d.Allowed != tc.allow || d.Authenticated != tc.authed || d.Reason != tc.reason {
+				t.Errorf("Check = %+v, want allowed %v authenticated %v reason %q", d, tc.allow, tc.authed, tc.reason)
+			}
+		})
+	}
+}
+
+func TestVerifierWatchCommands(t *testing.T) {
+	policy, err := CompilePolicy(PolicyConfig{
+		Roles:   map[string][]string{"watcher": {"WATCH", "CPU"}},
+		Clients: map[string][]string{"monitor": {"watcher"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(testKeys, policy, maxSkew)
+	now := time.Now()
+
+	// A stream may only sample commands the client could run itself
+	ok, raw := signed(t, "monitor", protocol.Request{Command: protocol.CMD_WATCH, Watch: &protocol.WatchArgs{Commands: []protocol.CommandType{protocol.CMD_CPU}}}, now)
+	if d := v.Check(raw, ok, now); !d.Allowed {
+		t.Errorf("watch of a permitted command denied: %s", d.Reason)
+	}
+	bad, raw := signed(t, "monitor", protocol.Request{Command: protocol.CMD_WATCH, Watch: &protocol.WatchArgs{Commands: []protocol.CommandType{protocol.CMD_CPU, protocol.CMD_PROCS}}}, now)
+	if d := v.Check(raw, bad, now); d.Allowed || d.Reason != "command PROCS not permitted" {
+		t.Errorf("watch of a forbidden command = %+v", d)
+	}
+}
+
+func TestVerifierSkew(t *testing.T) {
+	now := time.Now()
+	cases := map[string]struct {
+		offset time.Duration
+		ok     bool
+	}{
+		"on time":         {0, true},
+		"slightly behind": {-maxSkew + time.Second, true},
+		"slightly ahead":  {maxSkew - time.Second, true},
+		"too old":         {-maxSkew - time.Second, false},
+		"from the future": {maxSkew + time.Second, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req, raw := signed(t, "ops", protocol.Request{Command: protocol.CMD_CPU}, now.Add(tc.offset))
+			d := newTestVerifier(t).Check(raw, req, now)
+			if d.Allowed != tc.ok {
+				t.Errorf("Check = %+v, want allowed %v", d, tc.ok)
+			}
+		})
+	}
+}
+
+func TestVerifierRejectsReplay(t *testing.T) {
+	v := newTestVerifier(t)
+	now := time.Now()
+	req, raw := signed(t, "ops", protocol.Request{Command: protocol.CMD_CPU}, now)
+
+	if d := v.Check(raw, req, now); !d.Allowed {
+		t.Fatalf("first use denied: %s", d.Reason)
+	}
+	if d := v.Check(raw, req, now.Add(time.Second)); d.Allowed || d.Reason != "replayed nonce" {
+		t.Errorf("replay = %+v, want replayed nonce", d)
+	}
+	// Once the timestamp is outside the skew window the nonce may be
+	// forgotten; the skew check rejects the replay instead
+	if d := v.Check(raw, req, now.Add(2*maxSkew)); d.Allowed {
+		t.Errorf("late replay allowed")
+	}
+
+	// A forged request with the same nonce does not burn it for the real one
+	v = newTestVerifier(t)
+	forged := *req
+	forgedAuth := *req.Auth
+	forgedAuth.Signature = "00"
+	forged.Auth = &forgedAuth
+	if d := v.Check(raw, &forged, now); d.Allowed {
+		t.Fatal("forged request allowed")
+	}
+	if d := v.Check(raw, req, now); !d.Allowed {
+		t.Errorf("real request denied after a forgery: %s", d.Reason)
+	}
+}
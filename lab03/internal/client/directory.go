@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/auth"
+	"gemini-zeromq-labs/lab03/internal/directory"
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+// Agents asks the directory for the live agents whose labels match selector.
+func Agents(ctx context.Context, dir Target, selector string, timeout time.Duration, signer *auth.Signer) ([]protocol.AgentInfo, error) {
+	if _, err := directory.ParseSelector(selector); err != nil {
+		return nil, err
+	}
+	req := protocol.NewRequest(protocol.CMD_AGENTS)
+	req.Agents = &protocol.AgentsArgs{Selector: selector}
+	res := Query(ctx, dir, req, timeout, signer)
+	if res.Err != nil {
+		return nil, fmt.Errorf("directory %s: %w", dir.Endpoint, res.Err)
+	}
+	data, ok := res.Payload.(*protocol.AgentsData)
+	if !ok {
+		return nil, fmt.Errorf("directory %s: unexpected payload %T", dir.Endpoint, res.Payload)
+	}
+	return data.Agents, nil
+}
+
+// AgentTargets turns directory entries into targets named after the agents.
+func AgentTargets(agents []protocol.AgentInfo) []Target {
+	out := make([]Target, len(agents))
+	for i, a := range agents {
+		out[i] = Target{Name: a.Name, Endpoint: a.Endpoint}
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/auth"
+	"gemini-zeromq-labs/lab03/internal/protocol"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+// ErrorKind classifies why a target produced no data
+type ErrorKind string
+
+const (
+	Unreachable ErrorKind = "UNREACHABLE" // could not connect
+	Timeout     ErrorKind = "TIMEOUT"     // connected, but no reply in time
+	Invalid     ErrorKind = "INVALID"     // reply could not be parsed
+	Remote      ErrorKind = "REMOTE"      // the agent answered with an error (*protocol.Error)
+	Waiting     ErrorKind = "WAITING"     // watch: no sample yet
+)
+
+// TargetError is the error of a single target
+type TargetError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+}
+
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
+
+// Result is the outcome of querying one target
+type Result struct {
+	Target   Target
+	Response *protocol.Response // nil unless the agent answered
+	Payload  interface{}        // typed payload (see protocol.NewPayload), nil on error
+	Err      error              // *TargetError
+	Elapsed  time.Duration
+}
+
+// Query sends req to one agent over a fresh REQ socket and waits at most timeout.
+// A fresh socket per query keeps a lost reply from wedging the REQ state machine.
+// Versioned requests get a fresh ID per query, and the reply must echo it.
+// A non-nil signer signs each query with its own nonce.
+func Query(ctx context.Context, t Target, req protocol.Request, timeout time.Duration, signer *auth.Signer) Result {
+	start := time.Now()
+	res := Result{Target: t}
+	if req.Version > 0 {
+		req.ID = protocol.NewID()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	socket := zmq4.NewReq(ctx)
+	defer socket.Close()
+
+	fail := func(kind ErrorKind, err error) Result {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			if kind == Unreachable {
+				err = fmt.Errorf("no connection within %s", timeout)
+			} else {
+				kind, err = Timeout, fmt.Errorf("no reply within %s", timeout)
+			}
+		}
+		res.Err = &TargetError{Kind: kind, Err: err}
+		res.Elapsed = time.Since(start)
+		return res
+	}
+
+	if err := socket.Dial(t.Endpoint); err != nil {
+		return fail(Unreachable, err)
+	}
+
+	if signer != nil {
+		if err := signer.Sign(&req, time.Now()); err != nil {
+			return fail(Invalid, err)
+		}
+	}
+	reqBytes, err := req.ToBytes()
+	if err != nil {
+		return fail(Invalid, err)
+	}
+	if err := socket.Send(zmq4.NewMsg(reqBytes)); err != nil {
+		return fail(Unreachable, err)
+	}
+
+	msg, err := socket.Recv()
+	if err != nil {
+		return fail(Timeout, err)
+	}
+
+	resp, err := protocol.ResponseFromBytes(msg.Bytes())
+	if err != nil {
+		return fail(Invalid, err)
+	}
+	// Version 1 agents do not echo the ID
+	if resp.ID != "" && resp.ID != req.ID {
+		return fail(Invalid, fmt.Errorf("reply to request %s, expected %s", resp.ID, req.ID))
+	}
+	res.Response = resp
+	if err := resp.Err(); err != nil {
+		res.Err = &TargetError{Kind: Remote, Err: err}
+		res.Elapsed = time.Since(start)
+		return res
+	}
+	if res.Payload, err = resp.Decode(req.Command); err != nil {
+		return fail(Invalid, err)
+	}
+	res.Elapsed = time.Since(start)
+	return res
+}
+
+// FanOut queries every target concurrently, at most parallel at a time.
+// Results are in target order.
+func FanOut(ctx context.Context, targets []Target, req protocol.Request, timeout time.Duration, parallel int, signer *auth.Signer) []Result {
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]Result, len(targets))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t Target) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = Query(ctx, t, req, timeout, signer)
+		}(i, t)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Target is one node agent to query.
+type Target struct {
+	Name     string // as given by the user, used as the column header
+	Endpoint string // tcp://host:port
+}
+
+// ParseTarget accepts "host", "host:port" or "tcp://host:port".
+func ParseTarget(s string, defaultPort int) (Target, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Target{}, fmt.Errorf("empty target")
+	}
+	addr := strings.TrimPrefix(s, "tcp://")
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port given
+		host, port = strings.Trim(addr, "[]"), strconv.Itoa(defaultPort)
+	}
+	if host == "" {
+		return Target{}, fmt.Errorf("target %q: missing host", s)
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return Target{}, fmt.Errorf("target %q: invalid port %q", s, port)
+	}
+	return Target{Name: s, Endpoint: "tcp://" + net.JoinHostPort(host, port)}, nil
+}
+
+// LoadTargets collects targets from a comma separated list and from every file
+// matching the comma separated glob patterns in files (one target per line,
+// '#' starts a comment). Duplicate endpoints are dropped, order is kept.
+func LoadTargets(list, files string, defaultPort int) ([]Target, error) {
+	var specs []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			specs = append(specs, s)
+		}
+	}
+
+	for _, pattern := range strings.Split(files, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("targets file pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("targets file pattern %q matches no files", pattern)
+		}
+		for _, m := range matches {
+			lines, err := readLines(m)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, lines...)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var out []Target
+	for _, s := range specs {
+		t, err := ParseTarget(s, defaultPort)
+		if err != nil {
+			return nil, err
+		}
+		if seen[t.Endpoint] {
+			continue
+		}
+		seen[t.Endpoint] = true
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// Merge concatenates target lists, dropping repeated endpoints.
+func Merge(lists ...[]Target) []Target {
+	seen := make(map[string]bool)
+	var out []Target
+	for _, list := range lists {
+		for _, t := range list {
+			if !seen[t.Endpoint] {
+				seen[t.Endpoint] = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// Select keeps the targets whose name matches the glob pattern (path.Match syntax).
+// An empty pattern keeps everything.
+func Select(targets []Target, pattern string) ([]Target, error) {
+	if pattern == "" {
+		return targets, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("select pattern %q: %w", pattern, err)
+	}
+	var out []Target
+	for _, t := range targets {
+		if ok, _ := path.Match(pattern, t.Name); ok {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func readLines(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out, sc.Err()
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/auth"
+	"gemini-zeromq-labs/lab03/internal/protocol"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+// Event is a sample of one target, or a problem with its stream
+type Event struct {
+	Target Target
+	Sample *protocol.Sample // nil if Err is set
+	Err    error            // *TargetError
+}
+
+// defaultLease is used when WatchArgs.LeaseMS is zero; it matches the agent's default.
+const defaultLease = 10 * time.Second
+
+// Watch keeps a stream of args.Commands open on every target and sends what
+// arrives to events until ctx is done, then stops the streams. Leases are
+// renewed every third of their length. A target whose stream is lost, e.g.
+// because the agent restarted, gets a new one at the next renewal.
+func Watch(ctx context.Context, targets []Target, args protocol.WatchArgs, timeout time.Duration, signer *auth.Signer, events chan<- Event) {
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t Target) {
+			defer wg.Done()
+			w := &watcher{target: t, args: args, timeout: timeout, signer: signer, events: events}
+			w.run(ctx)
+		}(t)
+	}
+	wg.Wait()
+}
+
+type watcher struct {
+	target  Target
+	args    protocol.WatchArgs
+	timeout time.Duration
+	signer  *auth.Signer
+	events  chan<- Event
+
+	sub    zmq4.Socket // dialed on the first successful open
+	stream string      // current stream, "" if none
+}
+
+func (w *watcher) run(ctx context.Context) {
+	lease := time.Duration(w.args.LeaseMS) * time.Millisecond
+	if lease <= 0 {
+		lease = defaultLease
+	}
+	heartbeat := time.NewTicker(lease / 3)
+	defer heartbeat.Stop()
+
+	defer func() {
+		if w.sub != nil {
+			w.sub.Close()
+		}
+	}()
+
+	if granted := w.open(ctx); granted > 0 && granted != lease {
+		heartbeat.Reset(granted / 3)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			w.stop()
+			return
+		case <-heartbeat.C:
+			if w.stream == "" {
+				w.open(ctx)
+				continue
+			}
+			_, err := w.request(ctx, protocol.WatchArgs{Stream: w.stream})
+			var pe *protocol.Error
+			if errors.As(err, &pe) && pe.Code == protocol.ErrNoStream {
+				w.stream = ""
+				w.open(ctx)
+			} else if err != nil {
+				w.emit(ctx, Event{Target: w.target, Err: err})
+			}
+		}
+	}
+}
+
+// open asks for a new stream and subscribes to it. It returns the granted lease, 0 on failure.
+func (w *watcher) open(ctx context.Context) time.Duration {
+	a := w.args
+	a.Stream = ""
+	data, err := w.request(ctx, a)
+	if err != nil {
+		w.emit(ctx, Event{Target: w.target, Err: err})
+		return 0
+	}
+
+	if w.sub == nil {
+		endpoint, err := streamEndpoint(w.target, data.Port)
+		if err == nil {
+			sub := zmq4.NewSub(ctx, zmq4.WithAutomaticReconnect(true))
+			if err = sub.Dial(endpoint); err == nil {
+				w.sub = sub
+				go w.receive(ctx, sub)
+			} else {
+				sub.Close()
+			}
+		}
+		if err != nil {
+			w.stream = data.Stream
+			w.stop()
+			w.emit(ctx, Event{Target: w.target, Err: &TargetError{Kind: Unreachable, Err: fmt.Errorf("stream: %w", err)}})
+			return 0
+		}
+	}
+
+	if w.stream != "" {
+		w.sub.SetOption(zmq4.OptionUnsubscribe, w.stream)
+	}
+	w.sub.SetOption(zmq4.OptionSubscribe, data.Stream)
+	w.stream = data.Stream
+	return time.Duration(data.LeaseMS) * time.Millisecond
+}
+
+// stop ends the current stream; best effort, the lease would end it anyway.
+func (w *watcher) stop() {
+	if w.stream == "" {
+		return
+	}
+	w.request(context.Background(), protocol.WatchArgs{Stream: w.stream, Stop: true})
+	w.stream = ""
+}
+
+func (w *watcher) request(ctx context.Context, a protocol.WatchArgs) (*protocol.WatchData, error) {
+	req := protocol.NewRequest(protocol.CMD_WATCH)
+	req.Watch = &a
+	res := Query(ctx, w.target, req, w.timeout, w.signer)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	data, ok := res.Payload.(*protocol.WatchData)
+	if !ok {
+		return nil, &TargetError{Kind: Invalid, Err: fmt.Errorf("unexpected WATCH payload %T", res.Payload)}
+	}
+	return data, nil
+}
+
+func (w *watcher) receive(ctx context.Context, sub zmq4.Socket) {
+	for {
+		msg, err := sub.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		if len(msg.Frames) < 2 {
+			continue
+		}
+		var s protocol.Sample
+		if err := json.Unmarshal(msg.Frames[1], &s); err != nil {
+			w.emit(ctx, Event{Target: w.target, Err: &TargetError{Kind: Invalid, Err: err}})
+			continue
+		}
+		w.emit(ctx, Event{Target: w.target, Sample: &s})
+	}
+}
+
+func (w *watcher) emit(ctx context.Context, e Event) {
+	select {
+	case w.events <- e:
+	case <-ctx.Done():
+	}
+}
+
+// streamEndpoint is the target's host with the agent's stream port.
+func streamEndpoint(t Target, port int) (string, error) {
+	host, _, err := net.SplitHostPort(strings.TrimPrefix(t.Endpoint, "tcp://"))
+	if err != nil {
+		return "", err
+	}
+	return "tcp://" + net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
@@ -3,17 +3,77 @@ package config
 import (
 	"flag"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
 	Port int
 	Host string // For client connection
+
+	// Client fan-out
+	Targets     string        // comma separated host[:port] list
+	TargetsFile string        // comma separated glob patterns of target files
+	Select      string        // glob over target names
+	Timeout     time.Duration // per target
+	Parallel    int           // concurrent queries
+	Output      string        // table, json or csv
+
+	// Authentication
+	KeysFile   string        // per-client HMAC keys; empty disables signing/verification
+	ClientID   string        // who the client, or the agent registering, signs as
+	PolicyFile string        // role policy (Agent, Directory)
+	MaxSkew    time.Duration // accepted clock difference (Agent, Directory)
+	AuditLog   string        // file receiving every auth decision (Agent)
+
+	// Agent request handling
+	Workers        int           // per lane
+	Queue          int           // pending requests per lane
+	SampleInterval time.Duration // background CPU sample length
+
+	// Watch streams
+	StreamPort int // PUB port of the sample streams (Agent)
+	MaxStreams int // open streams per agent (Agent)
+
+	// Directory service
+	Directory     string        // host[:port] of the directory; empty = none
+	DirectoryPort int           // default port of the directory, and its bind port
+	Name          string        // agent name in the directory (Agent)
+	Labels        string        // key=value,... (Agent)
+	Advertise     string        // host others reach the agent at (Agent)
+	Heartbeat     time.Duration // registration renewal interval (Agent)
+	Selector      string        // label selector (Client)
 }
 
 func LoadConfig() *Config {
 	port := flag.Int("port", 5559, "Port to listen on/connect to")
 	host := flag.String("host", "127.0.0.1", "Host to connect to (Client only)")
+	targets := flag.String("targets", "", "Comma separated agents as host[:port] (Client only, default -host:-port)")
+	targetsFile := flag.String("targets-file", "", "Files listing one agent per line; comma separated glob patterns (Client only)")
+	sel := flag.String("select", "", "Only query targets whose name matches this glob, e.g. 'db-*' (Client only)")
+	timeout := flag.Duration("timeout", 5*time.Second, "Timeout per target (Client only)")
+	parallel := flag.Int("parallel", 16, "Maximum concurrent queries (Client only)")
+	output := flag.String("o", "table", "Output format: table, json or csv (Client only)")
+	keys := flag.String("keys", "", "Per-client HMAC keys file; enables request signing (Client, Agent registrations) and verification (Agent, Directory registrations)")
+	clientID := flag.String("client-id", "", "Client ID to sign requests (Client) or directory registrations (Agent) as, must be in -keys")
+	policy := flag.String("policy", "auth_policy.json", "Role policy file, used with -keys (Agent, Directory)")
+	maxSkew := flag.Duration("max-skew", 30*time.Second, "Maximum clock difference of signed requests (Agent, Directory)")
+	auditLog := flag.String("audit-log", "audit.log", "File receiving every authorization decision (Agent only)")
+	workers := flag.Int("workers", 4, "Workers per lane, fast and slow commands have a lane each (Agent only)")
+	queue := flag.Int("queue", 64, "Pending requests per lane before replying BUSY (Agent only)")
+	sampleInterval := flag.Duration("sample-interval", time.Second, "Length of the background CPU samples (Agent only)")
+	streamPort := flag.Int("stream-port", 5560, "PUB port publishing watch streams (Agent only)")
+	maxStreams := flag.Int("max-streams", 32, "Maximum open watch streams (Agent only)")
+	directory := flag.String("directory", "", "Directory service host[:port] to register with (Agent) or discover agents from (Client)")
+	directoryPort := flag.Int("directory-port", 5561, "Port of the directory service (Directory listens on it)")
+	hostname, _ := os.Hostname()
+	name := flag.String("name", hostname, "Name to register under (Agent only)")
+	labels := flag.String("labels", "", "Labels to register with, e.g. env=prod,role=db (Agent only)")
+	advertise := flag.String("advertise", hostname, "Host name or address clients reach this agent at (Agent only)")
+	heartbeat := flag.Duration("heartbeat", 5*time.Second, "Directory registration renewal interval; the entry lives 3 of them (Agent only)")
+	selector := flag.String("l", "", "Only agents whose directory labels match, e.g. env=prod,role=db (Client only, needs -directory)")
 	flag.Parse()
 
 	if envPort := os.Getenv("LAB03_PORT"); envPort != "" {
@@ -24,6 +84,34 @@ func LoadConfig() *Config {
 	return &Config{
 		Port: *port,
 		Host: *host,
+
+		Targets:     *targets,
+		TargetsFile: *targetsFile,
+		Select:      *sel,
+		Timeout:     *timeout,
+		Parallel:    *parallel,
+		Output:      *output,
+
+		KeysFile:   *keys,
+		ClientID:   *clientID,
+		PolicyFile: *policy,
+		MaxSkew:    *maxSkew,
+		AuditLog:   *auditLog,
+
+		Workers:        *workers,
+		Queue:          *queue,
+		SampleInterval: *sampleInterval,
+
+		StreamPort: *streamPort,
+		MaxStreams: *maxStreams,
+
+		Directory:     *directory,
+		DirectoryPort: *directoryPort,
+		Name:          *name,
+		Labels:        *labels,
+		Advertise:     *advertise,
+		Heartbeat:     *heartbeat,
+		Selector:      *selector,
 	}
 }
 
@@ -31,6 +119,24 @@ func (c *Config) BindAddr() string {
 	return fmt.Sprintf("tcp://*:%d", c.Port)
 }
 
+func (c *Config) StreamBindAddr() string {
+	return fmt.Sprintf("tcp://*:%d", c.StreamPort)
+}
+
+func (c *Config) DirectoryBindAddr() string {
+	return fmt.Sprintf("tcp://*:%d", c.DirectoryPort)
+}
+
+// AdvertiseAddr is the RPC endpoint the agent registers in the directory.
+func (c *Config) AdvertiseAddr() string {
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(c.Advertise, strconv.Itoa(c.Port)))
+}
+
+// AdvertiseStreamAddr is the watch stream endpoint the agent registers in the directory.
+func (c *Config) AdvertiseStreamAddr() string {
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(c.Advertise, strconv.Itoa(c.StreamPort)))
+}
+
 func (c *Config) ConnectAddr() string {
 	return fmt.Sprintf("tcp://%s:%d", c.Host, c.Port)
 }
@@ -0,0 +1,95 @@
+package directory
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+// Bounds of the TTL an agent may ask for
+const (
+	MinTTL = time.Second
+	MaxTTL = 5 * time.Minute
+)
+
+// ErrNameTaken is returned for a name registered by another client.
+var ErrNameTaken = errors.New("name is registered by another client")
+
+// Registry holds the registered agents by name. It is not safe for
+// concurrent use; the directory serves it from a single loop.
+type Registry struct {
+	agents map[string]*protocol.AgentInfo
+	owners map[string]string // name -> client that registered it
+}
+
+func NewRegistry() *Registry {
+	return &Registry{agents: make(map[string]*protocol.AgentInfo), owners: make(map[string]string)}
+}
+
+// Register adds or renews an agent for client owner, returning the granted
+// TTL and whether the agent is new. A renewal replaces everything but the
+// first registration time. A name belongs to the client that registered it
+// until it leaves or expires; other clients get ErrNameTaken.
+func (r *Registry) Register(info protocol.AgentInfo, owner string, ttl time.Duration, now time.Time) (time.Duration, bool, error) {
+	ttl = min(max(ttl, MinTTL), MaxTTL)
+
+	old, renewal := r.agents[info.Name]
+	if renewal && r.owners[info.Name] != owner {
+		return 0, false, ErrNameTaken
+	}
+	info.Registered = now
+	if renewal {
+		info.Registered = old.Registered
+	}
+	info.LastSeen = now
+	info.Expires = now.Add(ttl)
+	r.agents[info.Name] = &info
+	r.owners[info.Name] = owner
+	return ttl, !renewal, nil
+}
+
+// Leave removes an agent of client owner and reports whether it was registered.
+func (r *Registry) Leave(name, owner string) (bool, error) {
+	if _, ok := r.agents[name]; !ok {
+		return false, nil
+	}
+	if r.owners[name] != owner {
+		return false, ErrNameTaken
+	}
+	delete(r.agents, name)
+	delete(r.owners, name)
+	return true, nil
+}
+
+// Expire removes the agents whose TTL ran out and returns them.
+func (r *Registry) Expire(now time.Time) []protocol.AgentInfo {
+	var gone []protocol.AgentInfo
+	for name, a := range r.agents {
+		if now.After(a.Expires) {
+			gone = append(gone, *a)
+			delete(r.agents, name)
+			delete(r.owners, name)
+		}
+	}
+	return gone
+}
+
+// List returns the live agents matching sel, sorted by name.
+func (r *Registry) List(sel Selector, now time.Time) []protocol.AgentInfo {
+	out := []protocol.AgentInfo{}
+	for _, a := range r.agents {
+		if now.After(a.Expires) || !sel.Matches(a.Labels) {
+			continue
+		}
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Len returns the number of registered agents, expired or not.
+func (r *Registry) Len() int {
+	return len(r.agents)
+}
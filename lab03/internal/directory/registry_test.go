@@ -0,0 +1,80 @@
+package directory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gemini-zeromq-labs/lab03/internal/protocol"
+)
+
+func agent(name string, labels map[string]string) protocol.AgentInfo {
+	return protocol.AgentInfo{Name: name, Endpoint: "tcp://" + name + ":5559", Labels: labels}
+}
+
+func TestRegistryLifecycle(t *testing.T) {
+	r := NewRegistry()
+	start := time.Now()
+
+	ttl, added, err := r.Register(agent("db1", nil), "agent", time.Millisecond, start)
+	if err != nil || !added || ttl != MinTTL {
+		t.Fatalf("Register = %s, %v, %v; want the minimum TTL, added", ttl, added, err)
+	}
+	_, added, _ = r.Register(agent("db1", nil), "agent", 10*time.Second, start.Add(time.Second))
+	if added {
+		t.Error("renewal reported as new")
+	}
+	list := r.List(nil, start.Add(time.Second))
+	if len(list) != 1 || !list[0].Registered.Equal(start) {
+		t.Fatalf("List = %+v, want db1 first registered at start", list)
+	}
+
+	if gone := r.Expire(start.Add(12 * time.Second)); len(gone) != 1 || r.Len() != 0 {
+		t.Errorf("Expire = %+v, %d left; want db1 gone", gone, r.Len())
+	}
+}
+
+func TestRegistryNameOwnership(t *testing.T) {
+	r := NewRegistry()
+	now := time.Now()
+	r.Register(agent("db1", nil), "agent", time.Minute, now)
+
+	if _, _, err := r.Register(agent("db1", nil), "mallory", time.Minute, now); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("hijack Register err = %v, want ErrNameTaken", err)
+	}
+	if _, _, err := r.Register(agent("db1", nil), "", time.Minute, now); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("anonymous Register err = %v, want ErrNameTaken", err)
+	}
+	if _, err := r.Leave("db1", "mallory"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("foreign Leave err = %v, want ErrNameTaken", err)
+	}
+	if list := r.List(nil, now); len(list) != 1 || list[0].Endpoint != "tcp://db1:5559" {
+		t.Fatalf("List = %+v, want the original db1", list)
+	}
+
+	if left, err := r.Leave("db1", "agent"); !left || err != nil {
+		t.Fatalf("Leave = %v, %v", left, err)
+	}
+	// Once free, the name can be registered by anyone
+	if _, added, err := r.Register(agent("db1", nil), "mallory", time.Minute, now); !added || err != nil {
+		t.Errorf("Register after Leave = %v, %v", added, err)
+	}
+}
+
+func TestRegistryList(t *testing.T) {
+	r := NewRegistry()
+	now := time.Now()
+	r.Register(agent("web1", map[string]string{"role": "web"}), "", time.Minute, now)
+	r.Register(agent("db2", map[string]string{"role": "db"}), "", time.Minute, now)
+	r.Register(agent("db1", map[string]string{"role": "db"}), "", time.Second, now)
+
+	sel, _ := ParseSelector("role=db")
+	list := r.List(sel, now)
+	if len(list) != 2 || list[0].Name != "db1" || list[1].Name != "db2" {
+		t.Errorf("List(role=db) = %+v, want db1, db2", list)
+	}
+	// Expired but not yet removed agents are left out
+	if list := r.List(sel, now.Add(2*time.Second)); len(list) != 1 || list[0].Name != "db2" {
+		t.Errorf("List after db1's TTL = %+v, want db2", list)
+	}
+}
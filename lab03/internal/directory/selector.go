@@ -0,0 +1,107 @@
+package directory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseLabels parses "env=prod,role=db" into a map.
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("label %q: want key=value", part)
+		}
+		labels[k] = strings.TrimSpace(v)
+	}
+	return labels, nil
+}
+
+// FormatLabels is the inverse of ParseLabels, with sorted keys.
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + labels[k]
+	}
+	return strings.Join(parts, ",")
+}
+
+type requirement struct {
+	key   string
+	value string
+	op    string // "=", "!=", "exists", "!exists"
+}
+
+// Selector matches label sets. All requirements must hold.
+type Selector []requirement
+
+// ParseSelector parses comma separated requirements:
+//
+//	key=value  key==value  key!=value  key  !key
+//
+// An empty string selects everything.
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		var r requirement
+		switch {
+		case strings.Contains(part, "!="):
+			k, v, _ := strings.Cut(part, "!=")
+			r = requirement{key: k, value: v, op: "!="}
+		case strings.Contains(part, "="):
+			k, v, _ := strings.Cut(part, "=")
+			r = requirement{key: k, value: strings.TrimPrefix(v, "="), op: "="}
+		case strings.HasPrefix(part, "!"):
+			r = requirement{key: part[1:], op: "!exists"}
+		default:
+			r = requirement{key: part, op: "exists"}
+		}
+		r.key, r.value = strings.TrimSpace(r.key), strings.TrimSpace(r.value)
+		if r.key == "" {
+			return nil, fmt.Errorf("selector %q: missing key", part)
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// Matches reports whether labels satisfy every requirement.
+// A missing key satisfies key!=value.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		v, ok := labels[r.key]
+		switch r.op {
+		case "=":
+			if !ok || v != r.value {
+				return false
+			}
+		case "!=":
+			if ok && v == r.value {
+				return false
+			}
+		case "exists":
+			if !ok {
+				return false
+			}
+		case "!exists":
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
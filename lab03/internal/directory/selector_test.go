@@ -0,0 +1,61 @@
+package directory
+
+import "testing"
+
+func TestSelectorMatches(t *testing.T) {
+	db := map[string]string{"env": "prod", "role": "db"}
+	web := map[string]string{"env": "staging", "role": "web", "canary": ""}
+
+	cases := []struct {
+		selector string
+		db, web  bool
+	}{
+		{"", true, true},
+		{"env=prod", true, false},
+		{"env==prod", true, false},
+		{" env = prod , role = db ", true, false},
+		{"env!=prod", false, true},
+		{"zone!=eu", true, true}, // a missing key satisfies !=
+		{"canary", false, true},  // exists, even with an empty value
+		{"!canary", true, false},
+		{"role=db,!canary", true, false},
+		{"env=prod,role=web", false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.selector, func(t *testing.T) {
+			sel, err := ParseSelector(tc.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sel.Matches(db); got != tc.db {
+				t.Errorf("matches db = %v, want %v", got, tc.db)
+			}
+			if got := sel.Matches(web); got != tc.web {
+				t.Errorf("matches web = %v, want %v", got, tc.web)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{"=prod", "!", "!=x"} {
+		if _, err := ParseSelector(s); err == nil {
+			t.Errorf("ParseSelector(%q) succeeded", s)
+		}
+	}
+	for _, s := range []string{"env", "=prod"} {
+		if _, err := ParseLabels(s); err == nil {
+			t.Errorf("ParseLabels(%q) succeeded", s)
+		}
+	}
+}
+
+func TestLabelsRoundTrip(t *testing.T) {
+	labels, err := ParseLabels(" role=db, env = prod ,,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FormatLabels(labels); got != "env=prod,role=db" {
+		t.Errorf("FormatLabels = %q, want env=prod,role=db", got)
+	}
+}
@@ -0,0 +1,153 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the protocol version of this build.
+// Version 1 is the original envelope without version, id and code; a message
+// without a version field is treated as version 1. Both sides ignore fields
+// they do not know, so either side can be upgraded first.
+const Version = 2
+
+// ErrorCode is the machine-readable reason of an ERROR response
+type ErrorCode string
+
+const (
+	ErrUnknownCommand   ErrorCode = "UNKNOWN_COMMAND"
+	ErrCollectionFailed ErrorCode = "COLLECTION_FAILED"
+	ErrUnauthorized     ErrorCode = "UNAUTHORIZED"
+	ErrBadRequest       ErrorCode = "BAD_REQUEST" // the request could not be parsed
+	ErrBusy             ErrorCode = "BUSY"        // the agent's queue for the command is full
+	ErrNoStream         ErrorCode = "NO_STREAM"   // the watch stream expired or never existed
+)
+
+// Error is an ERROR response as a Go error
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// NewRequest returns a current-version request for cmd with a fresh ID.
+func NewRequest(cmd CommandType) Request {
+	return Request{Version: Version, ID: NewID(), Command: cmd}
+}
+
+// NewID returns a random request ID.
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// OK builds the reply to req carrying payload.
+func OK(req *Request, payload interface{}) (Response, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Response{}, err
+	}
+	resp := reply(req)
+	resp.Status = "OK"
+	resp.Data = data
+	return resp, nil
+}
+
+// Fail builds an ERROR reply to req; req may be nil if it could not be parsed.
+func Fail(req *Request, code ErrorCode, msg string) Response {
+	resp := reply(req)
+	resp.Status = "ERROR"
+	resp.Code = code
+	resp.Error = msg
+	return resp
+}
+
+func reply(req *Request) Response {
+	resp := Response{Version: Version}
+	if req != nil {
+		resp.ID = req.ID
+	}
+	return resp
+}
+
+// Err returns the error of an ERROR response as an *Error, or nil.
+// Version 1 agents send no code; it is derived from their messages.
+func (r *Response) Err() error {
+	if r.Status != "ERROR" {
+		return nil
+	}
+	code := r.Code
+	if code == "" {
+		code = legacyCode(r.Error)
+	}
+	return &Error{Code: code, Message: r.Error}
+}
+
+func legacyCode(msg string) ErrorCode {
+	switch msg {
+	case "Unknown command":
+		return ErrUnknownCommand
+	case "Invalid JSON format":
+		return ErrBadRequest
+	}
+	return ErrCollectionFailed
+}
+
+// NewPayload returns a pointer to the payload type of cmd, or nil if the command is unknown.
+func NewPayload(cmd CommandType) interface{} {
+	switch cmd {
+	case CMD_CPU:
+		return &CPUData{}
+	case CMD_MEM:
+		return &MemData{}
+	case CMD_HOST:
+		return &HostData{}
+	case CMD_DISK:
+		return &DiskData{}
+	case CMD_NET:
+		return &NetData{}
+	case CMD_PROCS:
+		return &ProcsData{}
+	case CMD_WATCH:
+		return &WatchData{}
+	case CMD_REGISTER:
+		return &RegisterData{}
+	case CMD_AGENTS:
+		return &AgentsData{}
+	}
+	return nil
+}
+
+// Watchable reports whether a watch stream can sample cmd.
+func Watchable(cmd CommandType) bool {
+	switch cmd {
+	case CMD_CPU, CMD_MEM, CMD_HOST, CMD_DISK, CMD_NET, CMD_PROCS:
+		return true
+	}
+	return false
+}
+
+// Decode unmarshals the payload of a reply to cmd into its typed struct
+// (a pointer, see NewPayload). Payloads of unknown commands are decoded generically.
+func (r *Response) Decode(cmd CommandType) (interface{}, error) {
+	if len(r.Data) == 0 {
+		return NewPayload(cmd), nil
+	}
+	if v := NewPayload(cmd); v != nil {
+		if err := json.Unmarshal(r.Data, v); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", cmd, err)
+		}
+		return v, nil
+	}
+	var generic interface{}
+	if err := json.Unmarshal(r.Data, &generic); err != nil {
+		return nil, fmt.Errorf("decode %s payload: %w", cmd, err)
+	}
+	return generic, nil
+}
@@ -0,0 +1,106 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestRequestVersions(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     string
+		version int
+		id      string
+	}{
+		{"legacy bare request", `{"command":"CPU"}`, 0, ""},
+		{"versioned envelope", `{"version":2,"id":"abc","command":"CPU"}`, 2, "abc"},
+		{"unknown version", `{"version":3,"id":"abc","command":"CPU","deadline":"5s","trace":{"span":1}}`, 3, "abc"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := FromBytes([]byte(tc.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Version != tc.version || req.ID != tc.id || req.Command != CMD_CPU {
+				t.Errorf("request = %+v, want version %d id %q command CPU", req, tc.version, tc.id)
+			}
+
+			// The reply echoes the ID, which a legacy request does not have
+			resp, err := OK(req, CPUData{Cores: 4})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Version != Version || resp.ID != tc.id || resp.Status != "OK" {
+				t.Errorf("reply = %+v, want version %d id %q", resp, Version, tc.id)
+			}
+		})
+	}
+}
+
+func TestLegacyRequestWire(t *testing.T) {
+	// A legacy agent must see exactly the fields it knows
+	data, err := (&Request{Command: CMD_MEM}).ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"command":"MEM"}` {
+		t.Errorf("legacy request encodes as %s", data)
+	}
+}
+
+func TestResponseVersions(t *testing.T) {
+	cases := []struct {
+		name  string
+		raw   string
+		code  ErrorCode // empty = OK
+		cores int
+	}{
+		{"legacy ok", `{"status":"OK","data":{"model":"x","cores":2}}`, "", 2},
+		{"legacy unknown command", `{"status":"ERROR","error":"Unknown command"}`, ErrUnknownCommand, 0},
+		{"legacy bad json", `{"status":"ERROR","error":"Invalid JSON format"}`, ErrBadRequest, 0},
+		{"legacy other error", `{"status":"ERROR","error":"disk on fire"}`, ErrCollectionFailed, 0},
+		{"versioned ok", `{"version":2,"id":"abc","status":"OK","data":{"cores":8}}`, "", 8},
+		{"versioned error", `{"version":2,"id":"abc","status":"ERROR","code":"BUSY","error":"slow lane is full"}`, ErrBusy, 0},
+		{"unknown version ok", `{"version":3,"id":"abc","status":"OK","data":{"cores":16,"numa":[0,1]},"took_ms":3}`, "", 16},
+		{"unknown version error code", `{"version":3,"id":"abc","status":"ERROR","code":"QUOTA","error":"over quota"}`, "QUOTA", 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := ResponseFromBytes([]byte(tc.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = resp.Err()
+			if tc.code != "" {
+				var pe *Error
+				if !errors.As(err, &pe) || pe.Code != tc.code {
+					t.Errorf("Err() = %v, want code %s", err, tc.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Err() = %v", err)
+			}
+			payload, err := resp.Decode(CMD_CPU)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cpu := payload.(*CPUData); cpu.Cores != tc.cores {
+				t.Errorf("cores = %d, want %d", cpu.Cores, tc.cores)
+			}
+		})
+	}
+}
+
+func TestDecodeUnknownCommand(t *testing.T) {
+	resp := Response{Status: "OK", Data: json.RawMessage(`{"answer":42}`)}
+	payload, err := resp.Decode("FUTURE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := payload.(map[string]interface{}); !ok || m["answer"] != 42.0 {
+		t.Errorf("payload = %#v, want a generic map", payload)
+	}
+}
@@ -1,33 +1,100 @@
 package protocol
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 // CommandType defines the type of command
 type CommandType string
 
 const (
-	CMD_CPU  CommandType = "CPU"
-	CMD_MEM  CommandType = "MEM"
-	CMD_HOST CommandType = "HOST"
+	CMD_CPU   CommandType = "CPU"
+	CMD_MEM   CommandType = "MEM"
+	CMD_HOST  CommandType = "HOST"
+	CMD_DISK  CommandType = "DISK"
+	CMD_NET   CommandType = "NET"
+	CMD_PROCS CommandType = "PROCS"
+	CMD_WATCH CommandType = "WATCH" // open, renew or stop a sample stream
+
+	// Directory service
+	CMD_REGISTER CommandType = "REGISTER" // an agent registers or renews itself
+	CMD_AGENTS   CommandType = "AGENTS"   // list the registered agents
 )
 
-// Request sent by the Admin CLI
+// Request sent by the Admin CLI.
+// The argument fields are optional; older agents ignore them.
+// Version and ID are absent in version 1 requests.
 type Request struct {
+	Version int         `json:"version,omitempty"`
+	ID      string      `json:"id,omitempty"`
 	Command CommandType `json:"command"`
+	Disk    *DiskArgs   `json:"disk,omitempty"`  // CMD_DISK only
+	Net     *NetArgs    `json:"net,omitempty"`   // CMD_NET only
+	Procs   *ProcsArgs  `json:"procs,omitempty"` // CMD_PROCS only
+	Watch   *WatchArgs  `json:"watch,omitempty"` // CMD_WATCH only
+
+	Register *RegisterArgs `json:"register,omitempty"` // CMD_REGISTER only
+	Agents   *AgentsArgs   `json:"agents,omitempty"`   // CMD_AGENTS only
+	Auth     *Auth         `json:"auth,omitempty"`     // set by signing clients
+}
+
+// Auth authenticates a request. Signature is the hex HMAC-SHA256, under the
+// client's key, of the client, timestamp, nonce and the request without "auth".
+type Auth struct {
+	Client    string `json:"client"`
+	Timestamp int64  `json:"ts"` // unix milliseconds
+	Nonce     string `json:"nonce"`
+	Signature string `json:"sig"`
+}
+
+// DiskArgs are the arguments of CMD_DISK
+type DiskArgs struct {
+	All bool `json:"all,omitempty"` // include pseudo file systems (proc, tmpfs, ...)
 }
 
-// Response sent by the Node Agent
+// NetArgs are the arguments of CMD_NET
+type NetArgs struct {
+	Interface   string `json:"interface,omitempty"`   // glob over interface names
+	Connections bool   `json:"connections,omitempty"` // list the connections, not just the counts
+	Kind        string `json:"kind,omitempty"`        // tcp, udp, inet (default), inet4, inet6, ...
+	State       string `json:"state,omitempty"`       // only connections in this state, e.g. LISTEN
+}
+
+// Sort orders of CMD_PROCS
+const (
+	SortCPU  = "cpu"
+	SortMem  = "mem"
+	SortPID  = "pid"
+	SortName = "name"
+)
+
+// ProcsArgs are the arguments of CMD_PROCS
+type ProcsArgs struct {
+	Top  int    `json:"top,omitempty"`  // number of processes to return (default 10)
+	Sort string `json:"sort,omitempty"` // SortCPU (default), SortMem, SortPID or SortName
+	Name string `json:"name,omitempty"` // only processes whose name contains this (case-insensitive)
+	User string `json:"user,omitempty"` // only processes of this user
+}
+
+// Response sent by the Node Agent.
+// Data holds the payload type of the command (see NewPayload).
+// Version, ID and Code are absent in version 1 responses.
 type Response struct {
-	Status string      `json:"status"` // "OK" or "ERROR"
-	Data   interface{} `json:"data,omitempty"`
-	Error  string      `json:"error,omitempty"`
+	Version int             `json:"version,omitempty"`
+	ID      string          `json:"id,omitempty"` // echoes Request.ID
+	Status  string          `json:"status"`       // "OK" or "ERROR"
+	Code    ErrorCode       `json:"code,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
 }
 
 // CPUData represents the data payload for CMD_CPU
 type CPUData struct {
-	Model      string    `json:"model"`
-	Cores      int       `json:"cores"`
+	Model        string    `json:"model"`
+	Cores        int       `json:"cores"`
 	UsagePercent []float64 `json:"usage_percent"`
+	SampledAt    time.Time `json:"sampled_at,omitempty"` // end of the sample interval
 }
 
 // MemData represents the data payload for CMD_MEM
@@ -45,6 +112,137 @@ type HostData struct {
 	Uptime   uint64 `json:"uptime"` // seconds
 }
 
+// DiskData represents the data payload for CMD_DISK
+type DiskData struct {
+	Partitions []DiskUsage `json:"partitions"`
+}
+
+// DiskUsage is the usage of one mounted file system
+type DiskUsage struct {
+	Mountpoint  string  `json:"mountpoint"`
+	Device      string  `json:"device"`
+	Fstype      string  `json:"fstype"`
+	Total       uint64  `json:"total"`
+	Used        uint64  `json:"used"`
+	Free        uint64  `json:"free"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// NetData represents the data payload for CMD_NET
+type NetData struct {
+	Interfaces  []NetInterface `json:"interfaces"`
+	ConnStates  map[string]int `json:"conn_states"`           // connection count per state
+	Connections []NetConn      `json:"connections,omitempty"` // only if NetArgs.Connections
+}
+
+// NetInterface holds the counters of one network interface
+type NetInterface struct {
+	Name        string   `json:"name"`
+	Addrs       []string `json:"addrs,omitempty"`
+	BytesSent   uint64   `json:"bytes_sent"`
+	BytesRecv   uint64   `json:"bytes_recv"`
+	PacketsSent uint64   `json:"packets_sent"`
+	PacketsRecv uint64   `json:"packets_recv"`
+	Errin       uint64   `json:"errin"`
+	Errout      uint64   `json:"errout"`
+	Dropin      uint64   `json:"dropin"`
+	Dropout     uint64   `json:"dropout"`
+}
+
+// NetConn is one open socket
+type NetConn struct {
+	Proto  string `json:"proto"` // tcp, tcp6, udp, udp6
+	Local  string `json:"local"`
+	Remote string `json:"remote,omitempty"`
+	State  string `json:"state,omitempty"`
+	PID    int32  `json:"pid,omitempty"`
+}
+
+// ProcsData represents the data payload for CMD_PROCS
+type ProcsData struct {
+	Total     int           `json:"total"` // processes matching the filters
+	Sort      string        `json:"sort"`
+	Processes []ProcessInfo `json:"processes"` // the top ones, in sort order
+}
+
+// ProcessInfo describes one process.
+// CPUPercent is averaged over the lifetime of the process.
+type ProcessInfo struct {
+	PID        int32   `json:"pid"`
+	Name       string  `json:"name"`
+	User       string  `json:"user,omitempty"`
+	CPUPercent float64 `json:"cpu_percent"`
+	MemPercent float32 `json:"mem_percent"`
+	RSS        uint64  `json:"rss"`
+	Threads    int32   `json:"threads"`
+}
+
+// WatchArgs are the arguments of CMD_WATCH. Without a Stream they open a new
+// stream of the Commands; with one they renew its lease, or end it if Stop is set.
+type WatchArgs struct {
+	Stream     string        `json:"stream,omitempty"`
+	Stop       bool          `json:"stop,omitempty"`
+	Commands   []CommandType `json:"commands,omitempty"`
+	IntervalMS int64         `json:"interval_ms,omitempty"` // time between samples
+	LeaseMS    int64         `json:"lease_ms,omitempty"`    // the stream ends unless renewed within this
+}
+
+// WatchData represents the data payload for CMD_WATCH
+type WatchData struct {
+	Stream     string        `json:"stream"` // topic of the stream's samples
+	Port       int           `json:"port"`   // PUB port publishing the samples
+	Commands   []CommandType `json:"commands"`
+	IntervalMS int64         `json:"interval_ms"` // as granted
+	LeaseMS    int64         `json:"lease_ms"`    // as granted
+	Expires    time.Time     `json:"expires"`
+}
+
+// Sample is one message of a watch stream, published as [stream, Sample JSON].
+// Response holds the reply the command would have got, typed by Command.
+type Sample struct {
+	Stream   string      `json:"stream"`
+	Seq      uint64      `json:"seq"` // per stream, one per tick
+	Command  CommandType `json:"command"`
+	Time     time.Time   `json:"time"`
+	Response Response    `json:"response"`
+}
+
+// AgentInfo is what the directory knows about one agent
+type AgentInfo struct {
+	Name           string            `json:"name"`
+	Endpoint       string            `json:"endpoint"`                  // RPC, tcp://host:port
+	StreamEndpoint string            `json:"stream_endpoint,omitempty"` // watch streams
+	Labels         map[string]string `json:"labels,omitempty"`
+	Host           HostData          `json:"host"`
+	Registered     time.Time         `json:"registered"` // first registration
+	LastSeen       time.Time         `json:"last_seen"`  // last heartbeat
+	Expires        time.Time         `json:"expires"`
+}
+
+// RegisterArgs are the arguments of CMD_REGISTER. Every heartbeat sends the
+// full registration, so a restarted directory is repopulated by the next one.
+type RegisterArgs struct {
+	Agent AgentInfo `json:"agent"` // the directory sets the times
+	TTLMS int64     `json:"ttl_ms"`
+	Leave bool      `json:"leave,omitempty"` // deregister, e.g. on shutdown
+}
+
+// RegisterData represents the data payload for CMD_REGISTER
+type RegisterData struct {
+	TTLMS   int64     `json:"ttl_ms"` // as granted
+	Expires time.Time `json:"expires"`
+}
+
+// AgentsArgs are the arguments of CMD_AGENTS
+type AgentsArgs struct {
+	Selector string `json:"selector,omitempty"` // label selector, e.g. "env=prod,role=db"
+}
+
+// AgentsData represents the data payload for CMD_AGENTS
+type AgentsData struct {
+	Agents []AgentInfo `json:"agents"` // sorted by name
+}
+
 // Helper methods
 
 func (r *Request) ToBytes() ([]byte, error) {
@@ -60,3 +258,9 @@ func FromBytes(data []byte) (*Request, error) {
 func (r *Response) ToBytes() ([]byte, error) {
 	return json.Marshal(r)
 }
+
+func ResponseFromBytes(data []byte) (*Response, error) {
+	var r Response
+	err := json.Unmarshal(data, &r)
+	return &r, err
+}
@@ -5,12 +5,29 @@ Write-Host "Initializing Lab 03..."
 go mod tidy
 ./build.ps1
 
+# The keys file holds secrets and is not committed; create it with fresh keys
+if (-not (Test-Path clients.json)) {
+    Write-Host "Creating clients.json with random keys..."
+    $clients = Get-Content clients.example.json -Raw | ConvertFrom-Json
+    $rng = [System.Security.Cryptography.RandomNumberGenerator]::Create()
+    foreach ($c in $clients.PSObject.Properties) {
+        $bytes = New-Object byte[] 32
+        $rng.GetBytes($bytes)
+        $c.Value = -join ($bytes | ForEach-Object { $_.ToString("x2") })
+    }
+    $clients | ConvertTo-Json | Set-Content clients.json
+}
+
+Write-Host "Starting Directory..."
+Start-Process ".\directory.exe" -ArgumentList "-keys", "clients.json", "-policy", "auth_policy.json" -NoNewWindow
+
 Write-Host "Starting Node Agent (Server)..."
-Start-Process ".\node_agent.exe" -NoNewWindow
+Start-Process ".\node_agent.exe" -ArgumentList "-keys", "clients.json", "-policy", "auth_policy.json", "-client-id", "agent", "-directory", "localhost", "-advertise", "localhost", "-labels", "env=lab,role=demo" -NoNewWindow
 
 trap {
     Write-Host "Stopping processes..."
     Stop-Process -Name node_agent -ErrorAction SilentlyContinue
+    Stop-Process -Name directory -ErrorAction SilentlyContinue
     exit
 }
 
@@ -18,14 +35,26 @@ Write-Host "Waiting for agent to initialize..."
 Start-Sleep -Seconds 2
 
 Write-Host "--- Querying Host Info ---" -ForegroundColor Yellow
-& .\admin_cli.exe HOST
+& .\admin_cli.exe -keys clients.json -client-id ops HOST
 
 Write-Host "`n--- Querying Memory Info ---" -ForegroundColor Yellow
-& .\admin_cli.exe MEM
+& .\admin_cli.exe -keys clients.json -client-id ops MEM
 
 Write-Host "`n--- Querying CPU Info ---" -ForegroundColor Yellow
-& .\admin_cli.exe CPU
+& .\admin_cli.exe -keys clients.json -client-id ops CPU
+
+Write-Host "`n--- Viewer role may not list processes (see audit.log) ---" -ForegroundColor Yellow
+& .\admin_cli.exe -keys clients.json -client-id monitor PROCS
+
+Write-Host "`n--- Agents registered in the directory ---" -ForegroundColor Yellow
+& .\admin_cli.exe -directory localhost agents
+
+Write-Host "`n--- Memory of every env=lab agent ---" -ForegroundColor Yellow
+& .\admin_cli.exe -keys clients.json -client-id ops -directory localhost -l env=lab MEM
+
+Write-Host "`n--- Watching CPU and Memory (5 refreshes) ---" -ForegroundColor Yellow
+& .\admin_cli.exe -keys clients.json -client-id ops watch CPU MEM -interval 1s -count 5
 
 Write-Host "`nLab 03 Demonstration Complete."
-Write-Host "Node Agent is still running. Press Ctrl+C to stop."
+Write-Host "Node Agent and Directory are still running. Press Ctrl+C to stop."
 while($true) { Start-Sleep -Seconds 1 }
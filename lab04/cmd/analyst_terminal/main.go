@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"gemini-zeromq-labs/lab04/internal/config"
 	"gemini-zeromq-labs/lab04/internal/protocol"
@@ -19,8 +22,8 @@ func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
 	topic := "sensors/temp"
-	if len(os.Args) > 1 {
-		topic = os.Args[1]
+	if flag.NArg() > 0 {
+		topic = flag.Arg(0)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -44,41 +47,100 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The marker is subscribed last, so seeing it proves the topic is too
+	marker := newMarker()
 	logger.Info("Subscribing", "topic", topic)
-	if err := sub.SetOption(zmq4.OptionSubscribe, topic); err != nil {
-		logger.Error("Failed to subscribe", "error", err)
-		os.Exit(1)
+	for _, t := range []string{topic, marker} {
+		if err := sub.SetOption(zmq4.OptionSubscribe, t); err != nil {
+			logger.Error("Failed to subscribe", "error", err)
+			os.Exit(1)
+		}
 	}
 
-	fmt.Printf("Listening for updates on %s...\n", topic)
-
-	for {
-		msg, err := sub.Recv()
-		if err != nil {
-			if ctx.Err() != nil {
-				break
+	// Live messages queue up here while the terminal syncs and fetches the
+	// snapshot
+	live := make(chan zmq4.Msg, 256)
+	go func() {
+		defer close(live)
+		for {
+			msg, err := sub.Recv()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logger.Error("Recv error", "error", err)
+				continue
+			}
+			select {
+			case live <- msg:
+			case <-ctx.Done():
+				return
 			}
-			logger.Error("Recv error", "error", err)
-			continue
 		}
+	}()
+
+	fmt.Printf("Listening for updates on %s...\n", topic)
 
+	// Once the subscription is confirmed nothing published from then on is
+	// missed, and the snapshot fills in what was published before. Live
+	// messages up to the snapshot's last number were published before it was
+	// taken, so they are either in it or older than what was asked for.
+	// Without confirmation (e.g. an older broker) this is best-effort: updates
+	// published just after the snapshot may be lost.
+	if err := awaitSubscription(ctx, cfg.SnapshotConnectAddr(), marker, live); err != nil {
+		logger.Warn("Subscription not confirmed, updates right after the snapshot may be missed", "error", err)
+	}
+	cached, upTo, err := fetchSnapshot(ctx, cfg.SnapshotConnectAddr(), topic, cfg.Last, cfg.Since)
+	if err != nil {
+		logger.Warn("No snapshot, showing live updates only", "error", err)
+	}
+	for _, c := range cached {
+		show(logger, c.frames[1], replayedAgo(c.kind, c.age))
+	}
+
+	for msg := range live {
 		if len(msg.Frames) < 2 {
 			continue
 		}
 
-		// rcvTopic := string(msg.Frames[0])
 		payload := msg.Frames[1]
 
-		data, err := protocol.FromBytes(payload)
-		if err != nil {
-			logger.Error("Parse error", "error", err)
-			continue
+		// The broker adds its message number as the last frame
+		if len(msg.Frames) >= 3 {
+			seq, err := strconv.ParseUint(string(msg.Frames[len(msg.Frames)-1]), 10, 64)
+			if err == nil && seq <= upTo {
+				continue
+			}
 		}
+		show(logger, payload, "live")
+	}
+}
 
-		fmt.Printf("[%s] %s: %.2f (cached or new)\n", 
-			data.Timestamp.Format("15:04:05.000"), 
-			data.SensorID,
-			data.Value,
-		)
+// replayedAgo describes a replayed message, e.g. "cached 42s ago" or
+// "history, 5s ago".
+func replayedAgo(kind string, age time.Duration) string {
+	how := "cached "
+	if kind == protocol.SnapshotHistory {
+		how = "history, "
 	}
+	if age < time.Second {
+		return how + "just now"
+	}
+	return fmt.Sprintf("%s%s ago", how, age.Round(time.Second))
+}
+
+// show prints one update; how says where it came from, e.g. "live".
+func show(logger *slog.Logger, payload []byte, how string) {
+	data, err := protocol.FromBytes(payload)
+	if err != nil {
+		logger.Error("Parse error", "error", err)
+		return
+	}
+
+	fmt.Printf("[%s] %s: %.2f (%s)\n",
+		data.Timestamp.Format("15:04:05.000"),
+		data.SensorID,
+		data.Value,
+		how,
+	)
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gemini-zeromq-labs/lab04/internal/protocol"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+// snapshotTimeout bounds the whole snapshot exchange
+const snapshotTimeout = 2 * time.Second
+
+// cachedValue is a message replayed from the broker's cache
+type cachedValue struct {
+	kind   string        // protocol.SnapshotCached or protocol.SnapshotHistory
+	frames [][]byte      // topic, payload...
+	age    time.Duration // since the broker received it
+}
+
+// fetchSnapshot asks the broker for the cached message of every topic under
+// prefix or, with last or since set, for their history. It returns the
+// messages and the broker's last message number at the time. They come over
+// a DEALER of our own, so other subscribers never see them.
+func fetchSnapshot(ctx context.Context, endpoint, prefix string, last int, since time.Duration) ([]cachedValue, uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+
+	dealer := zmq4.NewDealer(ctx)
+	defer dealer.Close()
+	if err := dealer.Dial(endpoint); err != nil {
+		return nil, 0, err
+	}
+	req := zmq4.NewMsgFrom([]byte(protocol.SnapshotRequest), []byte(prefix))
+	if last > 0 || since > 0 {
+		req = zmq4.NewMsgFrom([]byte(protocol.SnapshotHistory), []byte(prefix),
+			[]byte(strconv.Itoa(last)), []byte(strconv.FormatInt(since.Milliseconds(), 10)))
+	}
+	if err := dealer.Send(req); err != nil {
+		return nil, 0, err
+	}
+
+	var cached []cachedValue
+	for {
+		msg, err := dealer.Recv()
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(msg.Frames) == 0 {
+			continue
+		}
+		switch kind := string(msg.Frames[0]); kind {
+		case protocol.SnapshotCached, protocol.SnapshotHistory:
+			if len(msg.Frames) < 5 {
+				continue
+			}
+			// [kind, age, seq, topic, payload...]; the order is the broker's
+			ms, err := strconv.ParseInt(string(msg.Frames[1]), 10, 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("bad age %q", msg.Frames[1])
+			}
+			cached = append(cached, cachedValue{kind: kind, frames: msg.Frames[3:], age: time.Duration(ms) * time.Millisecond})
+		case protocol.SnapshotEnd:
+			if len(msg.Frames) < 3 {
+				return nil, 0, errors.New("broker: END without seq")
+			}
+			upTo, err := strconv.ParseUint(string(msg.Frames[2]), 10, 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("bad seq %q", msg.Frames[2])
+			}
+			return cached, upTo, nil
+		case protocol.SnapshotError:
+			if len(msg.Frames) > 1 {
+				return nil, 0, fmt.Errorf("broker: %s", msg.Frames[1])
+			}
+			return nil, 0, errors.New("broker: snapshot refused")
+		}
+	}
+}
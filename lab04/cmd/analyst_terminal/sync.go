@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gemini-zeromq-labs/lab04/internal/protocol"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+// syncRetry is how long the terminal waits for its marker before asking again
+const syncRetry = 100 * time.Millisecond
+
+// newMarker returns a marker topic no other terminal uses
+func newMarker() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return protocol.SyncPrefix + hex.EncodeToString(b)
+}
+
+// awaitSubscription waits until the broker's PUB has applied our
+// subscriptions, which it does asynchronously, so messages published right
+// after subscribing can still be filtered out. The caller subscribes to
+// marker after its topic; a SUB's subscriptions reach the broker in order,
+// so once the broker echoes the marker on the live stream the topic is
+// subscribed too. A marker published before its own subscription arrived is
+// lost, so the request is repeated every syncRetry. Live messages read
+// meanwhile are dropped: they were published before the snapshot is taken.
+func awaitSubscription(ctx context.Context, endpoint, marker string, live <-chan zmq4.Msg) error {
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+
+	dealer := zmq4.NewDealer(ctx)
+	defer dealer.Close()
+	if err := dealer.Dial(endpoint); err != nil {
+		return err
+	}
+	req := zmq4.NewMsgFrom([]byte(protocol.SnapshotSync), []byte(marker))
+	for {
+		if err := dealer.Send(req); err != nil {
+			return err
+		}
+		retry := time.After(syncRetry)
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case msg, ok := <-live:
+				if !ok {
+					return errors.New("subscriber closed")
+				}
+				if len(msg.Frames) == 1 && string(msg.Frames[0]) == marker {
+					return nil
+				}
+			case <-retry:
+				break wait
+			}
+		}
+	}
+}
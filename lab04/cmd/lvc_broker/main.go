@@ -2,17 +2,25 @@ package main
 
 import (
 	"context"
-	"encoding/hex"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"gemini-zeromq-labs/lab04/internal/config"
+	"gemini-zeromq-labs/lab04/internal/lvc"
+	"gemini-zeromq-labs/lab04/internal/protocol"
 
 	"github.com/go-zeromq/zmq4"
 )
 
+// snapshotQueue bounds the snapshot replies waiting to be sent. Requests
+// beyond it are dropped; their terminals time out and show live updates only.
+const snapshotQueue = 64
+
 func main() {
 	cfg := config.LoadConfig()
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
@@ -28,33 +36,68 @@ func main() {
 		cancel()
 	}()
 
-	// 1. Backend (XSUB) - Publishers connect here
-	backend := zmq4.NewXSub(ctx)
+	// 1. Backend (SUB) - Publishers connect here.
+	// The cache needs every topic, whoever subscribes, so the backend takes
+	// everything. A SUB socket also repeats its subscription to publishers
+	// that connect later, which an XSUB does not.
+	backend := zmq4.NewSub(ctx)
 	defer backend.Close()
 	if err := backend.Listen(cfg.BackendBindAddr()); err != nil {
 		logger.Error("Failed to bind backend", "error", err)
 		os.Exit(1)
 	}
+	if err := backend.SetOption(zmq4.OptionSubscribe, ""); err != nil {
+		logger.Error("Failed to subscribe backend", "error", err)
+		os.Exit(1)
+	}
 
-	// 2. Frontend (XPUB) - Subscribers connect here
-	frontend := zmq4.NewXPub(ctx)
+	// 2. Frontend (PUB) - Subscribers connect here for live updates
+	frontend := zmq4.NewPub(ctx)
 	defer frontend.Close()
 	if err := frontend.Listen(cfg.FrontendBindAddr()); err != nil {
 		logger.Error("Failed to bind frontend", "error", err)
 		os.Exit(1)
 	}
 
-	logger.Info("LVC Broker started", 
-		"backend", cfg.BackendBindAddr(), 
-		"frontend", cfg.FrontendBindAddr())
+	// 3. Snapshot (ROUTER) - Subscribers ask here for the cached values,
+	// which go back to the asking subscriber only
+	snapshot := zmq4.NewRouter(ctx)
+	defer snapshot.Close()
+	if err := snapshot.Listen(cfg.SnapshotBindAddr()); err != nil {
+		logger.Error("Failed to bind snapshot", "error", err)
+		os.Exit(1)
+	}
 
-	// Last Value Cache: Topic -> Message Frames (Slice of bytes)
-	cache := make(map[string][][]byte)
+	logger.Info("LVC Broker started",
+		"backend", cfg.BackendBindAddr(),
+		"frontend", cfg.FrontendBindAddr(),
+		"snapshot", cfg.SnapshotBindAddr())
+
+	// Last Value Cache: Topic -> Message Frames, sorted by topic
+	topicTTL, err := lvc.ParseTopicTTL(cfg.TopicTTL)
+	if err != nil {
+		logger.Error("Invalid -topic-ttl", "error", err)
+		os.Exit(1)
+	}
+	cache := lvc.NewCache(lvc.Limits{
+		TTL:        cfg.TTL,
+		TopicTTL:   topicTTL,
+		MaxBytes:   cfg.CacheBytes,
+		History:    cfg.History,
+		HistoryAge: cfg.HistoryAge,
+	})
+	logger.Info("Cache limits", "ttl", cfg.TTL.String(), "topic_ttl", cfg.TopicTTL,
+		"max_bytes", cfg.CacheBytes, "max_age", cfg.MaxAge.String(),
+		"history", cfg.History, "history_age", cfg.HistoryAge.String())
+	if cfg.Checkpoint != "" {
+		restore(cache, cfg.Checkpoint, logger)
+	}
 
 	type connMsg struct {
-		msg       zmq4.Msg
-		isBackend bool
-		err       error
+		msg        zmq4.Msg
+		isBackend  bool
+		isSnapshot bool
+		err        error
 	}
 
 	msgChan := make(chan connMsg)
@@ -77,14 +120,14 @@ func main() {
 		}
 	}()
 
-	// Goroutine for Frontend (Subscribers)
+	// Goroutine for Snapshot requests (Subscribers)
 	go func() {
 		for {
-			msg, err := frontend.Recv()
+			msg, err := snapshot.Recv()
 			select {
 			case <-ctx.Done():
 				return
-			case msgChan <- connMsg{msg: msg, isBackend: false, err: err}:
+			case msgChan <- connMsg{msg: msg, isSnapshot: true, err: err}:
 			}
 			if err != nil {
 				if ctx.Err() != nil {
@@ -94,10 +137,56 @@ func main() {
 		}
 	}()
 
+	// Snapshot replies go out from their own goroutine: a ROUTER send to a
+	// slow terminal can block, and the loop must keep forwarding. The loop
+	// takes the entries from the cache; their frames are never modified
+	// afterwards, so the sender may read them.
+	replies := make(chan []zmq4.Msg, snapshotQueue)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case batch := <-replies:
+				for _, m := range batch {
+					if err := snapshot.Send(m); err != nil {
+						logger.Error("Failed to send snapshot", "error", err)
+						break
+					}
+				}
+			}
+		}
+	}()
+
+	expiry := time.NewTicker(time.Second)
+	defer expiry.Stop()
+
+	// Checkpoints are taken when the cache changed since the last one
+	var checkpointTick <-chan time.Time
+	if cfg.Checkpoint != "" && cfg.CheckpointInterval > 0 {
+		t := time.NewTicker(cfg.CheckpointInterval)
+		defer t.Stop()
+		checkpointTick = t.C
+	}
+	dirty := false
+
 	for {
 		select {
 		case <-ctx.Done():
+			if cfg.Checkpoint != "" && dirty {
+				save(cache, cfg.Checkpoint, logger)
+			}
 			return
+		case <-checkpointTick:
+			if dirty {
+				save(cache, cfg.Checkpoint, logger)
+				dirty = false
+			}
+		case now := <-expiry.C:
+			if gone := cache.Expire(now); len(gone) > 0 {
+				logger.Info("Cached values expired", "topics", gone, "cached", cache.Len())
+				dirty = true
+			}
 		case cm := <-msgChan:
 			if cm.err != nil {
 				// Log error but continue (unless fatal)
@@ -112,46 +201,133 @@ func main() {
 				if len(msg.Frames) >= 2 {
 					topic := string(msg.Frames[0])
 					// Update Cache
-					cachedMsg := make([][]byte, len(msg.Frames))
-					for i, f := range msg.Frames {
-						cachedMsg[i] = make([]byte, len(f))
-						copy(cachedMsg[i], f)
+					seq, evicted := cache.Set(topic, msg.Frames, time.Now())
+					dirty = true
+					logger.Debug("Cached update", "topic", topic, "seq", seq)
+					if len(evicted) > 0 {
+						logger.Warn("Cache full, evicted least recently used", "topics", evicted, "bytes", cache.Bytes())
 					}
-					cache[topic] = cachedMsg
-					logger.Debug("Cached update", "topic", topic)
+					// The number lets terminals line the live stream up with replays
+					msg = zmq4.NewMsgFrom(append(msg.Frames, []byte(strconv.FormatUint(seq, 10)))...)
 				}
 				// Forward to Frontend (Subscribers)
 				frontend.Send(msg)
 
-			} else {
-				// Message from Subscriber -> Frontend
-				msg := cm.msg
-				// XPUB frames: Byte 0 is flag (0x01=Sub, 0x00=Unsub)
-				if len(msg.Frames) > 0 {
-					frame := msg.Frames[0]
-					if len(frame) > 0 {
-						flag := frame[0]
-						topicBytes := frame[1:]
-						topic := string(topicBytes)
-
-						if flag == 1 {
-							logger.Info("New subscription detected", "topic", topic)
-							// Check Cache
-							if lastMsgFrames, ok := cache[topic]; ok {
-								logger.Info("Sending cached value", "topic", topic)
-								cachedMsg := zmq4.NewMsgFrom(lastMsgFrames...)
-								frontend.Send(cachedMsg)
-							}
-						} else {
-							logger.Debug("Unsubscription detected", "topic", topic)
-						}
+			} else if cm.isSnapshot {
+				// [identity, SYNC, marker] is echoed on the live stream, in
+				// order with the updates, so the terminal knows the frontend
+				// has its subscriptions
+				if f := cm.msg.Frames; len(f) == 3 && string(f[1]) == protocol.SnapshotSync {
+					if strings.HasPrefix(string(f[2]), protocol.SyncPrefix) {
+						frontend.Send(zmq4.NewMsg(f[2]))
 					} else {
-						logger.Warn("Received empty frame from frontend", "hex", hex.EncodeToString(frame))
+						logger.Warn("Invalid sync marker", "marker", string(f[2]))
 					}
+					continue
+				}
+				batch := snapshotReplies(cm.msg, cache, cfg.MaxAge, logger)
+				if len(batch) == 0 {
+					continue
+				}
+				select {
+				case replies <- batch:
+				default:
+					logger.Warn("Snapshot replies backed up, dropping request", "queued", len(replies))
 				}
-				// Forward subscription upstream to Publishers (via Backend)
-				backend.Send(msg)
 			}
 		}
 	}
 }
+
+// snapshotReplies answers [identity, SNAPSHOT, prefix] with the cached value of
+// every topic under prefix, in topic order, and [identity, HISTORY, prefix,
+// last, since] with the kept messages under prefix, in arrival order. Each
+// comes with its age and number, then END with the last number. ZMQ
+// subscriptions are prefixes, so the empty prefix asks for every topic.
+// Messages older than maxAge are left out. It returns the reply messages,
+// addressed to the asking terminal, for the sender to send.
+func snapshotReplies(msg zmq4.Msg, cache *lvc.Cache, maxAge time.Duration, logger *slog.Logger) []zmq4.Msg {
+	if len(msg.Frames) < 2 {
+		return nil
+	}
+	identity := msg.Frames[0]
+	var out []zmq4.Msg
+	reply := func(frames ...[]byte) {
+		out = append(out, zmq4.NewMsgFrom(append([][]byte{identity}, frames...)...))
+	}
+	invalid := func(reason string) []zmq4.Msg {
+		logger.Warn("Invalid snapshot request", "frames", len(msg.Frames), "reason", reason)
+		reply([]byte(protocol.SnapshotError), []byte(reason))
+		return out
+	}
+
+	now := time.Now()
+	kind := string(msg.Frames[1])
+	var entries []lvc.Entry
+	switch {
+	case kind == protocol.SnapshotRequest && len(msg.Frames) == 3:
+		prefix := string(msg.Frames[2])
+		entries = cache.Prefix(prefix, maxAge, now)
+		logger.Info("Sending snapshot", "prefix", prefix, "cached", len(entries))
+
+	case kind == protocol.SnapshotHistory && len(msg.Frames) == 5:
+		prefix := string(msg.Frames[2])
+		last, err1 := strconv.Atoi(string(msg.Frames[3]))
+		sinceMS, err2 := strconv.ParseInt(string(msg.Frames[4]), 10, 64)
+		if err1 != nil || err2 != nil || last < 0 || sinceMS < 0 {
+			return invalid("bad last or since")
+		}
+		since := time.Duration(sinceMS) * time.Millisecond
+		if maxAge > 0 && (since == 0 || since > maxAge) {
+			since = maxAge
+		}
+		entries = cache.History(prefix, last, since, now)
+		logger.Info("Sending history", "prefix", prefix, "last", last, "since", since.String(), "messages", len(entries))
+
+	default:
+		return invalid("invalid request")
+	}
+
+	tag := []byte(protocol.SnapshotCached)
+	if kind == protocol.SnapshotHistory {
+		tag = []byte(protocol.SnapshotHistory)
+	}
+	for _, e := range entries {
+		age := strconv.FormatInt(now.Sub(e.Updated).Milliseconds(), 10)
+		seq := strconv.FormatUint(e.Seq, 10)
+		reply(append([][]byte{tag, []byte(age), []byte(seq)}, e.Frames...)...)
+	}
+	reply([]byte(protocol.SnapshotEnd), []byte(strconv.Itoa(len(entries))), []byte(strconv.FormatUint(cache.Seq(), 10)))
+	return out
+}
+
+// restore loads the checkpoint at path into the cache. A missing or damaged
+// checkpoint only costs the cached values, so the broker starts empty.
+func restore(cache *lvc.Cache, path string, logger *slog.Logger) {
+	cp, err := lvc.LoadCheckpoint(path)
+	if err == nil && cp == nil {
+		logger.Info("No cache checkpoint, starting empty", "path", path)
+		return
+	}
+	var expired int
+	if err == nil {
+		expired, err = cache.Restore(cp, time.Now())
+	}
+	if err != nil {
+		logger.Warn("Ignoring cache checkpoint", "path", path, "error", err)
+		return
+	}
+	logger.Info("Cache restored", "path", path, "saved_at", cp.SavedAt, "cached", cache.Len(), "expired", expired)
+}
+
+func save(cache *lvc.Cache, path string, logger *slog.Logger) {
+	cp, err := cache.Checkpoint()
+	if err == nil {
+		err = cp.Save(path)
+	}
+	if err != nil {
+		logger.Error("Failed to save cache checkpoint", "path", path, "error", err)
+		return
+	}
+	logger.Debug("Cache checkpoint saved", "path", path, "cached", cache.Len())
+}
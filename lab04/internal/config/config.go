@@ -3,18 +3,47 @@ package config
 import (
 	"flag"
 	"fmt"
+	"time"
 )
 
 type Config struct {
 	Host         string
 	BackendPort  int // Pubs connect here
 	FrontendPort int // Subs connect here
+	SnapshotPort int // Subs ask for cached values here
+
+	// Cache limits (Broker)
+	TTL        time.Duration // lifetime of a cached value, 0 = forever
+	TopicTTL   string        // prefix=duration,... overriding TTL
+	CacheBytes int64         // memory cap of the cache, 0 = none
+	MaxAge     time.Duration // older values are not replayed, 0 = any age
+	History    int           // messages kept per topic
+	HistoryAge time.Duration // older messages leave the history, 0 = any age
+
+	// History replay (Terminal)
+	Last  int           // replay the last messages per topic, 0 = only the cached value
+	Since time.Duration // replay the messages of this long, 0 = only the cached value
+
+	// Cache persistence (Broker)
+	Checkpoint         string        // file the cache is saved to, "" = none
+	CheckpointInterval time.Duration // time between saves
 }
 
 func LoadConfig() *Config {
 	host := flag.String("host", "127.0.0.1", "Host Address")
-	backendPort := flag.Int("backend-port", 5560, "Port for Publishers (XSUB)")
-	frontendPort := flag.Int("frontend-port", 5561, "Port for Subscribers (XPUB)")
+	backendPort := flag.Int("backend-port", 5560, "Port for Publishers (SUB)")
+	frontendPort := flag.Int("frontend-port", 5561, "Port for Subscribers (PUB)")
+	snapshotPort := flag.Int("snapshot-port", 5562, "Port for cache snapshot requests (ROUTER)")
+	ttl := flag.Duration("ttl", time.Hour, "Lifetime of a cached value after its last update, 0 = forever (Broker only)")
+	topicTTL := flag.String("topic-ttl", "", "Per-topic TTLs by prefix, e.g. sensors/temp=30s,sensors/=5m; the longest prefix wins (Broker only)")
+	cacheBytes := flag.Int64("cache-bytes", 64<<20, "Memory cap of the cache in bytes, least recently used values are evicted, 0 = none (Broker only)")
+	maxAge := flag.Duration("max-age", 0, "Do not replay values older than this, 0 = any age (Broker only)")
+	history := flag.Int("history", 100, "Messages kept per topic for history replays, 1 = only the last value (Broker only)")
+	historyAge := flag.Duration("history-age", 0, "Drop kept messages older than this, except a topic's last value, 0 = any age (Broker only)")
+	last := flag.Int("last", 0, "Replay the last N messages of each topic before going live (Terminal only)")
+	since := flag.Duration("since", 0, "Replay the messages of this long before going live, e.g. 5m (Terminal only)")
+	checkpoint := flag.String("checkpoint", "lvc_cache.json", "File the cache is saved to and restored from, empty = none (Broker only)")
+	checkpointInterval := flag.Duration("checkpoint-interval", 30*time.Second, "Time between cache saves; it is also saved on shutdown (Broker only)")
 	flag.Parse()
 
 	// Env var overrides omitted for brevity but recommended in prod
@@ -23,6 +52,18 @@ func LoadConfig() *Config {
 		Host:         *host,
 		BackendPort:  *backendPort,
 		FrontendPort: *frontendPort,
+		SnapshotPort: *snapshotPort,
+		TTL:          *ttl,
+		TopicTTL:     *topicTTL,
+		CacheBytes:   *cacheBytes,
+		MaxAge:       *maxAge,
+		History:      *history,
+		HistoryAge:   *historyAge,
+		Last:         *last,
+		Since:        *since,
+
+		Checkpoint:         *checkpoint,
+		CheckpointInterval: *checkpointInterval,
 	}
 }
 
@@ -35,6 +76,10 @@ func (c *Config) FrontendBindAddr() string {
 	return fmt.Sprintf("tcp://*:%d", c.FrontendPort)
 }
 
+func (c *Config) SnapshotBindAddr() string {
+	return fmt.Sprintf("tcp://*:%d", c.SnapshotPort)
+}
+
 // Client methods
 func (c *Config) PubConnectAddr() string {
 	return fmt.Sprintf("tcp://%s:%d", c.Host, c.BackendPort)
@@ -43,3 +88,7 @@ func (c *Config) PubConnectAddr() string {
 func (c *Config) SubConnectAddr() string {
 	return fmt.Sprintf("tcp://%s:%d", c.Host, c.FrontendPort)
 }
+
+func (c *Config) SnapshotConnectAddr() string {
+	return fmt.Sprintf("tcp://%s:%d", c.Host, c.SnapshotPort)
+}
@@ -0,0 +1,307 @@
+package lvc
+
+import (
+	"cmp"
+	"container/list"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry is a message of a topic
+type Entry struct {
+	Topic   string    `json:"topic"`
+	Seq     uint64    `json:"seq"`     // broker-wide, in arrival order
+	Frames  [][]byte  `json:"frames"`  // base64 in JSON
+	Updated time.Time `json:"updated"` // when the message arrived
+}
+
+func (e Entry) size() int64 {
+	n := int64(len(e.Topic))
+	for _, f := range e.Frames {
+		n += int64(len(f))
+	}
+	return n
+}
+
+// Limits bound what the cache holds. Zero values mean no bound.
+type Limits struct {
+	TTL        time.Duration            // lifetime of a topic after its last update
+	TopicTTL   map[string]time.Duration // per-topic TTL by topic prefix; the longest prefix wins over TTL
+	MaxBytes   int64                    // topics plus frames; history goes first, then the least recently used topics
+	History    int                      // messages kept per topic; below 1 only the last one
+	HistoryAge time.Duration            // older messages leave the history, except the last one
+}
+
+type item struct {
+	topic   string
+	history []Entry // oldest first; the last one is the current value
+	size    int64
+	expires time.Time // zero = never
+}
+
+func (it *item) last() Entry {
+	return it.history[len(it.history)-1]
+}
+
+// Cache holds the recent messages of every topic, numbered in arrival order.
+// Topics are kept sorted, so the topics under a prefix are a contiguous
+// range. Topics expire after their TTL, and when the cache outgrows MaxBytes
+// older history is dropped first, then the least recently used (updated or
+// replayed) topics are evicted. It is not
+// safe for concurrent use; the broker serves it from a single loop.
+type Cache struct {
+	limits Limits
+	topics []string // sorted
+	items  map[string]*list.Element
+	lru    *list.List // of *item, most recently used first
+	bytes  int64
+	seq    uint64 // of the last message
+}
+
+func NewCache(limits Limits) *Cache {
+	return &Cache{
+		limits: limits,
+		items:  make(map[string]*list.Element),
+		lru:    list.New(),
+	}
+}
+
+// Set stores a copy of frames as the last message of topic. It returns the
+// number given to the message and the topics evicted to stay within
+// MaxBytes. A message larger than MaxBytes on its own is not kept, and
+// neither are the older messages of its topic, which would be replayed as
+// the last value; the topic is then reported as evicted if it was cached.
+func (c *Cache) Set(topic string, frames [][]byte, now time.Time) (uint64, []string) {
+	c.seq++
+	e := Entry{Topic: topic, Seq: c.seq, Frames: make([][]byte, len(frames)), Updated: now}
+	for i, f := range frames {
+		e.Frames[i] = slices.Clone(f)
+	}
+	return c.seq, c.put(e, now)
+}
+
+// put appends e to the history of its topic and trims it.
+func (c *Cache) put(e Entry, now time.Time) []string {
+	if c.limits.MaxBytes > 0 && e.size() > c.limits.MaxBytes {
+		if _, ok := c.items[e.Topic]; ok {
+			c.remove(e.Topic)
+			return []string{e.Topic}
+		}
+		return nil
+	}
+
+	el, ok := c.items[e.Topic]
+	if !ok {
+		i := sort.SearchStrings(c.topics, e.Topic)
+		c.topics = slices.Insert(c.topics, i, e.Topic)
+		el = c.lru.PushFront(&item{topic: e.Topic})
+		c.items[e.Topic] = el
+	}
+	c.lru.MoveToFront(el)
+	it := el.Value.(*item)
+	it.history = append(it.history, e)
+	it.size += e.size()
+	c.bytes += e.size()
+	c.trim(it, now)
+	it.expires = time.Time{}
+	if ttl := c.ttl(e.Topic); ttl > 0 {
+		it.expires = e.Updated.Add(ttl)
+	}
+
+	// Over MaxBytes, history goes before any topic's last value: first the
+	// older messages of this topic, then those of the others, least
+	// recently used first, and only then whole topics
+	if c.limits.MaxBytes > 0 && c.bytes > c.limits.MaxBytes {
+		c.shed(it)
+		for el := c.lru.Back(); el != nil && c.bytes > c.limits.MaxBytes; el = el.Prev() {
+			c.shed(el.Value.(*item))
+		}
+	}
+	var evicted []string
+	for c.limits.MaxBytes > 0 && c.bytes > c.limits.MaxBytes {
+		oldest := c.lru.Back().Value.(*item)
+		c.remove(oldest.topic)
+		evicted = append(evicted, oldest.topic)
+	}
+	return evicted
+}
+
+// trim drops the messages beyond History or older than HistoryAge, keeping
+// the last one.
+func (c *Cache) trim(it *item, now time.Time) {
+	drop := max(len(it.history)-max(c.limits.History, 1), 0)
+	if c.limits.HistoryAge > 0 {
+		for drop < len(it.history)-1 && now.Sub(it.history[drop].Updated) > c.limits.HistoryAge {
+			drop++
+		}
+	}
+	if drop == 0 {
+		return
+	}
+	for _, e := range it.history[:drop] {
+		it.size -= e.size()
+		c.bytes -= e.size()
+	}
+	it.history = slices.Clone(it.history[drop:])
+}
+
+// shed drops the oldest messages of it, keeping the last one, until the
+// cache is within MaxBytes.
+func (c *Cache) shed(it *item) {
+	drop := 0
+	for drop < len(it.history)-1 && c.bytes > c.limits.MaxBytes {
+		size := it.history[drop].size()
+		it.size -= size
+		c.bytes -= size
+		drop++
+	}
+	if drop > 0 {
+		it.history = slices.Clone(it.history[drop:])
+	}
+}
+
+// ttl returns the TTL of topic: the one of its longest prefix in TopicTTL,
+// else the default.
+func (c *Cache) ttl(topic string) time.Duration {
+	ttl, best := c.limits.TTL, -1
+	for prefix, d := range c.limits.TopicTTL {
+		if len(prefix) > best && strings.HasPrefix(topic, prefix) {
+			ttl, best = d, len(prefix)
+		}
+	}
+	return ttl
+}
+
+// Prefix returns the last message of every topic starting with prefix, in
+// topic order. The empty prefix matches every topic, as in ZMQ subscriptions.
+// Messages older than maxAge (if not zero) are left out; the rest count as used.
+func (c *Cache) Prefix(prefix string, maxAge time.Duration, now time.Time) []Entry {
+	var out []Entry
+	c.each(prefix, now, func(it *item) {
+		if e := it.last(); maxAge <= 0 || now.Sub(e.Updated) <= maxAge {
+			out = append(out, e)
+		}
+	})
+	return out
+}
+
+// History returns the kept messages of every topic starting with prefix, at
+// most the last n of each (if n > 0) and none older than maxAge (if not
+// zero), in arrival order.
+func (c *Cache) History(prefix string, n int, maxAge time.Duration, now time.Time) []Entry {
+	var out []Entry
+	c.each(prefix, now, func(it *item) {
+		h := it.history
+		if n > 0 && len(h) > n {
+			h = h[len(h)-n:]
+		}
+		for _, e := range h {
+			if maxAge <= 0 || now.Sub(e.Updated) <= maxAge {
+				out = append(out, e)
+			}
+		}
+	})
+	slices.SortFunc(out, func(a, b Entry) int { return cmp.Compare(a.Seq, b.Seq) })
+	return out
+}
+
+// each calls fn for every live topic under prefix, in topic order, and
+// marks it used.
+func (c *Cache) each(prefix string, now time.Time, fn func(*item)) {
+	for i := sort.SearchStrings(c.topics, prefix); i < len(c.topics); i++ {
+		t := c.topics[i]
+		if !strings.HasPrefix(t, prefix) {
+			break
+		}
+		el := c.items[t]
+		it := el.Value.(*item)
+		if c.expired(it, now) {
+			continue
+		}
+		c.trim(it, now)
+		c.lru.MoveToFront(el)
+		fn(it)
+	}
+}
+
+// Seq returns the number of the last message; every message the cache
+// returns has a number up to it.
+func (c *Cache) Seq() uint64 {
+	return c.seq
+}
+
+// Entries returns every kept message, topics least recently used first and
+// each topic's history oldest first, so that putting them in order restores
+// the cache.
+func (c *Cache) Entries() []Entry {
+	var out []Entry
+	for el := c.lru.Back(); el != nil; el = el.Prev() {
+		out = append(out, el.Value.(*item).history...)
+	}
+	return out
+}
+
+// Expire removes the topics whose TTL ran out and returns them.
+func (c *Cache) Expire(now time.Time) []string {
+	var gone []string
+	for _, t := range c.topics {
+		if c.expired(c.items[t].Value.(*item), now) {
+			gone = append(gone, t)
+		}
+	}
+	for _, t := range gone {
+		c.remove(t)
+	}
+	return gone
+}
+
+func (c *Cache) expired(it *item, now time.Time) bool {
+	return !it.expires.IsZero() && now.After(it.expires)
+}
+
+func (c *Cache) remove(topic string) {
+	el, ok := c.items[topic]
+	if !ok {
+		return
+	}
+	c.bytes -= el.Value.(*item).size
+	c.lru.Remove(el)
+	delete(c.items, topic)
+	if i, found := slices.BinarySearch(c.topics, topic); found {
+		c.topics = slices.Delete(c.topics, i, i+1)
+	}
+}
+
+// Len returns the number of cached topics.
+func (c *Cache) Len() int {
+	return len(c.topics)
+}
+
+// Bytes returns the size of the cached topics and frames.
+func (c *Cache) Bytes() int64 {
+	return c.bytes
+}
+
+// ParseTopicTTL parses "sensors/temp=30s,sensors/=5m" into Limits.TopicTTL.
+// A TTL of 0 keeps the topics forever.
+func ParseTopicTTL(s string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		prefix, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("topic TTL %q: want prefix=duration", part)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("topic TTL %q: bad duration", part)
+		}
+		ttls[strings.TrimSpace(prefix)] = d
+	}
+	return ttls, nil
+}
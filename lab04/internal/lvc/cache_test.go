@@ -0,0 +1,278 @@
+package lvc
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// set stores payload as the last message of topic at now.
+func set(c *Cache, topic, payload string, now time.Time) []string {
+	_, evicted := c.Set(topic, [][]byte{[]byte(topic), []byte(payload)}, now)
+	return evicted
+}
+
+func topics(entries []Entry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.Topic)
+	}
+	return out
+}
+
+func TestCachePrefix(t *testing.T) {
+	c := NewCache(Limits{})
+	for _, topic := range []string{"sensors/temp", "alerts", "sensors/pressure", "sensorsX", "sensors/temp/2"} {
+		set(c, topic, "v", t0)
+	}
+	set(c, "sensors/temp", "latest", t0)
+
+	cases := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"alerts", "sensors/pressure", "sensors/temp", "sensors/temp/2", "sensorsX"}},
+		{"sensors/", []string{"sensors/pressure", "sensors/temp", "sensors/temp/2"}},
+		{"sensors/temp", []string{"sensors/temp", "sensors/temp/2"}},
+		{"sensors", []string{"sensors/pressure", "sensors/temp", "sensors/temp/2", "sensorsX"}},
+		{"a", []string{"alerts"}},
+		{"b", nil},
+		{"zzz", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.prefix, func(t *testing.T) {
+			if got := topics(c.Prefix(tc.prefix, 0, t0)); !slices.Equal(got, tc.want) {
+				t.Errorf("Prefix(%q) = %v, want %v", tc.prefix, got, tc.want)
+			}
+		})
+	}
+
+	got := c.Prefix("sensors/temp", 0, t0)[0]
+	if string(got.Frames[1]) != "latest" || got.Seq != 6 {
+		t.Errorf("sensors/temp = %s seq %d, want the latest value, seq 6", got.Frames[1], got.Seq)
+	}
+}
+
+func TestCachePrefixMaxAge(t *testing.T) {
+	c := NewCache(Limits{})
+	set(c, "a", "old", t0)
+	set(c, "b", "new", t0.Add(time.Minute))
+	now := t0.Add(90 * time.Second)
+	if got := topics(c.Prefix("", time.Minute, now)); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("Prefix with max age = %v, want [b]", got)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, max age must not delete", c.Len())
+	}
+}
+
+func TestCacheCopiesFrames(t *testing.T) {
+	c := NewCache(Limits{})
+	frames := [][]byte{[]byte("t"), []byte("v1")}
+	c.Set("t", frames, t0)
+	frames[1][1] = '2'
+	if got := string(c.Prefix("t", 0, t0)[0].Frames[1]); got != "v1" {
+		t.Errorf("cached frame %q changed with the caller's buffer", got)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := NewCache(Limits{
+		TTL:      time.Hour,
+		TopicTTL: map[string]time.Duration{"sensors/": 5 * time.Minute, "sensors/temp": 30 * time.Second, "static/": 0},
+	})
+	for _, topic := range []string{"other", "sensors/pressure", "sensors/temp", "static/name"} {
+		set(c, topic, "v", t0)
+	}
+
+	steps := []struct {
+		after time.Duration
+		gone  []string
+	}{
+		{30 * time.Second, nil},
+		{31 * time.Second, []string{"sensors/temp"}}, // longest prefix wins
+		{5*time.Minute + time.Second, []string{"sensors/pressure"}},
+		{time.Hour + time.Second, []string{"other"}},
+		{1000 * time.Hour, nil}, // a TTL of 0 keeps the topic
+	}
+	for _, s := range steps {
+		if gone := c.Expire(t0.Add(s.after)); !slices.Equal(gone, s.gone) {
+			t.Errorf("Expire after %s = %v, want %v", s.after, gone, s.gone)
+		}
+	}
+	if got := topics(c.Prefix("", 0, t0.Add(1000*time.Hour))); !slices.Equal(got, []string{"static/name"}) {
+		t.Errorf("left %v, want [static/name]", got)
+	}
+}
+
+func TestCacheTTLFromLastUpdate(t *testing.T) {
+	c := NewCache(Limits{TTL: time.Minute})
+	set(c, "a", "v", t0)
+	set(c, "a", "v", t0.Add(50*time.Second))
+	now := t0.Add(90 * time.Second)
+	if gone := c.Expire(now); len(gone) != 0 {
+		t.Errorf("Expire = %v, an update must restart the TTL", gone)
+	}
+	// Expired topics are not replayed even before Expire removes them
+	if got := c.Prefix("", 0, t0.Add(111*time.Second)); len(got) != 0 {
+		t.Errorf("Prefix = %v after the TTL", topics(got))
+	}
+}
+
+func TestCacheLRU(t *testing.T) {
+	// Each topic takes 2*1 + 8 = 10 bytes
+	c := NewCache(Limits{MaxBytes: 30})
+	for _, topic := range []string{"a", "b", "c"} {
+		if evicted := set(c, topic, "12345678", t0); evicted != nil {
+			t.Fatalf("evicted %v below the limit", evicted)
+		}
+	}
+	if c.Bytes() != 30 {
+		t.Fatalf("Bytes() = %d, want 30", c.Bytes())
+	}
+
+	c.Prefix("a", 0, t0) // a replay counts as use
+	if evicted := set(c, "d", "12345678", t0); !slices.Equal(evicted, []string{"b"}) {
+		t.Errorf("evicted %v, want [b]", evicted)
+	}
+	set(c, "c", "12345678", t0) // so does an update
+	if evicted := set(c, "e", "123456789012345678", t0); !slices.Equal(evicted, []string{"a", "d"}) {
+		t.Errorf("evicted %v, want [a d]", evicted)
+	}
+	if got := topics(c.Prefix("", 0, t0)); !slices.Equal(got, []string{"c", "e"}) || c.Bytes() != 30 {
+		t.Errorf("left %v in %d bytes, want [c e] in 30", got, c.Bytes())
+	}
+
+	// A message larger than the whole cache is not kept, and the others stay
+	if evicted := set(c, "f", "1234567890123456789012345678901", t0); evicted != nil || c.Len() != 2 || c.Bytes() != 30 {
+		t.Errorf("oversized new topic: evicted %v, %d topics in %d bytes left", evicted, c.Len(), c.Bytes())
+	}
+	// Its topic's older value is not replayed as the last one
+	if evicted := set(c, "c", "1234567890123456789012345678901", t0); !slices.Equal(evicted, []string{"c"}) {
+		t.Errorf("oversized update evicted %v, want [c]", evicted)
+	}
+	if got := topics(c.Prefix("", 0, t0)); !slices.Equal(got, []string{"e"}) || c.Bytes() != 20 {
+		t.Errorf("left %v in %d bytes, want [e] in 20", got, c.Bytes())
+	}
+}
+
+func TestParseTopicTTL(t *testing.T) {
+	got, err := ParseTopicTTL(" sensors/temp=30s, sensors/ = 5m ,,static/=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]time.Duration{"sensors/temp": 30 * time.Second, "sensors/": 5 * time.Minute, "static/": 0}
+	if len(got) != len(want) {
+		t.Fatalf("ParseTopicTTL = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if d, ok := got[k]; !ok || d != v {
+			t.Errorf("TTL of %q = %v, want %v", k, d, v)
+		}
+	}
+
+	for _, bad := range []string{"sensors/", "sensors/=soon", "sensors/=-1s"} {
+		if _, err := ParseTopicTTL(bad); err == nil {
+			t.Errorf("ParseTopicTTL(%q) accepted", bad)
+		}
+	}
+}
+
+func payloads(entries []Entry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, string(e.Frames[1]))
+	}
+	return out
+}
+
+func TestCacheHistory(t *testing.T) {
+	c := NewCache(Limits{History: 3})
+	for i, p := range []string{"t1", "t2", "t3", "t4"} {
+		set(c, "sensors/temp", p, t0.Add(time.Duration(i)*time.Second))
+	}
+	set(c, "sensors/pressure", "p1", t0.Add(2500*time.Millisecond))
+	set(c, "alerts", "a1", t0)
+	now := t0.Add(4 * time.Second)
+
+	cases := []struct {
+		name   string
+		prefix string
+		n      int
+		maxAge time.Duration
+		want   []string
+	}{
+		{"trimmed to History", "sensors/temp", 0, 0, []string{"t2", "t3", "t4"}},
+		{"arrival order across topics", "sensors/", 0, 0, []string{"t2", "t3", "t4", "p1"}},
+		{"last n of each topic", "sensors/", 1, 0, []string{"t4", "p1"}},
+		{"max age", "sensors/", 0, 1500 * time.Millisecond, []string{"t4", "p1"}},
+		{"everything", "", 2, 0, []string{"t3", "t4", "p1", "a1"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := payloads(c.History(tc.prefix, tc.n, tc.maxAge, now)); !slices.Equal(got, tc.want) {
+				t.Errorf("History(%q, %d, %s) = %v, want %v", tc.prefix, tc.n, tc.maxAge, got, tc.want)
+			}
+		})
+	}
+	if got := payloads(c.Prefix("sensors/temp", 0, now)); !slices.Equal(got, []string{"t4"}) {
+		t.Errorf("Prefix = %v, want the last message only", got)
+	}
+}
+
+func TestCacheHistoryAge(t *testing.T) {
+	c := NewCache(Limits{History: 10, HistoryAge: time.Minute})
+	set(c, "a", "1", t0)
+	set(c, "a", "2", t0.Add(30*time.Second))
+	set(c, "a", "3", t0.Add(61*time.Second))
+	if got := payloads(c.History("a", 0, 0, t0.Add(61*time.Second))); !slices.Equal(got, []string{"2", "3"}) {
+		t.Errorf("History = %v, want [2 3]", got)
+	}
+	before := c.Bytes()
+
+	// Old messages leave the history as time goes on, except the last one,
+	// which stays until the topic's TTL
+	if got := payloads(c.History("a", 0, 0, t0.Add(time.Hour))); !slices.Equal(got, []string{"3"}) {
+		t.Errorf("History an hour later = %v, want [3]", got)
+	}
+	if c.Bytes() >= before {
+		t.Errorf("Bytes() = %d after trimming, was %d", c.Bytes(), before)
+	}
+}
+
+func TestCacheNoHistory(t *testing.T) {
+	c := NewCache(Limits{})
+	set(c, "a", "1", t0)
+	set(c, "a", "2", t0)
+	if got := payloads(c.History("a", 0, 0, t0)); !slices.Equal(got, []string{"2"}) {
+		t.Errorf("History = %v, want the last message only", got)
+	}
+	if want := int64(2 + 1); c.Bytes() != want {
+		t.Errorf("Bytes() = %d, want %d", c.Bytes(), want)
+	}
+}
+
+func TestCacheHistoryBeforeLastValues(t *testing.T) {
+	// Each message takes 2*1 + 8 = 10 bytes
+	c := NewCache(Limits{MaxBytes: 50, History: 100})
+	set(c, "x", "quiet---", t0)
+	for i := range 20 {
+		if evicted := set(c, "y", "hot-----", t0.Add(time.Duration(i)*time.Second)); evicted != nil {
+			t.Fatalf("update %d evicted %v; y's history must go first", i, evicted)
+		}
+	}
+	if got := len(c.History("y", 0, 0, t0.Add(time.Minute))); got != 4 || c.Bytes() != 50 {
+		t.Errorf("y keeps %d messages in %d bytes, want 4 in 50", got, c.Bytes())
+	}
+	if got := payloads(c.Prefix("x", 0, t0.Add(time.Minute))); !slices.Equal(got, []string{"quiet---"}) {
+		t.Errorf("x = %v, its last value must survive", got)
+	}
+
+	// A new topic takes history from the others, least recently used first
+	set(c, "z", "new-----", t0.Add(time.Minute))
+	if got := len(c.History("y", 0, 0, t0.Add(time.Minute))); got != 3 || c.Len() != 3 {
+		t.Errorf("y keeps %d messages, %d topics; want 3 and 3", got, c.Len())
+	}
+}
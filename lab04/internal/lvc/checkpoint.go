@@ -0,0 +1,104 @@
+package lvc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// checkpointVersion is bumped when the checkpoint layout changes.
+// Version 2 keeps the history of every topic and the message numbers.
+const checkpointVersion = 2
+
+// Checkpoint is the persisted cache. Checksum is the SHA-256 of the Entries
+// JSON exactly as written, so a torn or edited file is refused.
+type Checkpoint struct {
+	Version  int             `json:"version"`
+	SavedAt  time.Time       `json:"saved_at"`
+	Checksum string          `json:"checksum"`
+	Entries  json.RawMessage `json:"entries"` // []Entry, as Cache.Entries returns them
+}
+
+// Checkpoint captures the cached messages.
+func (c *Cache) Checkpoint() (*Checkpoint, error) {
+	entries, err := json.Marshal(c.Entries())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(entries)
+	return &Checkpoint{
+		Version:  checkpointVersion,
+		Checksum: hex.EncodeToString(sum[:]),
+		Entries:  entries,
+	}, nil
+}
+
+// Restore loads the messages of a checkpoint into the cache and carries on
+// with their numbering. TTLs count from each topic's last update, not from
+// the restart, so topics that expired while the broker was down are dropped.
+// It returns how many were dropped.
+func (c *Cache) Restore(cp *Checkpoint, now time.Time) (int, error) {
+	if cp.Version != checkpointVersion {
+		return 0, fmt.Errorf("unsupported checkpoint version %d", cp.Version)
+	}
+	sum := sha256.Sum256(cp.Entries)
+	if hex.EncodeToString(sum[:]) != cp.Checksum {
+		return 0, errors.New("checkpoint checksum mismatch")
+	}
+	var entries []Entry
+	if err := json.Unmarshal(cp.Entries, &entries); err != nil {
+		return 0, fmt.Errorf("parse entries: %w", err)
+	}
+	for _, e := range entries {
+		c.put(e, now)
+		c.seq = max(c.seq, e.Seq)
+	}
+	return len(c.Expire(now)), nil
+}
+
+// LoadCheckpoint reads a checkpoint. A missing file returns nil, nil.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// Save writes the checkpoint atomically (temp file + rename). The temp file
+// is synced first, so a crash leaves either the old checkpoint or the new one.
+func (cp *Checkpoint) Save(path string) error {
+	cp.SavedAt = time.Now()
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,130 @@
+package lvc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	c := NewCache(Limits{History: 3})
+	set(c, "b", "1", t0)
+	set(c, "a", "2", t0)
+	set(c, "b", "3", t0.Add(time.Second))
+
+	path := filepath.Join(t.TempDir(), "lvc_cache.json")
+	cp, err := c.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temp file left behind: %v", err)
+	}
+	loaded, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.SavedAt.IsZero() {
+		t.Error("SavedAt not set")
+	}
+
+	r := NewCache(Limits{History: 3})
+	if expired, err := r.Restore(loaded, t0.Add(time.Second)); err != nil || expired != 0 {
+		t.Fatalf("Restore = %d, %v", expired, err)
+	}
+	if r.Seq() != 3 || r.Bytes() != c.Bytes() {
+		t.Errorf("restored seq %d, %d bytes; want 3, %d", r.Seq(), r.Bytes(), c.Bytes())
+	}
+	got, want := r.Entries(), c.Entries()
+	if len(got) != len(want) {
+		t.Fatalf("restored %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Topic != w.Topic || g.Seq != w.Seq || !g.Updated.Equal(w.Updated) || !slices.EqualFunc(g.Frames, w.Frames, bytes.Equal) {
+			t.Errorf("entry %d = %+v, want %+v", i, g, w)
+		}
+	}
+
+	// Numbering carries on after the restored messages
+	if seq, _ := r.Set("c", nil, t0); seq != 4 {
+		t.Errorf("next seq %d, want 4", seq)
+	}
+}
+
+func TestRestoreRejects(t *testing.T) {
+	c := NewCache(Limits{})
+	set(c, "a", "1", t0)
+
+	cases := []struct {
+		name   string
+		modify func(*Checkpoint)
+	}{
+		{"checksum mismatch", func(cp *Checkpoint) {
+			cp.Entries = bytes.Replace(cp.Entries, []byte(`"a"`), []byte(`"b"`), 1)
+		}},
+		{"bad checksum", func(cp *Checkpoint) { cp.Checksum = "00" }},
+		{"unknown version", func(cp *Checkpoint) { cp.Version = checkpointVersion + 1 }},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cp, err := c.Checkpoint()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.modify(cp)
+			r := NewCache(Limits{})
+			if _, err := r.Restore(cp, t0); err == nil {
+				t.Error("Restore accepted the checkpoint")
+			}
+			if r.Len() != 0 || r.Seq() != 0 {
+				t.Errorf("refused checkpoint left %d topics, seq %d", r.Len(), r.Seq())
+			}
+		})
+	}
+}
+
+func TestRestoreDropsExpired(t *testing.T) {
+	c := NewCache(Limits{})
+	set(c, "sensors/temp", "1", t0)
+	set(c, "sensors/pressure", "2", t0.Add(time.Minute))
+	cp, err := c.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The TTL counts from the last update, not from the restart
+	r := NewCache(Limits{TTL: 2 * time.Minute})
+	expired, err := r.Restore(cp, t0.Add(150*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 1 || !slices.Equal(topics(r.Prefix("", 0, t0.Add(150*time.Second))), []string{"sensors/pressure"}) {
+		t.Errorf("Restore expired %d, left %v; want 1 and [sensors/pressure]", expired, topics(r.Entries()))
+	}
+	if r.Seq() != 2 {
+		t.Errorf("Seq() = %d, want 2 even with the expired message dropped", r.Seq())
+	}
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	cp, err := LoadCheckpoint(filepath.Join(dir, "missing.json"))
+	if cp != nil || err != nil {
+		t.Errorf("missing checkpoint = %v, %v; want nil, nil", cp, err)
+	}
+
+	torn := filepath.Join(dir, "torn.json")
+	if err := os.WriteFile(torn, []byte(`{"version":2,"entr`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCheckpoint(torn); err == nil {
+		t.Error("LoadCheckpoint accepted a torn file")
+	}
+}
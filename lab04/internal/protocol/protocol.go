@@ -24,3 +24,37 @@ func FromBytes(data []byte) (*TelemetryData, error) {
 	err := json.Unmarshal(data, &t)
 	return &t, err
 }
+
+// Snapshot channel. A terminal sends [SnapshotRequest, prefix] to the
+// broker's ROUTER and gets one [SnapshotCached, age, seq, topic, payload...]
+// message per cached topic under the prefix, then [SnapshotEnd, count, seq].
+// [SnapshotHistory, prefix, last, since] asks for the kept messages instead,
+// at most the last ones of each topic and none older than since
+// milliseconds (0 = no limit), answered with [SnapshotHistory, age, seq,
+// topic, payload...] messages in arrival order, then [SnapshotEnd, count, seq].
+//
+// The age is the time in milliseconds since the broker received the message.
+// The broker numbers every message it receives, and the live stream carries
+// the number as an extra last frame: [topic, payload..., seq]. The seq of
+// SnapshotEnd is the last number at the time of the reply, so a terminal
+// whose subscription was in place before asking drops the live messages up
+// to it and misses none after it. Only the asking terminal sees the replies.
+//
+// PUB applies subscriptions asynchronously, so a terminal cannot tell from
+// subscribing alone that the broker has it. It also subscribes to a marker
+// topic under SyncPrefix and sends [SnapshotSync, marker]; the broker
+// publishes [marker] on the live stream and does not reply. Once the marker
+// arrives, the subscriptions made before it are in place too.
+const (
+	SnapshotRequest = "SNAPSHOT"
+	SnapshotHistory = "HISTORY"
+	SnapshotCached  = "CACHED"
+	SnapshotEnd     = "END"
+	SnapshotError   = "ERROR"
+	SnapshotSync    = "SYNC"
+)
+
+// SyncPrefix starts every marker topic. The broker publishes no other
+// markers, and a marker is a single frame, which terminals subscribed to
+// everything skip.
+const SyncPrefix = "$sync/"
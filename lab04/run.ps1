@@ -18,6 +18,9 @@ Start-Sleep -Seconds 3
 Write-Host "Starting Analyst Terminal..."
 Start-Process ".\analyst_terminal.exe" -ArgumentList "sensors/temp" -NoNewWindow
 
+Write-Host "Starting Analyst Terminal with history replay of all sensors..."
+Start-Process ".\analyst_terminal.exe" -ArgumentList "-last", "3", "sensors/" -NoNewWindow
+
 Write-Host "Lab 04 running. Press Ctrl+C to stop."
 
 trap {